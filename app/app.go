@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"sourcecrawler/app/handler"
+	"sourcecrawler/app/model"
 	"sourcecrawler/config"
 
 	"github.com/gorilla/mux"
@@ -18,14 +19,27 @@ type App struct {
 
 // Initialize initializes the app with predefined configuration
 func (a *App) Initialize(config *config.Config) {
+	db, err := gorm.Open(config.DB.Dialect, config.DB.URI())
+	if err != nil {
+		log.Fatal("Could not connect database: ", err)
+	}
+
+	if err := handler.Configure(config); err != nil {
+		log.Fatal("Could not configure the handlers: ", err)
+	}
+	a.DB = model.DBMigrate(db)
 	a.Router = mux.NewRouter()
 	a.setRouters()
 }
 
 // setRouters sets the all required routers
 func (a *App) setRouters() {
+	a.Get("/config", a.handleRequest(handler.ShowConfig))
 	a.Post("/slicer", a.handleRequest(handler.SliceProgram))
 	a.Post("/unsafe", a.handleRequest(handler.UnsafeEndpoint))
+	a.Post("/index", a.handleRequest(handler.IndexProject))
+	a.Post("/logsource", a.handleRequest(handler.LogSource))
+	a.Post("/logsource/batch", a.handleRequest(handler.LogSourceBatch))
 }
 
 // Get wraps the router for GET method
@@ -0,0 +1,276 @@
+package cfg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//z3Converter keeps the constraints defining the constants standing for
+//the results of the operations the solver has no operator for
+type z3Converter struct {
+	ctx  *z3.Context
+	fset *token.FileSet
+	defs []*z3.AST
+}
+
+//Widths of the sized integer types and whether they are signed, int and
+//uint are 64 bits wide
+var intWidths = map[string]struct {
+	bits   int
+	signed bool
+}{
+	"int": {64, true}, "int8": {8, true}, "int16": {16, true}, "int32": {32, true}, "int64": {64, true}, "rune": {32, true},
+	"uint": {64, false}, "uint8": {8, false}, "uint16": {16, false}, "uint32": {32, false}, "uint64": {64, false},
+	"uintptr": {64, false}, "byte": {8, false},
+}
+
+//Whether the node converts to a boolean, the constraints go with those
+func isCondition(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.AssignStmt:
+		return true
+	case *ast.ParenExpr:
+		return isCondition(node.X)
+	case *ast.UnaryExpr:
+		return node.Op == token.NOT
+	case *ast.BinaryExpr:
+		switch node.Op {
+		case token.EQL, token.NEQ, token.LSS, token.GTR, token.LEQ, token.GEQ, token.LAND, token.LOR:
+			return true
+		}
+	}
+	return false
+}
+
+//Constant standing for the value of the expression, its name is in
+//brackets so it isn't taken for a variable ([a / b])
+func (c *z3Converter) derived(name string) *z3.AST {
+	return c.ctx.Const(c.ctx.Symbol("["+name+"]"), c.ctx.IntSort())
+}
+
+//Integer of any size, Z3 makes them from 32 bits
+func (c *z3Converter) integer(v int64) *z3.AST {
+	if v >= -1<<31 && v < 1<<31 {
+		return c.ctx.Int(int(v), c.ctx.IntSort())
+	}
+	return c.integer(v >> 30).Mul(c.pow2(30)).Add(c.integer(v & (1<<30 - 1)))
+}
+
+func (c *z3Converter) pow2(k int) *z3.AST {
+	if k <= 30 {
+		return c.ctx.Int(1<<uint(k), c.ctx.IntSort())
+	}
+	return c.pow2(30).Mul(c.pow2(k - 30))
+}
+
+func (c *z3Converter) abs(v *z3.AST) *z3.AST {
+	zero := c.integer(0)
+	return v.Ge(zero).Ite(v, zero.Sub(v))
+}
+
+//Quotient and remainder of the division. Go truncates the quotient
+//towards zero so the remainder has the sign of the dividend, nothing
+//holds for a division by zero, it panics
+func (c *z3Converter) divide(expr *ast.BinaryExpr, x, y *z3.AST) (*z3.AST, *z3.AST) {
+	quotient := c.derived(types.ExprString(&ast.BinaryExpr{X: expr.X, Op: token.QUO, Y: expr.Y}))
+	remainder := c.derived(types.ExprString(&ast.BinaryExpr{X: expr.X, Op: token.REM, Y: expr.Y}))
+	zero := c.integer(0)
+	defined := x.Eq(y.Mul(quotient).Add(remainder)).And(
+		c.abs(remainder).Lt(c.abs(y)),
+		x.Ge(zero).Implies(remainder.Ge(zero)),
+		x.Lt(zero).Implies(remainder.Le(zero)),
+	)
+	c.defs = append(c.defs, y.Eq(zero).Not().Implies(defined))
+	return quotient, remainder
+}
+
+//Shifts by a constant multiply or divide by a power of two, rounding
+//down like the bits shifted out do. Shifts by variables are unknown
+func (c *z3Converter) shift(expr *ast.BinaryExpr, x *z3.AST) *z3.AST {
+	k, ok := constValue(expr.Y)
+	if !ok || k < 0 || k > 64 {
+		return nil
+	}
+	if expr.Op == token.SHL {
+		return x.Mul(c.pow2(int(k)))
+	}
+	name := types.ExprString(expr)
+	quotient, low := c.derived(name), c.derived(name+" low bits")
+	zero := c.integer(0)
+	c.defs = append(c.defs, x.Eq(c.pow2(int(k)).Mul(quotient).Add(low)), low.Ge(zero), low.Lt(c.pow2(int(k))))
+	return quotient
+}
+
+//Bitwise operations work on the bits of the operands, in two's
+//complement for the signed types
+func (c *z3Converter) bitwise(expr *ast.BinaryExpr, x, y *z3.AST) *z3.AST {
+	width, signed := widthOf(expr.X, expr.Y)
+	a, b := c.bits(expr.X, x, width, signed), c.bits(expr.Y, y, width, signed)
+	zero, one := c.integer(0), c.integer(1)
+	terms := make([]*z3.AST, width)
+	for i := range terms {
+		ai, bi := a[i].Eq(one), b[i].Eq(one)
+		var set *z3.AST
+		switch expr.Op {
+		case token.AND:
+			set = ai.And(bi)
+		case token.OR:
+			set = ai.Or(bi)
+		case token.XOR:
+			set = ai.Xor(bi)
+		case token.AND_NOT:
+			set = ai.And(bi.Not())
+		}
+		terms[i] = set.Ite(c.weight(i, width, signed), zero)
+	}
+	return zero.Add(terms...)
+}
+
+//Bits of the value, the least significant first. The bits of a variable
+//are constants named after it, the value is their sum
+func (c *z3Converter) bits(expr ast.Expr, value *z3.AST, width int, signed bool) []*z3.AST {
+	bits := make([]*z3.AST, width)
+	if v, ok := constValue(expr); ok {
+		for i := range bits {
+			bits[i] = c.integer(int64(uint64(v) >> uint(i) & 1))
+		}
+		return bits
+	}
+	name := types.ExprString(expr)
+	zero, one := c.integer(0), c.integer(1)
+	terms := make([]*z3.AST, width)
+	for i := range bits {
+		bits[i] = c.derived(fmt.Sprint(name, " bit ", i))
+		c.defs = append(c.defs, bits[i].Ge(zero), bits[i].Le(one))
+		terms[i] = bits[i].Mul(c.weight(i, width, signed))
+	}
+	c.defs = append(c.defs, value.Eq(zero.Add(terms...)))
+	return bits
+}
+
+//Value of the bit, the sign bit counts negatively
+func (c *z3Converter) weight(i, width int, signed bool) *z3.AST {
+	if signed && i == width-1 {
+		return c.integer(0).Sub(c.pow2(i))
+	}
+	return c.pow2(i)
+}
+
+//Width of the first operand with a sized type, int otherwise
+func widthOf(exprs ...ast.Expr) (int, bool) {
+	for _, expr := range exprs {
+		if width, ok := intWidths[typeName(expr)]; ok {
+			return width.bits, width.signed
+		}
+	}
+	return 64, true
+}
+
+//Name of the type the variable is declared with, empty when it's not
+//spelled out
+func typeName(expr ast.Expr) string {
+	if t, ok := typeOf(expr).(*ast.Ident); ok {
+		return t.Name
+	}
+	return ""
+}
+
+//Value of an integer literal
+func constValue(expr ast.Expr) (int64, bool) {
+	switch expr := unparen(expr).(type) {
+	case *ast.BasicLit:
+		if expr.Kind != token.INT {
+			return 0, false
+		}
+		if v, err := strconv.ParseInt(expr.Value, 0, 64); err == nil {
+			return v, true
+		}
+		v, err := strconv.ParseUint(expr.Value, 0, 64)
+		return int64(v), err == nil
+	case *ast.UnaryExpr:
+		if v, ok := constValue(expr.X); ok && expr.Op == token.SUB {
+			return -v, true
+		}
+	}
+	return 0, false
+}
+
+//Conditions of the block to panic on its own, when the trace ends in a
+//block without a panic call: one of its divisions divided by zero or one
+//of the pointers it dereferences is nil
+func (b *BlockWrapper) implicitPanics() []ast.Node {
+	if b.Block == nil || b.callsPanic() {
+		return nil
+	}
+	nodes := b.ssaNodes()
+	if condition := b.ssaCondition(); condition != nil {
+		nodes = append(nodes, condition)
+	}
+	conditions := make([]ast.Expr, 0)
+	for _, node := range nodes {
+		ast.Inspect(node, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.BinaryExpr:
+				if node.Op != token.QUO && node.Op != token.REM || isFloat(node.X) || isFloat(node.Y) {
+					return true
+				}
+				if v, ok := constValue(node.Y); ok && v != 0 {
+					return true
+				}
+				conditions = append(conditions, &ast.BinaryExpr{X: node.Y, Op: token.EQL, Y: &ast.BasicLit{Kind: token.INT, Value: "0"}})
+			}
+			return true
+		})
+	}
+	var condition ast.Expr
+	seen := make(map[string]bool)
+	for _, c := range append(conditions, b.nilPanics()...) {
+		if name := types.ExprString(c); !seen[name] {
+			seen[name] = true
+			if condition == nil {
+				condition = c
+			} else {
+				condition = &ast.BinaryExpr{X: condition, Op: token.LOR, Y: c}
+			}
+		}
+	}
+	if condition == nil {
+		return nil
+	}
+	return []ast.Node{condition}
+}
+
+//Whether the block calls panic itself
+func (b *BlockWrapper) callsPanic() bool {
+	found := false
+	for _, node := range b.Block.Nodes {
+		ast.Inspect(node, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.CallExpr:
+				found = found || isBuiltin(node.Fun, "panic")
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+//Whether the expression is a floating point number, dividing those by
+//zero doesn't panic
+func isFloat(expr ast.Expr) bool {
+	if lit, ok := unparen(expr).(*ast.BasicLit); ok {
+		return lit.Kind == token.FLOAT
+	}
+	name := typeName(expr)
+	return strings.HasPrefix(name, "float") || strings.HasPrefix(name, "complex")
+}
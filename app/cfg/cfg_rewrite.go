@@ -10,6 +10,7 @@ import (
 	"sourcecrawler/app/helper"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/tools/go/cfg"
 )
@@ -17,150 +18,94 @@ import (
 // ------------------ Logical Methods ------------------
 
 func (paths *PathList) TraverseCFG(curr Wrapper, root Wrapper) []Path {
+	stmts, labels := make([]ast.Node, 0), make([]ExecutionLabel, 0)
+	//the panic may be in a library function called at the end of the
+	//block, or in a division or a dereference of it
+	if b, ok := curr.(*BlockWrapper); ok {
+		paths.exception = b.nodeOnLine(paths.ExceptionLine)
+		panics := b.libraryPanics()
+		if len(panics) == 0 {
+			panics = b.implicitPanics()
+		}
+		stmts, labels = appendMust(stmts, labels, panics)
+	}
 
-	paths.TraverseCFGRecur(curr, make(map[string]int), make([]ast.Node, 0), root, make(map[string]ast.Node), make([]ExecutionLabel, 0), false)
+	if paths.Timeout > 0 {
+		paths.deadline = time.Now().Add(paths.Timeout)
+	}
+	paths.findLoops(curr)
+	if paths.Observed != nil {
+		paths.fromGraph = fromGraph(graphLogs(curr, paths.exception), paths.Observed)
+	}
+	paths.TraverseCFGRecur(curr, stmts, root, make(map[string]ast.Node), labels, false, loopState{}, make([]LogEvent, 0))
+	paths.prioritize()
 	return paths.Paths
 }
 
+//ConvertCFGtoSSAForm builds the ssa form of every block reachable from
+//the root, the syntax trees the blocks come from aren't changed. Graphs
+//built from go/ssa are in ssa form already
 func ConvertCFGtoSSAForm(root Wrapper) {
-	ConvertCFGtoSSAFormRecur(root, make(map[string]int), make(map[ast.Node]struct{}))
-}
-
-//adds function name and ssa identifier to variables, done before traversal?
-func ConvertCFGtoSSAFormRecur(curr Wrapper, ssaInts map[string]int, alreadySSA map[ast.Node]struct{}) {
-	if curr, ok := curr.(*BlockWrapper); ok {
-		for _, node := range curr.Block.Nodes {
-			good := false
-			switch node.(type) {
-			case *ast.AssignStmt, *ast.IncDecStmt, ast.Expr:
-				good = true
-			}
-			if good {
-				ast.Inspect(node, func(node ast.Node) bool {
-					switch node := node.(type) {
-					case *ast.Ident:
-						//Grab function name and identifier name
-						if fn, ok := curr.GetOuterWrapper().(*FnWrapper); ok {
-							var fnName string
-							switch fn := fn.Fn.(type) {
-							case *ast.FuncDecl:
-								fnName = fn.Name.Name
-							case *ast.FuncLit:
-								//TODO: wat do??
-								fnName = "lit"
-							}
-							if !strings.Contains(node.Name, fnName+".") {
-								node.Name = fmt.Sprint(fnName, ".", node.Name)
-							}
-						}
-					}
-					return true
-				})
-			}
-		}
-		for i, node := range curr.Block.Nodes {
-			//Increment counter for each object encountered
-			switch node := node.(type) {
-			case *ast.AssignStmt, *ast.IncDecStmt:
-				artificial, same := RessignmentConversion(node, curr.GetFileSet())
-				if artificial != nil {
-					if same {
-						artificial = node.(*ast.AssignStmt)
-					}
-					for i, l := range artificial.Lhs {
-						if id, ok := l.(*ast.Ident); ok {
-							name := id.Name
-							if i, ok := ssaInts[name]; ok && i == 0 {
-								delete(ssaInts, name)
-							} else if _, ok := alreadySSA[node]; ok {
-								ssaInts[name]--
-							}
-							SSAconversion(artificial.Rhs[i], ssaInts)
-							ssaInts[name]++
-							SSAconversion(l, ssaInts)
-							alreadySSA[artificial] = struct{}{}
-						}
-					}
-					curr.Block.Nodes[i] = artificial
-				}
-			case *ast.ExprStmt:
-				SSAconversion(node.X, ssaInts)
-			case ast.Expr:
-				SSAconversion(node, ssaInts)
-			}
-		}
-	}
-
-	for _, child := range curr.GetChildren() {
-		ConvertCFGtoSSAFormRecur(child, ssaInts, alreadySSA)
+	if fn, ok := root.(*FnWrapper); ok && fn.SSAFn != nil {
+		return
 	}
+	buildSSA(root)
 }
 
 // ------------- Traversal function ---------------
 // Assumptions: outer wrapper has already been assigned, and tree structure has been created.
-func (paths *PathList) TraverseCFGRecur(curr Wrapper, ssaInts map[string]int,
-	stmts []ast.Node, root Wrapper, varFilter map[string]ast.Node, pathLabels []ExecutionLabel, fromElse bool) {
+func (paths *PathList) TraverseCFGRecur(curr Wrapper,
+	stmts []ast.Node, root Wrapper, varFilter map[string]ast.Node, pathLabels []ExecutionLabel, fromElse bool, loops loopState, logs []LogEvent) {
+	//the path is dropped past the limits
+	if paths.stopped() {
+		return
+	}
+	paths.depth++
+	defer func() { paths.depth-- }()
+
 	//Check if if is a FnWrapper or BlockWrapper Type
 	switch currWrapper := curr.(type) {
 	case *FnWrapper:
 	case *BlockWrapper:
-		if len(currWrapper.Succs) == 2 {
-			ast.Inspect(currWrapper.Block.Nodes[len(currWrapper.Block.Nodes)-1], func(node ast.Node) bool {
-				switch node := node.(type) {
-				case *ast.Ident:
-					//Grab function name and identifier name
-					if fn, ok := currWrapper.GetOuterWrapper().(*FnWrapper); ok {
-						var fnName string
-						switch fn := fn.Fn.(type) {
-						case *ast.FuncDecl:
-							fnName = fn.Name.Name
-						case *ast.FuncLit:
-							//TODO: wat do??
-							fnName = "lit"
-						}
-
-						//Remove extra fnName.fnName.fnName... (works for now)
-						if !strings.Contains(node.Name, fnName+".") {
-							node.Name = fmt.Sprint(fnName, ".", node.Name)
-						}
-					}
-				}
-				return true
-			})
+		defer paths.countDefers(currWrapper)()
+		//Variables assigned in loops get fresh names in every iteration
+		var links []ast.Node
+		loops, links = paths.enterLoops(currWrapper, loops)
+		stmts, pathLabels = appendMust(stmts, pathLabels, links)
+		loops, links = loops.resolvePending(currWrapper)
+		stmts, pathLabels = appendMust(stmts, pathLabels, links)
+		//the path is gathered backwards, the block's logs come first.
+		//Nothing after the exception in its block ran
+		events := currWrapper.logEvents()
+		if paths.depth == 1 {
+			events = eventsUntil(events, paths.exception)
 		}
+		logs = append(events, logs...)
 
-		for _, node := range currWrapper.Block.Nodes {
+		for _, node := range currWrapper.ssaNodes() {
 			//Increment counter for each object encountered
 			switch node := node.(type) {
 			case *ast.AssignStmt, *ast.IncDecStmt:
 				reassignment, _ := RessignmentConversion(node, curr.GetFileSet())
 				if reassignment != nil {
-					stmts = append(stmts, node)				
-
-					//Override the label for assignment statements, because its label inside a block could be different from the condition's label
-					if strings.Contains(currWrapper.Block.String(), "if.done") || strings.Contains(currWrapper.Block.String(), "entry"){ //An assignment in ifDone/entry should be must
-						//fmt.Println("If done block", currWrapper.Block.String())
-						pathLabels = append(pathLabels, Must)
-					}else {
-						//fmt.Println("Lbl in conversion", currWrapper.GetLabel())
-						pathLabels = append(pathLabels, currWrapper.GetLabel())
-					}
+					stmts = append(stmts, loops.renamed(reassignment))
+					//an assignment ran if its block did
+					pathLabels = append(pathLabels, currWrapper.GetLabel())
+				}
+			case ast.Expr:
+				//a library function returned with its constraints holding
+				if currWrapper.isLibrary() {
+					stmts = append(stmts, loops.renamed(node))
+					pathLabels = append(pathLabels, Must)
 				}
-				// pathLabels = append(pathLabels, currWrapper.GetLabel())
 			}
 		}
 
 		//If conditional block, extract the condition and add to list
-		condition := currWrapper.GetCondition()
+		condition := currWrapper.ssaCondition()
 
-		var isNegated bool = false //Used to assign a Must/MustNot label accordingly
 		if condition != nil {
-			ast.Inspect(condition, func(node ast.Node) bool {
-				if node, ok := node.(*ast.Ident); ok {
-					SSAconversion(node, ssaInts)
-				}
-				return true
-			})
+			condition = loops.renamed(condition)
 
 			if cond, ok := condition.(ast.Expr); fromElse && ok {
 				// Came from an else and the condition is an expression
@@ -171,8 +116,6 @@ func (paths *PathList) TraverseCFGRecur(curr Wrapper, ssaInts map[string]int,
 					Op:    token.NOT,
 					X:     cond,
 				}
-		
-				isNegated = true
 			}
 
 			//Prevent duplicates
@@ -186,23 +129,50 @@ func (paths *PathList) TraverseCFGRecur(curr Wrapper, ssaInts map[string]int,
 			if !contained {
 
 				stmts = append(stmts, condition)
-				if isNegated && currWrapper.GetLabel() == MustNot{
-					pathLabels = append(pathLabels, Must)
-				}else if isNegated && currWrapper.GetLabel() == Must{
-					pathLabels = append(pathLabels, MustNot)
-				}else if !isNegated{
-					pathLabels = append(pathLabels, currWrapper.GetLabel())
+				//the condition held as it did if the branch
+				//the path went through ran
+				label := currWrapper.GetLabel()
+				if len(currWrapper.Succs) == 2 {
+					branch := currWrapper.Succs[0]
+					if fromElse {
+						branch = currWrapper.Succs[1]
+					}
+					label = branch.GetLabel()
 				}
+				pathLabels = append(pathLabels, label)
 			}
 		}
 	default:
 		fmt.Println("Default", currWrapper)
 	}
 
+	//At a loop header the path came around from the iteration before,
+	//through one of the latches, or entered the loop from before it
+	entering := loops
+	if header, ok := curr.(*BlockWrapper); ok && header.HeadOf != nil && loops.find(header.HeadOf) != -1 {
+		var links []ast.Node
+		loops, links = loops.resolveAtHeader(header.HeadOf)
+		stmts, pathLabels = appendMust(stmts, pathLabels, links)
+
+		if iteration := loops[loops.find(header.HeadOf)].iteration; iteration+1 < paths.LoopBound {
+			previous := loops.previousIteration(header.HeadOf)
+			for _, latch := range header.HeadOf.Latches {
+				paths.TraverseCFGRecur(latch, copyNodes(stmts), root, varFilter, copyLabels(pathLabels), false, previous, logs)
+			}
+		}
+
+		loops, links = loops.exit(header.HeadOf)
+		stmts, pathLabels = appendMust(copyNodes(stmts), copyLabels(pathLabels), links)
+	}
+
 	//If there are parent blocks to check, continue | otherwise add the path
 	if len(curr.GetParents()) != 0 {
 		//Go through each parent in the wrapper
 		for _, parent := range curr.GetParents() {
+			//the deferred calls after a recovered panic ran from it
+			if paths.Recovered != nil && parent != paths.Recovered && hasWrapper(curr.GetParents(), paths.Recovered) {
+				continue
+			}
 			// Determine if the next possible conditional should be negated or not
 			children := parent.GetChildren()
 			if len(children) == 2 {
@@ -212,9 +182,21 @@ func (paths *PathList) TraverseCFGRecur(curr Wrapper, ssaInts map[string]int,
 					fromElse = false
 				}
 			}
-			paths.TraverseCFGRecur(parent, ssaInts, stmts, root, varFilter, pathLabels, fromElse)
+			//a variable merged at the block has the version of the parent
+			parentStmts, parentLabels := stmts, pathLabels
+			if b, ok := curr.(*BlockWrapper); ok {
+				if links := b.phiLinks(parent); len(links) > 0 {
+					//the phis of a header are in the first iteration
+					parentStmts, parentLabels = appendMust(copyNodes(stmts), copyLabels(pathLabels), entering.renamedNodes(links))
+				}
+			}
+			paths.TraverseCFGRecur(parent, parentStmts, root, varFilter, parentLabels, fromElse, loops, logs)
 		}
 	} else {
+		//a deferred call only ran if its defer statement did
+		if !paths.defersRegistered() {
+			return
+		}
 
 		// the filter seems to be working but somehow vars
 		// gets 3 of the same thing (since there's 3 functions I guess)
@@ -230,17 +212,9 @@ func (paths *PathList) TraverseCFGRecur(curr Wrapper, ssaInts map[string]int,
 			}
 		}
 
-		//Make shallow copy of nodes so the conditions/labels don't get changed after recursive processing (only used in printing)
-		copiedNodes := []ast.Node{}
-		for _, st := range stmts{
-			copiedNodes = append(copiedNodes, st)
-		}
-		copiedLabels := []ExecutionLabel{}
-		for _, lbl := range pathLabels{
-			copiedLabels = append(copiedLabels, lbl)
-		}
-
-		paths.AddNewPath(Path{Expressions: stmts, ExecStatus: pathLabels, DidExecute: pthLbl, CopyExpressions: copiedNodes, CopyExecStatus: copiedLabels})
+		//the siblings of the path keep appending to the same slices
+		stmts, pathLabels = appendMust(copyNodes(stmts), copyLabels(pathLabels), mapConstraints(stmts))
+		paths.AddNewPath(Path{Expressions: stmts, ExecStatus: pathLabels, DidExecute: pthLbl, Logs: logs})
 	}
 
 }
@@ -283,15 +257,7 @@ func NewFnWrapper(root ast.Node, callingArgs []ast.Expr) *FnWrapper {
 	params := make([]*ast.Object, 0)
 	switch fn := root.(type) {
 	case *ast.FuncDecl:
-		c = cfg.New(fn.Body, func(call *ast.CallExpr) bool {
-			if call != nil {
-				// Functions that won't potentially cause the program will return.
-				if fn.Name.Name != "Exit" && !strings.Contains(fn.Name.Name, "Fatal") && fn.Name.Name != "panic" {
-					return true
-				}
-			}
-			return false
-		})
+		c = cfg.New(fn.Body, mayReturn)
 
 		//fset := token.NewFileSet()
 		//fmt.Println("Blocks", c.Format(fset))
@@ -304,9 +270,7 @@ func NewFnWrapper(root ast.Node, callingArgs []ast.Expr) *FnWrapper {
 			}
 		}
 	case *ast.FuncLit:
-		c = cfg.New(fn.Body, func(call *ast.CallExpr) bool {
-			return true
-		})
+		c = cfg.New(fn.Body, mayReturn)
 		for _, param := range fn.Type.Params.List {
 			for _, name := range param.Names {
 				params = append(params, name.Obj)
@@ -336,10 +300,34 @@ func NewFnWrapper(root ast.Node, callingArgs []ast.Expr) *FnWrapper {
 // NewBlockWrapper creates a wrapper around a `*cfg.Block` which points to
 // the outer `Wrapper`
 func NewBlockWrapper(block *cfg.Block, parent Wrapper, outer Wrapper) *BlockWrapper {
-	return newBlockWrapper(block, parent, outer, make(map[*cfg.Block]*BlockWrapper))
+	backEdges := findBackEdges(block)
+	cache := make(map[*cfg.Block]*BlockWrapper)
+	b := newBlockWrapper(block, parent, outer, cache, backEdges)
+
+	//switch and select tests know which case they test for
+	if fn, ok := outer.(*FnWrapper); ok {
+		findCases(fnBody(fn.Fn), cache)
+	}
+
+	//back edges become loops instead of successors
+	for _, edge := range backEdges {
+		header, latch := cache[edge.to], cache[edge.from]
+		if header.HeadOf == nil {
+			header.HeadOf = &Loop{Header: header}
+		}
+		found := false
+		for _, l := range latch.LatchOf {
+			found = found || l == header.HeadOf
+		}
+		if !found {
+			header.HeadOf.Latches = append(header.HeadOf.Latches, latch)
+			latch.LatchOf = append(latch.LatchOf, header.HeadOf)
+		}
+	}
+	return b
 }
 
-func newBlockWrapper(block *cfg.Block, parent Wrapper, outer Wrapper, cache map[*cfg.Block]*BlockWrapper) *BlockWrapper {
+func newBlockWrapper(block *cfg.Block, parent Wrapper, outer Wrapper, cache map[*cfg.Block]*BlockWrapper, backEdges []backEdge) *BlockWrapper {
 	//Avoid duplicate blocks
 	if b, ok := cache[block]; ok {
 		b.AddParent(parent)
@@ -352,31 +340,23 @@ func newBlockWrapper(block *cfg.Block, parent Wrapper, outer Wrapper, cache map[
 		Outer:   outer,
 		Parents: make([]Wrapper, 0),
 	}
+	cache[block] = b
 
 	if parent != nil {
 		b.AddParent(parent)
 	}
 
-	// Don't recurse on these otherwise this will infinitely loop
-	if !strings.Contains(block.String(), "for.post") && !strings.Contains(block.String(), "range.body") {
-		for _, succ := range block.Succs {
-			succBlock := newBlockWrapper(succ, b, outer, cache)
-			cache[succ] = succBlock
-			b.AddChild(succBlock)
-		}
-
-		// Handle loops by manually grabbing the cached for.post or range.body
-		if strings.Contains(b.Block.String(), "range.loop") {
-			if body, ok := cache[block.Succs[0]]; ok {
-				body.AddChild(b)
-				b.AddParent(body)
-			}
-		} else if strings.Contains(b.Block.String(), "for.loop") {
-			if post, ok := cache[block.Succs[0].Succs[0]]; ok {
-				post.AddChild(b)
-				b.AddParent(post)
+	for _, succ := range block.Succs {
+		isBackEdge := false
+		for _, edge := range backEdges {
+			if edge.from == block && edge.to == succ {
+				isBackEdge = true
+				break
 			}
 		}
+		if !isBackEdge {
+			b.AddChild(newBlockWrapper(succ, b, outer, cache, backEdges))
+		}
 	}
 
 	return b
@@ -386,6 +366,9 @@ func newBlockWrapper(block *cfg.Block, parent Wrapper, outer Wrapper, cache map[
 //by adding in function calls, should be called
 //from the root with an empty stack
 func ExpandCFG(w Wrapper) {
+	if fn, ok := w.(*FnWrapper); ok {
+		fn.spliceDefers()
+	}
 	ExpandCFGRecur(w, make([]*FnWrapper, 0))
 }
 
@@ -393,18 +376,15 @@ func ExpandCFGRecur(w Wrapper, stack []*FnWrapper) {
 	if w != nil {
 		switch b := w.(type) {
 		case *FnWrapper:
-			//if function has not been seen in current
-			//branch, expand it, otherwise, skip, because
-			//it would recurse infinitely
-			found := false
-			for _, frame := range stack {
-				if frame.Fn == b.Fn {
-					found = true
-					break
-				}
-			}
-			if !found {
+			//summaries and functions past the limits
+			//aren't expanded, recursion would never stop
+			if !b.summarized && b.shouldExpand(stack) {
 				ExpandCFGRecur(b.FirstBlock, append(stack, b))
+			} else if b.FirstBlock != nil {
+				//the summary returns to the rest of the caller
+				for _, succ := range b.FirstBlock.GetChildren() {
+					ExpandCFGRecur(succ, stack)
+				}
 			}
 		case *BlockWrapper:
 			//check if the next block is a FnWrapper
@@ -422,77 +402,95 @@ func ExpandCFGRecur(w Wrapper, stack []*FnWrapper) {
 			if shouldConnect {
 				//For every node in the block
 				for i, node := range b.Block.Nodes {
-					//check if the node is a callExpr
-					var call *ast.CallExpr
-					ast.Inspect(node, func(node ast.Node) bool {
-						if node, ok := node.(*ast.CallExpr); ok {
-							call = node
-							return false
-						}
-						if _, ok := node.(*ast.FuncLit); ok {
-							return false
-						}
-						return true
-					})
-					// switch node := node.(type) {
-					// case *ast.CallExpr:
-					// 	call = node
-					// case *ast.ExprStmt:
-					// 	if x, ok := node.X.(*ast.CallExpr); ok {
-					// 		call = x
-					// 	}
-					// }
-
-					if call != nil {
-						//get arguments
-						//split the block into two pieces
-						topBlock, bottomBlock := b.splitAtNodeIndex(i)
-
-						//TODO:
+					//every call of the node gets its own wrapper,
+					//in the order they are evaluated
+					fns := make([]*FnWrapper, 0)
+					for _, call := range b.callsInOrder(node) {
 						newFn := GetDeclarationOfFunction(b.Outer, call, call.Args)
-
-						//get new function wrapper
 						if newFn != nil {
+							newFn.Call = call
 							newFn.SetOuterWrapper(b.Outer)
-
-							//connect the topBlock to the function
-							topBlock.connectCallTo(newFn)
-							//replace block with topBlock
-							for _, p := range b.Parents {
-								//remove block as child?
-								p.RemoveChild(b)
-								b.RemoveParent(p)
-								p.AddChild(topBlock)
-								topBlock.AddParent(p)
-							}
-
-							if bottomBlock != nil {
-								//connect the function to the
-								//second half of the block
-								newFn.connectReturnsTo(bottomBlock)
-								//replace block with bottomBlock
-								for _, c := range b.Succs {
-									//remove block as parent?
-									b.RemoveChild(c)
-									c.RemoveParent(b)
-									c.AddParent(bottomBlock)
-									bottomBlock.AddChild(c)
-								}
+							if newFn.shouldExpand(stack) {
+								newFn.spliceDefers()
 							} else {
-								for _, c := range b.Succs {
-									b.RemoveChild(c)
-									c.RemoveParent(b)
-									newFn.connectReturnsTo(c)
-								}
-							}
-							//stop after first function, block is now
-							//obsolete, move on to sucessors of topBlock
-							for _, succ := range topBlock.Succs {
-								ExpandCFGRecur(succ, stack)
+								newFn.summarize()
 							}
+							fns = append(fns, newFn)
+						}
+					}
+					if len(fns) == 0 {
+						continue
+					}
+
+					//split the block into two pieces
+					topBlock, bottomBlock := b.splitAtNodeIndex(i)
+					first, last := fns[0], fns[len(fns)-1]
+
+					//the halves take over the block's place in loops
+					if b.HeadOf != nil {
+						topBlock.HeadOf = b.HeadOf
+						b.HeadOf.Header = topBlock
+					}
+					//the second half holds the test of a case
+					if bottomBlock != nil {
+						bottomBlock.Case = b.Case
+					}
+					for _, l := range b.LatchOf {
+						if bottomBlock != nil {
+							l.replaceLatch(b, bottomBlock)
+						} else {
+							l.replaceLatch(b, GetLeafNodes(last)...)
 						}
+					}
+
+					//connect the topBlock to the first function, and
+					//every function to the one called after it
+					topBlock.connectCallTo(first)
+					for j := 1; j < len(fns); j++ {
+						between := &BlockWrapper{
+							Block:   &cfg.Block{},
+							Parents: make([]Wrapper, 0),
+							Succs:   make([]Wrapper, 0),
+							Outer:   b.Outer,
+						}
+						fns[j-1].connectReturnsTo(between)
+						between.connectCallTo(fns[j])
+					}
 
+					//replace block with topBlock
+					for _, p := range b.Parents {
+						//keep the block's place among the successors,
+						//the first one is the true branch
+						replaceChild(p, b, topBlock)
+						b.RemoveParent(p)
+						topBlock.AddParent(p)
 					}
+
+					if bottomBlock != nil {
+						//connect the last function to the
+						//second half of the block
+						last.connectReturnsTo(bottomBlock)
+						//replace block with bottomBlock
+						for _, c := range b.Succs {
+							//remove block as parent?
+							b.RemoveChild(c)
+							c.RemoveParent(b)
+							c.AddParent(bottomBlock)
+							bottomBlock.AddChild(c)
+						}
+					} else {
+						for _, c := range b.Succs {
+							b.RemoveChild(c)
+							c.RemoveParent(b)
+							last.connectReturnsTo(c)
+						}
+					}
+					//stop after the first node with calls, block is now
+					//obsolete, move on to sucessors of topBlock
+					for _, succ := range topBlock.Succs {
+						ExpandCFGRecur(succ, stack)
+					}
+					break
 				}
 			}
 			//did not find any function calls in this block,
@@ -515,6 +513,10 @@ func GetDeclarationOfFunction(w Wrapper, fn ast.Expr, args []ast.Expr) *FnWrappe
 	//if in map, get declaration
 	switch v := fn.(type) {
 	case *ast.CallExpr:
+		//immediately invoked closures
+		if lit, ok := v.Fun.(*ast.FuncLit); ok {
+			return NewFnWrapper(lit, args)
+		}
 		if fnName, ok := v.Fun.(*ast.Ident); ok {
 			//this is when it is in the map
 			if param, ok := w.(*FnWrapper).ParamsToArgs[fnName.Obj]; ok {
@@ -525,11 +527,18 @@ func GetDeclarationOfFunction(w Wrapper, fn ast.Expr, args []ast.Expr) *FnWrappe
 					//identifier
 					return GetDeclarationOfFunction(w.GetOuterWrapper(), param, args)
 				}
-			} else {
+			} else if lit := storedClosure(fnName); lit != nil {
+				//closures stored in a variable
+				return NewFnWrapper(lit, args)
+			} else if decl := w.(*FnWrapper).FirstBlock.(*BlockWrapper).GetFunctionWrapperFor(fn.(*ast.CallExpr), args); decl != nil {
 				//if not a parameter, find it using blind method
-				return w.(*FnWrapper).FirstBlock.(*BlockWrapper).GetFunctionWrapperFor(fn.(*ast.CallExpr), args)
+				return decl
 			}
 		}
+		//functions outside of the project
+		if summary, ok := Summaries.Lookup(v); ok {
+			return summary.wrap(w.(*FnWrapper), v)
+		}
 	case *ast.Ident:
 		//add case for when the ientifier is nested
 		//search the params map again
@@ -542,14 +551,15 @@ func GetDeclarationOfFunction(w Wrapper, fn ast.Expr, args []ast.Expr) *FnWrappe
 				return GetDeclarationOfFunction(w.GetOuterWrapper(), param, args)
 			}
 		}
-		switch decl := v.Obj.Decl.(type) {
 		//local functions (foo := func())
-		case *ast.AssignStmt:
-			return NewFnWrapper(decl.Rhs[0].(*ast.FuncLit), args)
+		if lit := storedClosure(v); lit != nil {
+			return NewFnWrapper(lit, args)
+		}
 		//package functions (func foo())
-		case *ast.FuncDecl:
-			return NewFnWrapper(decl.Body, args)
-
+		if v.Obj != nil {
+			if decl, ok := v.Obj.Decl.(*ast.FuncDecl); ok {
+				return NewFnWrapper(decl, args)
+			}
 		}
 	}
 	return nil
@@ -604,6 +614,51 @@ func (b *BlockWrapper) splitAtNodeIndex(ndx int) (first, second *BlockWrapper) {
 	}
 }
 
+//Calls of the node in the order they are evaluated, the calls in the
+//arguments of a call come before it
+func (b *BlockWrapper) callsInOrder(node ast.Node) []*ast.CallExpr {
+	calls := make([]*ast.CallExpr, 0)
+	stack := make([]ast.Node, 0)
+	ast.Inspect(node, func(node ast.Node) bool {
+		if node == nil {
+			if call, ok := stack[len(stack)-1].(*ast.CallExpr); ok {
+				calls = append(calls, call)
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			return false
+		//deferred calls run when the function exits
+		case *ast.DeferStmt:
+			return false
+		//goroutines are forks, only the traced one is followed
+		case *ast.GoStmt:
+			if !b.startsTracedGoroutine(node) {
+				return false
+			}
+		}
+		stack = append(stack, node)
+		return true
+	})
+	return calls
+}
+
+//Replaces a child without changing the order of the successors
+func replaceChild(p Wrapper, old Wrapper, new Wrapper) {
+	if p, ok := p.(*BlockWrapper); ok {
+		for i, succ := range p.Succs {
+			if succ == old {
+				p.Succs[i] = new
+				return
+			}
+		}
+	}
+	p.RemoveChild(old)
+	p.AddChild(new)
+}
+
 func (b *BlockWrapper) connectCallTo(fn *FnWrapper) {
 	b.AddChild(fn)
 	fn.AddParent(b)
@@ -639,7 +694,8 @@ func GetLeafNodes(w Wrapper) []Wrapper {
 					rets = append(rets, leaf)
 				}
 			}
-		} else {
+		} else if b, ok := c.(*BlockWrapper); !ok || len(b.LatchOf) == 0 {
+			//latches go back to their loop instead of returning
 			rets = append(rets, c)
 		}
 	}
@@ -680,6 +736,20 @@ func (b *BlockWrapper) GetFunctionWrapperFor(node *ast.CallExpr, args []ast.Expr
 }
 
 func FindPanicWrapper(w Wrapper, traceStruct *helper.StackTraceStruct) Wrapper {
+	return findFrameWrapper(w, traceStruct, 0)
+}
+
+//FindRecoveredWrapper finds the block of the panic a deferred function
+//recovered before panicking again, the frame under the recovering one
+func FindRecoveredWrapper(w Wrapper, traceStruct *helper.StackTraceStruct) Wrapper {
+	if len(traceStruct.LineNum) < 2 {
+		return nil
+	}
+	return findFrameWrapper(w, traceStruct, 1)
+}
+
+//Block with a node on the line of the frame of the trace
+func findFrameWrapper(w Wrapper, traceStruct *helper.StackTraceStruct, frame int) Wrapper {
 	if w != nil {
 		switch w := w.(type) {
 		case *FnWrapper:
@@ -693,8 +763,8 @@ func FindPanicWrapper(w Wrapper, traceStruct *helper.StackTraceStruct) Wrapper {
 					continue
 				}
 
-				if strings.Contains(pos.Filename, traceStruct.FileName[0]) {
-					lineNum, err := strconv.Atoi(traceStruct.LineNum[0])
+				if strings.Contains(pos.Filename, traceStruct.FileName[frame]) {
+					lineNum, err := strconv.Atoi(traceStruct.LineNum[frame])
 					if err == nil && pos.Line == lineNum {
 						return w
 					}
@@ -703,7 +773,7 @@ func FindPanicWrapper(w Wrapper, traceStruct *helper.StackTraceStruct) Wrapper {
 			}
 		}
 		for _, child := range w.GetChildren() {
-			ret := FindPanicWrapper(child, traceStruct)
+			ret := findFrameWrapper(child, traceStruct, frame)
 			if ret != nil {
 				return ret
 			}
@@ -3,8 +3,10 @@ package cfg
 import (
 	"go/ast"
 	"go/token"
+	"sourcecrawler/app/helper"
 
 	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/ssa"
 )
 
 //---- Branch Labels ----------
@@ -49,6 +51,19 @@ type FnWrapper struct {
 	Fset         *token.FileSet
 	ASTs         []*ast.File
 	ParamsToArgs map[*ast.Object]ast.Expr
+	Call         *ast.CallExpr            //call the function is expanded at, nil for the function sliced
+	MaxCallDepth int                      //calls deeper than this off the stack trace are summarized, 0 is no limit
+	CallContext  int                      //clones of a function in one call chain, recursion deeper than this is summarized
+	CreatedBy    *helper.GoroutineOrigin  //go statement the traced goroutine started at, other go statements aren't expanded
+	Trace        *helper.StackTraceStruct //calls along the stack trace are expanded past the depth limit
+	Library      *LibraryFunction         //summary the wrapper stands in for, its body isn't in the project
+	Panics       ast.Expr                 //condition the library function panics under at this call
+	panics       ast.Expr                 //Panics in ssa form
+	bound        map[string]ast.Expr      //arguments and results of the library function at this call
+	SSAFn        *ssa.Function            //set when the graph is built from golang.org/x/tools/go/ssa
+	name         string
+	summaries    map[ast.Node]*cfg.Block //summaries of the callees, shared by their call sites
+	summarized   bool                    //the body is replaced by the summary
 	//PathList PathList
 }
 
@@ -58,6 +73,11 @@ type BlockWrapper struct {
 	Succs   []Wrapper
 	Outer   Wrapper
 	Label   ExecutionLabel
+	HeadOf  *Loop          //set when the block is the header of a loop
+	LatchOf []*Loop        //loops the block jumps back to the header of
+	Case    *SwitchCase    //set when the block tests for a case of a switch or select
+	SSA     *SSABlock      //set when the graph is converted to ssa form
+	Defer   *ast.DeferStmt //set when the block runs the deferred call on the way out
 	//PathList PathList
 }
 
@@ -134,6 +154,58 @@ func (fn *FnWrapper) GetFileSet() *token.FileSet {
 	}
 }
 
+//must always be defined by the outermost wrapper
+func (fn *FnWrapper) GetMaxCallDepth() int {
+	if fn.MaxCallDepth != 0 {
+		return fn.MaxCallDepth
+	}
+	for outer := fn.Outer; outer != nil; outer = outer.GetOuterWrapper() {
+		if outer, ok := outer.(*FnWrapper); ok {
+			return outer.GetMaxCallDepth()
+		}
+	}
+	return 0
+}
+
+//must always be defined by the outermost wrapper
+func (fn *FnWrapper) GetCallContext() int {
+	if fn.CallContext != 0 {
+		return fn.CallContext
+	}
+	for outer := fn.Outer; outer != nil; outer = outer.GetOuterWrapper() {
+		if outer, ok := outer.(*FnWrapper); ok {
+			return outer.GetCallContext()
+		}
+	}
+	return 1
+}
+
+//must always be defined by the outermost wrapper
+func (fn *FnWrapper) GetTrace() *helper.StackTraceStruct {
+	if fn.Trace != nil {
+		return fn.Trace
+	}
+	for outer := fn.Outer; outer != nil; outer = outer.GetOuterWrapper() {
+		if outer, ok := outer.(*FnWrapper); ok {
+			return outer.GetTrace()
+		}
+	}
+	return nil
+}
+
+//must always be defined by the outermost wrapper
+func (fn *FnWrapper) GetCreatedBy() *helper.GoroutineOrigin {
+	if fn.CreatedBy != nil {
+		return fn.CreatedBy
+	}
+	for outer := fn.Outer; outer != nil; outer = outer.GetOuterWrapper() {
+		if outer, ok := outer.(*FnWrapper); ok {
+			return outer.GetCreatedBy()
+		}
+	}
+	return nil
+}
+
 //must always be defined by the outermost wrapper
 func (fn *FnWrapper) GetASTs() []*ast.File {
 	if fn.ASTs != nil {
@@ -0,0 +1,138 @@
+package cfg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+//Name of the function in variable names: declarations go by their name
+//and literals by the function they are in plus their position, so two
+//closures in one function don't collide (Handler.lit19:8)
+func (fn *FnWrapper) Name() string {
+	if fn.name != "" {
+		return fn.name
+	}
+
+	switch f := fn.Fn.(type) {
+	case *ast.FuncDecl:
+		fn.name = f.Name.Name
+	case *ast.FuncLit:
+		fset := fn.GetFileSet()
+		if fset == nil {
+			return "lit"
+		}
+		fn.name = funcNameAt(fn.GetASTs(), fset, f.Pos())
+		if fn.name == "" {
+			//not in the parsed files, go by the function it is called from
+			enclosing := "lit"
+			for outer := fn.Outer; outer != nil; outer = outer.GetOuterWrapper() {
+				if outer, ok := outer.(*FnWrapper); ok && outer.Fn != nil {
+					enclosing = outer.Name()
+					break
+				}
+			}
+			fn.name = litName(enclosing, fset, f)
+		}
+	}
+	return fn.name
+}
+
+func litName(enclosing string, fset *token.FileSet, lit *ast.FuncLit) string {
+	pos := fset.Position(lit.Pos())
+	return fmt.Sprintf("%s.lit%d:%d", enclosing, pos.Line, pos.Column)
+}
+
+//funcNameAt names the innermost function around pos, empty when pos is
+//outside of every function
+func funcNameAt(files []*ast.File, fset *token.FileSet, pos token.Pos) string {
+	name := ""
+	for _, file := range files {
+		if pos < file.Pos() || pos >= file.End() {
+			continue
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			if node == nil || pos < node.Pos() || pos >= node.End() {
+				return false
+			}
+			switch fn := node.(type) {
+			case *ast.FuncDecl:
+				name = fn.Name.Name
+			case *ast.FuncLit:
+				name = litName(name, fset, fn)
+			}
+			return true
+		})
+	}
+	return name
+}
+
+//Closures share the variables they capture with the function that
+//declares them, so those are named after that function
+func (fn *FnWrapper) varPrefix(id *ast.Ident) string {
+	name := fn.Name()
+	lit, ok := fn.Fn.(*ast.FuncLit)
+	if !ok || id.Obj == nil {
+		return name
+	}
+	decl, ok := id.Obj.Decl.(ast.Node)
+	if !ok || (decl.Pos() >= lit.Pos() && decl.Pos() < lit.End()) {
+		return name
+	}
+	if fset := fn.GetFileSet(); fset != nil {
+		if declaring := funcNameAt(fn.GetASTs(), fset, decl.Pos()); declaring != "" {
+			return declaring
+		}
+	}
+	return name
+}
+
+//qualified is the name of the identifier prefixed with the function it belongs to
+func (fn *FnWrapper) qualified(id *ast.Ident) string {
+	prefix := fn.varPrefix(id)
+	if strings.Contains(id.Name, prefix+".") {
+		return id.Name
+	}
+	return fmt.Sprint(prefix, ".", id.Name)
+}
+
+//Closure stored in the variable, nil if it holds something else
+func storedClosure(id *ast.Ident) *ast.FuncLit {
+	if id.Obj == nil {
+		return nil
+	}
+	var value ast.Expr
+	switch decl := id.Obj.Decl.(type) {
+	case *ast.AssignStmt:
+		for i, lhs := range decl.Lhs {
+			if lhs, ok := lhs.(*ast.Ident); ok && lhs.Obj == id.Obj && i < len(decl.Rhs) {
+				value = decl.Rhs[i]
+			}
+		}
+	case *ast.ValueSpec:
+		for i, name := range decl.Names {
+			if name.Obj == id.Obj && i < len(decl.Values) {
+				value = decl.Values[i]
+			}
+		}
+	}
+	lit, _ := value.(*ast.FuncLit)
+	return lit
+}
+
+//Whether the go statement started the goroutine of the stack trace,
+//other goroutines run on their own and aren't part of the slice
+func (b *BlockWrapper) startsTracedGoroutine(stmt *ast.GoStmt) bool {
+	fn, ok := b.Outer.(*FnWrapper)
+	if !ok {
+		return false
+	}
+	origin := fn.GetCreatedBy()
+	fset := b.GetFileSet()
+	if origin == nil || fset == nil {
+		return false
+	}
+	pos := fset.Position(stmt.Pos())
+	return strings.HasSuffix(pos.Filename, origin.FileName) && fmt.Sprint(pos.Line) == origin.LineNum
+}
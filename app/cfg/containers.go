@@ -0,0 +1,392 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//---------- Maps and channels in the constraints --------------
+//An element of a map is a variable of its own named after the map and the
+//key (F.m[F.k]), so is whether the map has the key (F.k in F.m). Writes
+//and deletes give a new version of the map. A channel is the number of
+//values in its buffer and its capacity (len(F.ch), cap(F.ch)), sends and
+//receives give a new version of it with one more or one less value.
+//Go-z3 has no arrays, what they'd say of the elements are implications
+//between the keys of the path (mapConstraints).
+
+func isMap(expr ast.Expr) bool {
+	_, ok := typeOf(expr).(*ast.MapType)
+	return ok
+}
+
+func isChan(expr ast.Expr) bool {
+	_, ok := typeOf(expr).(*ast.ChanType)
+	return ok
+}
+
+//entry is the data of the variable of an element, or of whether the map
+//has the key. prev is the version of the map a write changed
+type entry struct {
+	root   *ast.Object //the variable holding the map
+	m, key ast.Expr
+	member bool
+	prev   ast.Expr
+}
+
+//Value the map has for the key, both already in ssa form
+func element(m, key ast.Expr) *ast.Ident {
+	var t ast.Expr
+	if mt, ok := typeOf(m).(*ast.MapType); ok {
+		t = mt.Value
+	}
+	return container(types.ExprString(m)+"["+types.ExprString(key)+"]", &entry{m: m, key: key}, t)
+}
+
+//Whether the map has the key, both already in ssa form
+func membership(m, key ast.Expr) *ast.Ident {
+	return container(types.ExprString(key)+" in "+types.ExprString(m), &entry{m: m, key: key, member: true}, ast.NewIdent("bool"))
+}
+
+//Variable of the map, its data is the entry
+func container(name string, e *entry, t ast.Expr) *ast.Ident {
+	e.root = rootObject(e.m)
+	obj := &ast.Object{Kind: ast.Var, Name: name, Decl: &ast.Field{Type: t}, Data: e}
+	return &ast.Ident{Name: name, NamePos: e.m.Pos(), Obj: obj}
+}
+
+//The variable of the entry of the map written, a new version of prev
+func written(id *ast.Ident, prev ast.Expr) *ast.Ident {
+	id.Obj.Data.(*entry).prev = prev
+	return id
+}
+
+//Variable of the entry for the same key in another version of the map
+func (e *entry) in(m ast.Expr, key ast.Expr) *ast.Ident {
+	if e.member {
+		return membership(m, key)
+	}
+	return element(m, key)
+}
+
+//Entry the identifier is the variable of, nil for other identifiers and
+//for the ones renamed since (the iterations of loops)
+func entryOf(id *ast.Ident) *entry {
+	if id.Obj == nil {
+		return nil
+	}
+	e, ok := id.Obj.Data.(*entry)
+	if !ok || id.Name != e.in(e.m, e.key).Name {
+		return nil
+	}
+	return e
+}
+
+//Whether the keys of the map have a sort the constraints can compare
+func comparableKeys(m ast.Expr) bool {
+	mt, ok := typeOf(m).(*ast.MapType)
+	if !ok {
+		return false
+	}
+	key, ok := mt.Key.(*ast.Ident)
+	return ok && (strings.Contains(key.Name, "int") || key.Name == "bool")
+}
+
+//mapConstraints are what the nodes of a path imply of the elements of the
+//maps it uses: elements (and whether the map has them) of equal keys are
+//equal, and a write or a delete leaves the other keys of the version of
+//the map it changes as they were, a copy (a phi) all of them. Every key
+//the path uses is in every version of the map it's linked to
+func mapConstraints(nodes []ast.Node) []ast.Node {
+	type change struct {
+		m, prev ast.Expr
+		key     ast.Expr //nil for a copy
+	}
+	maps := make(map[string]ast.Expr)
+	keys := make(map[string]map[string]ast.Expr)
+	use := func(m ast.Expr, key ast.Expr) {
+		name := types.ExprString(m)
+		maps[name] = m
+		if keys[name] == nil {
+			keys[name] = make(map[string]ast.Expr)
+		}
+		if key != nil {
+			keys[name][types.ExprString(key)] = key
+		}
+	}
+	changes := make([]change, 0)
+	for _, node := range nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if e := entryOf(id); e != nil && comparableKeys(e.m) {
+					use(e.m, e.key)
+					if e.prev != nil {
+						use(e.prev, nil)
+						changes = append(changes, change{m: e.m, prev: e.prev, key: e.key})
+					}
+				}
+			}
+			return true
+		})
+		//the version of a map at a merge is the one of the parent
+		if assign, ok := node.(*ast.AssignStmt); ok && len(assign.Lhs) == len(assign.Rhs) {
+			for i, lhs := range assign.Lhs {
+				if l, ok := lhs.(*ast.Ident); ok && isMap(l) && comparableKeys(l) {
+					if r, ok := unparen(assign.Rhs[i]).(*ast.Ident); ok && isMap(r) {
+						use(l, nil)
+						use(r, nil)
+						changes = append(changes, change{m: l, prev: r})
+					}
+				}
+			}
+		}
+	}
+
+	//the keys of a version are the ones of the versions it's linked to
+	for grown := true; grown; {
+		grown = false
+		for _, c := range changes {
+			from, to := keys[types.ExprString(c.m)], keys[types.ExprString(c.prev)]
+			for name, key := range from {
+				if _, ok := to[name]; !ok {
+					to[name], grown = key, true
+				}
+			}
+			for name, key := range to {
+				if _, ok := from[name]; !ok {
+					from[name], grown = key, true
+				}
+			}
+		}
+	}
+
+	constraints := make([]ast.Node, 0)
+	kinds := []*entry{{}, {member: true}}
+	names := make([]string, 0, len(maps))
+	for name := range maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sorted := sortedKeys(keys[name])
+		for i := range sorted {
+			for j := i + 1; j < len(sorted); j++ {
+				for _, kind := range kinds {
+					equal := &ast.BinaryExpr{X: sorted[i], Op: token.EQL, Y: sorted[j]}
+					same := &ast.BinaryExpr{X: kind.in(maps[name], sorted[i]), Op: token.EQL, Y: kind.in(maps[name], sorted[j])}
+					constraints = append(constraints, implication(equal, same))
+				}
+			}
+		}
+	}
+	for _, c := range changes {
+		for _, key := range sortedKeys(keys[types.ExprString(c.m)]) {
+			for _, kind := range kinds {
+				var kept ast.Expr = &ast.BinaryExpr{X: kind.in(c.m, key), Op: token.EQL, Y: kind.in(c.prev, key)}
+				if c.key != nil {
+					other := &ast.BinaryExpr{X: key, Op: token.NEQ, Y: c.key}
+					kept = implication(other, kept)
+				}
+				constraints = append(constraints, kept)
+			}
+		}
+	}
+	return constraints
+}
+
+//Keys in the order of their names
+func sortedKeys(keys map[string]ast.Expr) []ast.Expr {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := make([]ast.Expr, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, keys[name])
+	}
+	return sorted
+}
+
+//!(condition) || then
+func implication(condition, then ast.Expr) ast.Expr {
+	not := &ast.UnaryExpr{Op: token.NOT, X: &ast.ParenExpr{X: condition}}
+	return &ast.BinaryExpr{X: not, Op: token.LOR, Y: then}
+}
+
+func builtinCall(name string, arg ast.Expr) *ast.CallExpr {
+	return &ast.CallExpr{Fun: ast.NewIdent(name), Args: []ast.Expr{arg}}
+}
+
+//Channels the node receives from
+func receives(node ast.Node) []ast.Expr {
+	chans := make([]ast.Expr, 0)
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.UnaryExpr:
+			if node.Op == token.ARROW {
+				chans = append(chans, node.X)
+			}
+		}
+		return true
+	})
+	return chans
+}
+
+//Maps and channels the statement changes, other than by assigning to
+//an element
+func changedBy(node ast.Node) []ast.Expr {
+	switch node := node.(type) {
+	case *ast.SendStmt:
+		return append([]ast.Expr{node.Chan}, receives(node.Value)...)
+	case *ast.ExprStmt:
+		if call, ok := unparen(node.X).(*ast.CallExpr); ok && isBuiltin(call.Fun, "delete") && len(call.Args) == 2 {
+			return []ast.Expr{call.Args[0]}
+		}
+	}
+	return receives(node)
+}
+
+//Defines a new version of the variable or location the expression is,
+//nil when it's neither
+func (s *ssaBuilder) redefine(expr ast.Expr, fn *FnWrapper, versions map[string]*Value) (*ast.Ident, []*Value) {
+	if id, ok := expr.(*ast.Ident); ok {
+		value := s.assign(id, fn, versions)
+		return id, append([]*Value{value}, s.reassigned(value.Var, versions, id.Pos())...)
+	}
+	if name := s.location(expr, fn, versions, &s.derefs); name != "" {
+		obj := locationObj(name, expr)
+		s.locations[name] = obj
+		value := s.define(name, obj, expr.Pos())
+		versions[name] = value
+		id := &ast.Ident{Name: value.Name(), NamePos: expr.Pos(), Obj: obj}
+		return id, append([]*Value{value}, s.reassigned(name, versions, expr.Pos())...)
+	}
+	return nil, nil
+}
+
+//The buffer of the channel once a value is sent (token.ADD) or received
+//(token.SUB), as the assignments to its length and capacity
+func (s *ssaBuilder) buffered(ch ast.Expr, op token.Token, fn *FnWrapper, versions map[string]*Value) ([]ast.Expr, []ast.Expr, []*Value) {
+	before := s.convert(copyAST(ch), fn, versions).(ast.Expr)
+	after, defs := s.redefine(copyAST(ch).(ast.Expr), fn, versions)
+	if after == nil {
+		return nil, nil, nil
+	}
+	lhs := []ast.Expr{builtinCall("len", after), builtinCall("cap", after)}
+	one := &ast.BasicLit{Kind: token.INT, Value: "1"}
+	rhs := []ast.Expr{&ast.BinaryExpr{X: builtinCall("len", before), Op: op, Y: one}, builtinCall("cap", before)}
+	return lhs, rhs, defs
+}
+
+//Sends, receives and deletes as assignments: to the buffer of the
+//channel, or to whether the map has the key. Nil for other statements
+func (s *ssaBuilder) change(node ast.Node, fn *FnWrapper, versions map[string]*Value) *Instr {
+	instr := &Instr{Source: node, Defs: make([]*Value, 0)}
+	assign := &ast.AssignStmt{Tok: token.ASSIGN}
+	switch node := node.(type) {
+	case *ast.SendStmt:
+		s.convert(copyAST(node.Value), fn, versions)
+		lhs, rhs, defs := s.buffered(node.Chan, token.ADD, fn, versions)
+		assign.Lhs, assign.Rhs, instr.Defs = lhs, rhs, defs
+	case *ast.ExprStmt:
+		switch x := unparen(node.X).(type) {
+		case *ast.UnaryExpr:
+			if x.Op != token.ARROW {
+				return nil
+			}
+			lhs, rhs, defs := s.buffered(x.X, token.SUB, fn, versions)
+			assign.Lhs, assign.Rhs, instr.Defs = lhs, rhs, defs
+		case *ast.CallExpr:
+			if !isBuiltin(x.Fun, "delete") || len(x.Args) != 2 {
+				return nil
+			}
+			key := s.convert(copyAST(x.Args[1]), fn, versions).(ast.Expr)
+			prev := s.convert(copyAST(x.Args[0]), fn, versions).(ast.Expr)
+			m, defs := s.redefine(copyAST(x.Args[0]).(ast.Expr), fn, versions)
+			if m == nil {
+				return nil
+			}
+			assign.Lhs, assign.Rhs, instr.Defs = []ast.Expr{written(membership(m, key), prev)}, []ast.Expr{ast.NewIdent("false")}, defs
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+	if len(assign.Lhs) == 0 {
+		return nil
+	}
+	instr.Node = assign
+	return instr
+}
+
+//Lookup with the comma ok form (v, ok := m[k]), the map and key in ssa
+//form, nil for other assignments
+func (s *ssaBuilder) commaOk(assign *ast.AssignStmt, fn *FnWrapper, versions map[string]*Value) (ast.Expr, ast.Expr) {
+	if len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		return nil, nil
+	}
+	index, ok := unparen(assign.Rhs[0]).(*ast.IndexExpr)
+	if !ok || !isMap(index.X) {
+		return nil, nil
+	}
+	m := s.convert(index.X, fn, versions).(ast.Expr)
+	return m, s.convert(index.Index, fn, versions).(ast.Expr)
+}
+
+//The length and capacity of the channels made by the assignment, the
+//buffer is empty
+func made(assign *ast.AssignStmt) ([]ast.Expr, []ast.Expr) {
+	lhs, rhs := make([]ast.Expr, 0), make([]ast.Expr, 0)
+	for i, l := range assign.Lhs {
+		if i >= len(assign.Rhs) {
+			break
+		}
+		call, ok := unparen(assign.Rhs[i]).(*ast.CallExpr)
+		if !ok || !isBuiltin(call.Fun, "make") || len(call.Args) == 0 {
+			continue
+		}
+		if _, ok := call.Args[0].(*ast.ChanType); !ok {
+			continue
+		}
+		var capacity ast.Expr = &ast.BasicLit{Kind: token.INT, Value: "0"}
+		if len(call.Args) > 1 {
+			capacity = call.Args[1]
+		}
+		lhs = append(lhs, builtinCall("len", l), builtinCall("cap", l))
+		rhs = append(rhs, &ast.BasicLit{Kind: token.INT, Value: "0"}, capacity)
+	}
+	return lhs, rhs
+}
+
+//Length or capacity of the value, an integer of its own. A channel's
+//buffer holds from none to its capacity of values
+func (c *z3Converter) length(builtin string, arg ast.Expr) *z3.AST {
+	var bf bytes.Buffer
+	printer.Fprint(&bf, c.fset, arg)
+	length := c.ctx.Const(c.ctx.Symbol(fmt.Sprintf("%s(%s)", builtin, bf.String())), c.ctx.IntSort())
+	if isChan(arg) {
+		capacity := c.ctx.Const(c.ctx.Symbol(fmt.Sprintf("cap(%s)", bf.String())), c.ctx.IntSort())
+		if builtin == "len" {
+			c.defs = append(c.defs, length.Ge(c.integer(0)), length.Le(capacity))
+		} else {
+			c.defs = append(c.defs, capacity.Ge(c.integer(0)))
+		}
+	}
+	return length
+}
+
+//Whether the variable is a key being in a map (F.k in F.m)
+func isMembership(name string) bool {
+	return strings.Contains(name, " in ")
+}
@@ -0,0 +1,107 @@
+package cfg
+
+import (
+	"go/ast"
+	"strings"
+
+	"golang.org/x/tools/go/cfg"
+)
+
+//Whether the call gets its own copy of the function's graph. Calls along
+//the stack trace always do, recursion is cloned CallContext times per
+//call chain and calls past MaxCallDepth are summarized
+func (fn *FnWrapper) shouldExpand(stack []*FnWrapper) bool {
+	if fn.onTrace(stack) {
+		return true
+	}
+
+	clones := 0
+	for _, frame := range stack {
+		if frame.Fn == fn.Fn {
+			clones++
+		}
+	}
+	if clones >= fn.GetCallContext() {
+		return false
+	}
+	limit := fn.GetMaxCallDepth()
+	return limit == 0 || len(stack) < limit
+}
+
+//Whether the call chain down to the function is the one of the stack
+//trace, from the entry function (or the one starting the goroutine)
+func (fn *FnWrapper) onTrace(stack []*FnWrapper) bool {
+	trace := fn.GetTrace()
+	if trace == nil {
+		return false
+	}
+	chain := make([]string, 0, len(trace.FuncName)+1)
+	if origin := fn.GetCreatedBy(); origin != nil {
+		chain = append(chain, origin.FuncName)
+	}
+	for i := len(trace.FuncName) - 1; i >= 0; i-- {
+		chain = append(chain, trace.FuncName[i])
+	}
+
+	frames := append(append([]*FnWrapper{}, stack...), fn)
+	if len(frames) > len(chain) {
+		return false
+	}
+	for i, frame := range frames {
+		//stack traces name literals by the function they are in
+		if strings.SplitN(frame.Name(), ".", 2)[0] != chain[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//summarize replaces the body of the function by a single block holding
+//its log statements, calls in it aren't expanded
+func (fn *FnWrapper) summarize() {
+	if fn.summarized {
+		return
+	}
+	fn.FirstBlock = &BlockWrapper{
+		Block:   fn.summary(),
+		Parents: []Wrapper{fn},
+		Succs:   make([]Wrapper, 0),
+		Outer:   fn,
+	}
+	fn.summarized = true
+}
+
+//Summarized tells if the body of the function is its summary
+func (fn *FnWrapper) Summarized() bool {
+	return fn.summarized
+}
+
+//Summary of the function, made once and shared by every call of it
+func (fn *FnWrapper) summary() *cfg.Block {
+	root := fn
+	for outer := fn.Outer; outer != nil; outer = outer.GetOuterWrapper() {
+		if outer, ok := outer.(*FnWrapper); ok {
+			root = outer
+		}
+	}
+	if root.summaries == nil {
+		root.summaries = make(map[ast.Node]*cfg.Block)
+	}
+	if block, ok := root.summaries[fn.Fn]; ok {
+		return block
+	}
+
+	block := &cfg.Block{Nodes: make([]ast.Node, 0)}
+	ast.Inspect(fnBody(fn.Fn), func(node ast.Node) bool {
+		if _, ok := node.(*ast.FuncLit); ok {
+			return false
+		}
+		if isLogStmt(node) {
+			block.Nodes = append(block.Nodes, node)
+			return false
+		}
+		return true
+	})
+	root.summaries[fn.Fn] = block
+	return block
+}
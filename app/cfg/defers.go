@@ -0,0 +1,173 @@
+package cfg
+
+import (
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/cfg"
+)
+
+//Calls that never return end their block, panics are exits of the function
+func mayReturn(call *ast.CallExpr) bool {
+	var name string
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		name = fn.Name
+	case *ast.SelectorExpr:
+		name = fn.Sel.Name
+	}
+	return name != "panic" && name != "Exit" && name != "Goexit" && !strings.Contains(name, "Fatal")
+}
+
+//spliceDefers runs the deferred calls of the function on its way out.
+//Every return and panic exit goes through the calls deferred before it,
+//the last one deferred first. Exits with the same deferred calls share
+//the wrappers of those calls, a path only goes through the ones whose
+//defer statement it ran
+func (fn *FnWrapper) spliceDefers() {
+	groups := make(map[string][]Wrapper)
+	deferred := make(map[string][]*ast.DeferStmt)
+	keys := make([]string, 0)
+	for _, exit := range GetLeafNodes(fn) {
+		defers := deferredBefore(exit, fn)
+		if len(defers) == 0 {
+			continue
+		}
+		key := fmt.Sprint(defers)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			deferred[key] = defers
+		}
+		groups[key] = append(groups[key], exit)
+	}
+
+	for _, key := range keys {
+		leaves := groups[key]
+		for _, stmt := range deferred[key] {
+			call := fn.deferredFunction(stmt.Call)
+			if call == nil {
+				continue
+			}
+
+			block := &BlockWrapper{
+				Block:   &cfg.Block{},
+				Parents: make([]Wrapper, 0),
+				Succs:   make([]Wrapper, 0),
+				Outer:   fn,
+				Defer:   stmt,
+			}
+			for _, leaf := range leaves {
+				leaf.AddChild(block)
+				block.AddParent(leaf)
+			}
+			block.connectCallTo(call)
+			leaves = GetLeafNodes(call)
+		}
+	}
+}
+
+//Whether the wrapper is in the list
+func hasWrapper(list []Wrapper, w Wrapper) bool {
+	for _, other := range list {
+		if other == w {
+			return true
+		}
+	}
+	return false
+}
+
+//Wrapper of a deferred call, nil when its declaration isn't found
+func (fn *FnWrapper) deferredFunction(call *ast.CallExpr) *FnWrapper {
+	var deferred *FnWrapper
+	if lit, ok := call.Fun.(*ast.FuncLit); ok {
+		deferred = NewFnWrapper(lit, call.Args)
+	} else if fn.FirstBlock != nil {
+		deferred = GetDeclarationOfFunction(fn, call, call.Args)
+	}
+	if deferred == nil {
+		return nil
+	}
+	deferred.Call = call
+	deferred.SetOuterWrapper(fn)
+
+	//a function deferring itself would never stop splicing
+	for outer := Wrapper(fn); outer != nil; outer = outer.GetOuterWrapper() {
+		if outer, ok := outer.(*FnWrapper); ok && outer.Fn == deferred.Fn {
+			return deferred
+		}
+	}
+	deferred.spliceDefers()
+	return deferred
+}
+
+//Counts the defer statements the block runs the call of on the way out,
+//or registers. The count is undone by the function returned
+func (paths *PathList) countDefers(b *BlockWrapper) func() {
+	if paths.deferred == nil {
+		paths.deferred = make(map[*ast.DeferStmt]int)
+		paths.registered = make(map[*ast.DeferStmt]int)
+	}
+	counted := make([]*ast.DeferStmt, 0)
+	if b.Defer != nil {
+		paths.deferred[b.Defer]++
+	}
+	for _, node := range b.Block.Nodes {
+		if stmt, ok := node.(*ast.DeferStmt); ok {
+			paths.registered[stmt]++
+			counted = append(counted, stmt)
+		}
+	}
+	return func() {
+		if b.Defer != nil {
+			paths.deferred[b.Defer]--
+		}
+		for _, stmt := range counted {
+			paths.registered[stmt]--
+		}
+	}
+}
+
+//Whether the path ran the defer statement of every deferred call it went
+//through
+func (paths *PathList) defersRegistered() bool {
+	for stmt, n := range paths.deferred {
+		if n > 0 && paths.registered[stmt] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//Defer statements of the function on the way to the exit, in the order
+//they run
+func deferredBefore(exit Wrapper, fn *FnWrapper) []*ast.DeferStmt {
+	defers := make([]*ast.DeferStmt, 0)
+	visited := make(map[Wrapper]bool)
+	stack := []Wrapper{exit}
+	for len(stack) > 0 {
+		curr := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[curr] {
+			continue
+		}
+		visited[curr] = true
+
+		b, ok := curr.(*BlockWrapper)
+		if !ok || b.Outer != fn {
+			continue
+		}
+		for _, node := range b.Block.Nodes {
+			if stmt, ok := node.(*ast.DeferStmt); ok {
+				defers = append(defers, stmt)
+			}
+		}
+		stack = append(stack, b.Parents...)
+	}
+
+	sort.Slice(defers, func(i, j int) bool {
+		return defers[i].Pos() > defers[j].Pos()
+	})
+	return defers
+}
@@ -0,0 +1,515 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//---------- Requests of http handlers --------------
+//A handler is an entry function taking an http.Request. Its inputs are
+//the parts of the request they are read from: a key of the JSON body
+//decoded into a struct, a URL variable of gorilla/mux, a query value or a
+//header. The values the solver finds for them make a request reproducing
+//the path, sent to the route the handler is registered at.
+
+//IsHandler is whether the function takes an http.Request
+func IsHandler(fnType *ast.FuncType) bool {
+	if fnType == nil || fnType.Params == nil {
+		return false
+	}
+	for _, field := range fnType.Params.List {
+		if isRequest(field.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+//Endpoint is the method and path a handler is registered at, the path
+//has the URL variables of mux ({id})
+type Endpoint struct {
+	Method string
+	Path   string
+}
+
+//Functions and methods registering handlers by their path, the ones named
+//after a method only route it
+var registrations = map[string]string{
+	"HandleFunc": "", "Handle": "",
+	"Get": "GET", "Post": "POST", "Put": "PUT", "Delete": "DELETE", "Patch": "PATCH",
+}
+
+//FindEndpoint is where the named handler is registered in the files, nil
+//when it isn't
+func FindEndpoint(files []*ast.File, name string) *Endpoint {
+	//routes restricted to methods, r.HandleFunc("/", h).Methods("POST")
+	methods := make(map[*ast.CallExpr]string)
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "Methods" {
+				return true
+			}
+			if route, ok := unparen(sel.X).(*ast.CallExpr); ok {
+				methods[route] = stringLit(call.Args[0])
+			}
+			return true
+		})
+	}
+
+	var endpoint *Endpoint
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || endpoint != nil || len(call.Args) < 2 {
+				return endpoint == nil
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			method, ok := registrations[sel.Sel.Name]
+			path := stringLit(call.Args[0])
+			if !ok || path == "" || !mentions(call.Args[1:], name) {
+				return true
+			}
+			if m, ok := methods[call]; ok && m != "" {
+				method = m
+			}
+			endpoint = &Endpoint{Method: method, Path: path}
+			return false
+		})
+		if endpoint != nil {
+			break
+		}
+	}
+	return endpoint
+}
+
+//Whether the expressions use the function, by itself or as a member of
+//a package (handler.Create)
+func mentions(exprs []ast.Expr, name string) bool {
+	found := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.Ident:
+				found = found || node.Name == name
+			case *ast.SelectorExpr:
+				found = found || node.Sel.Name == name
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+//Value of a string literal, empty for other expressions
+func stringLit(expr ast.Expr) string {
+	lit, ok := unparen(expr).(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+//Parts of a request a value is read from
+const (
+	BodyPart   = "body"
+	PathPart   = "path"
+	QueryPart  = "query"
+	HeaderPart = "header"
+)
+
+//RequestValue is where in the request an input is read from, the keys of
+//the JSON body down to the value or the name of the URL variable, query
+//value or header. Calls is how many calls the value went through to reach
+//the input
+type RequestValue struct {
+	Part  string
+	Keys  []string
+	Calls int
+}
+
+//Calls parsing their first argument, the value is the same input
+var parseCalls = map[string]bool{
+	"strconv.Atoi": true, "strconv.ParseInt": true, "strconv.ParseUint": true,
+	"strconv.ParseBool": true, "strconv.ParseFloat": true,
+}
+
+//Methods of a request reading the query or the form, r.FormValue("n")
+var queryMethods = map[string]bool{"FormValue": true, "PostFormValue": true}
+
+//RequestValues are the parts of the request the inputs of the assignments
+//are read from, inputs read from elsewhere aren't in it
+func RequestValues(block Wrapper, nodes []ast.Node, assignments map[string]*z3.AST) map[string]RequestValue {
+	t := newTaint(block)
+	idents, _ := pathVariables(nodes)
+	values := make(map[string]RequestValue)
+	for name, kind := range InputSources(block, nodes, assignments) {
+		if kind != JSON && kind != Request {
+			continue
+		}
+		variable, _ := inputVariable(name)
+		id, ok := idents[variable]
+		if !ok {
+			continue
+		}
+		root := rootObject(id)
+		if root == nil {
+			continue
+		}
+		value, ok := t.request(&ast.Ident{Name: root.Name, Obj: root}, make(map[*ast.Object]bool))
+		if !ok {
+			continue
+		}
+		//fields of the variable (Create.order.Qty)
+		if root != id.Obj {
+			fields, ok := fieldsOf(variable, root)
+			if !ok || value.Part != BodyPart {
+				continue
+			}
+			value.Keys = append(value.Keys, jsonKeys(typeOf(&ast.Ident{Name: root.Name, Obj: root}), fields)...)
+			if len(value.Keys) == 0 {
+				continue
+			}
+		}
+		values[name] = value
+	}
+	return values
+}
+
+//Fields of a location below the variable holding it, false for pointed
+//values and elements
+func fieldsOf(location string, root *ast.Object) ([]string, bool) {
+	base := ssaBase(location)
+	i := strings.Index(base, "."+root.Name+".")
+	if i < 0 || strings.ContainsAny(base, "*[ ") {
+		return nil, false
+	}
+	return strings.Split(base[i+len(root.Name)+2:], "."), true
+}
+
+//Part of the request the expression is read from, following parameters
+//to their arguments and variables to their values
+func (t *taint) request(expr ast.Expr, seen map[*ast.Object]bool) (RequestValue, bool) {
+	switch expr := unparen(expr).(type) {
+	case *ast.Ident:
+		obj := expr.Obj
+		if obj == nil || obj.Kind != ast.Var || seen[obj] {
+			return RequestValue{}, false
+		}
+		seen[obj] = true
+		if t.targets[obj] == JSON {
+			return RequestValue{Part: BodyPart}, true
+		}
+		switch decl := obj.Decl.(type) {
+		case *ast.Field:
+			for _, fn := range t.params[obj] {
+				if arg, ok := fn.ParamsToArgs[obj]; ok {
+					if value, ok := t.request(arg, seen); ok {
+						value.Calls++
+						return value, true
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range decl.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Obj == obj {
+					if len(decl.Lhs) == len(decl.Rhs) {
+						return t.request(decl.Rhs[i], seen)
+					}
+					//the others are errors and flags (n, err := strconv.Atoi(s))
+					if i == 0 {
+						return t.request(decl.Rhs[0], seen)
+					}
+					return RequestValue{}, false
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range decl.Names {
+				if name.Obj == obj && i < len(decl.Values) {
+					return t.request(decl.Values[i], seen)
+				}
+			}
+		}
+	case *ast.SelectorExpr:
+		//a field of a struct the body is decoded into
+		fields := []string{expr.Sel.Name}
+		x := unparen(expr.X)
+		for {
+			if star, ok := x.(*ast.StarExpr); ok {
+				x = unparen(star.X)
+			} else if sel, ok := x.(*ast.SelectorExpr); ok {
+				fields = append([]string{sel.Sel.Name}, fields...)
+				x = unparen(sel.X)
+			} else {
+				break
+			}
+		}
+		value, ok := t.request(x, seen)
+		if !ok || value.Part != BodyPart {
+			return RequestValue{}, false
+		}
+		keys := jsonKeys(typeOf(x), fields)
+		if len(keys) != len(fields) {
+			return RequestValue{}, false
+		}
+		value.Keys = append(value.Keys, keys...)
+		return value, true
+	case *ast.IndexExpr:
+		//a URL variable, mux.Vars(r)["id"]
+		if key := stringLit(expr.Index); key != "" && t.urlVars(expr.X, seen) {
+			return RequestValue{Part: PathPart, Keys: []string{key}}, true
+		}
+	case *ast.CallExpr:
+		if parseCalls[callName(expr)] && len(expr.Args) > 0 {
+			return t.request(expr.Args[0], seen)
+		}
+		sel, ok := expr.Fun.(*ast.SelectorExpr)
+		if !ok || len(expr.Args) != 1 {
+			break
+		}
+		key := stringLit(expr.Args[0])
+		if key == "" {
+			break
+		}
+		if queryMethods[sel.Sel.Name] {
+			return RequestValue{Part: QueryPart, Keys: []string{key}}, true
+		}
+		if sel.Sel.Name != "Get" {
+			break
+		}
+		//r.URL.Query().Get("n")
+		if query, ok := unparen(sel.X).(*ast.CallExpr); ok {
+			if q, ok := query.Fun.(*ast.SelectorExpr); ok && q.Sel.Name == "Query" {
+				return RequestValue{Part: QueryPart, Keys: []string{key}}, true
+			}
+		}
+		//r.Header.Get("X-Id")
+		if header, ok := unparen(sel.X).(*ast.SelectorExpr); ok && header.Sel.Name == "Header" {
+			return RequestValue{Part: HeaderPart, Keys: []string{key}}, true
+		}
+	}
+	return RequestValue{}, false
+}
+
+//Whether the expression is the URL variables of a request, the result of
+//mux.Vars or a variable holding it
+func (t *taint) urlVars(expr ast.Expr, seen map[*ast.Object]bool) bool {
+	switch expr := unparen(expr).(type) {
+	case *ast.CallExpr:
+		return callName(expr) == "mux.Vars"
+	case *ast.Ident:
+		if expr.Obj == nil || seen[expr.Obj] {
+			return false
+		}
+		if decl, ok := expr.Obj.Decl.(*ast.AssignStmt); ok && len(decl.Lhs) == len(decl.Rhs) {
+			for i, lhs := range decl.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Obj == expr.Obj {
+					return t.urlVars(decl.Rhs[i], seen)
+				}
+			}
+		}
+	}
+	return false
+}
+
+//Keys of the JSON encoding of the fields of the struct type, by their tag
+//or their name. Stops at a field that isn't encoded
+func jsonKeys(t ast.Expr, fields []string) []string {
+	keys := make([]string, 0, len(fields))
+	for _, name := range fields {
+		st := structOfType(t)
+		if st == nil {
+			return keys
+		}
+		var field *ast.Field
+		for _, f := range st.Fields.List {
+			for _, n := range f.Names {
+				if n.Name == name {
+					field = f
+				}
+			}
+		}
+		if field == nil || !ast.IsExported(name) {
+			return keys
+		}
+		key := name
+		if field.Tag != nil {
+			tag, _ := strconv.Unquote(field.Tag.Value)
+			if k := strings.Split(reflect.StructTag(tag).Get("json"), ",")[0]; k == "-" {
+				return keys
+			} else if k != "" {
+				key = k
+			}
+		}
+		keys = append(keys, key)
+		t = field.Type
+	}
+	return keys
+}
+
+//HTTPRequest is a request to a handler
+type HTTPRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header map[string]string
+	Body   interface{} //nested maps for the keys of a JSON object
+}
+
+//BuildRequest is the request to the endpoint with the values the solver
+//found for the inputs. Variables of the path without a value are 0
+func BuildRequest(endpoint *Endpoint, values map[string]RequestValue, assignments map[string]*z3.AST) *HTTPRequest {
+	request := &HTTPRequest{Path: "/", Query: url.Values{}, Header: make(map[string]string)}
+	if endpoint != nil {
+		request.Method, request.Path = endpoint.Method, endpoint.Path
+	}
+
+	//the constraints don't tie parameters to their arguments, a value
+	//passed to the calls the path ends in takes the place of the others
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if values[names[i]].Calls != values[names[j]].Calls {
+			return values[names[i]].Calls < values[names[j]].Calls
+		}
+		return names[i] < names[j]
+	})
+	vars := make(map[string]string)
+	for _, name := range names {
+		value, solved := values[name], assignments[name]
+		if solved == nil {
+			continue
+		}
+		v := concrete(name, solved)
+		switch value.Part {
+		case BodyPart:
+			request.Body = setKey(request.Body, value.Keys, v)
+		case PathPart:
+			vars[value.Keys[0]] = fmt.Sprint(v)
+		case QueryPart:
+			request.Query.Set(value.Keys[0], fmt.Sprint(v))
+		case HeaderPart:
+			request.Header[value.Keys[0]] = fmt.Sprint(v)
+		}
+	}
+	request.Path = fillVars(request.Path, vars)
+
+	if request.Method == "" {
+		request.Method = "GET"
+		if request.Body != nil {
+			request.Method = "POST"
+		}
+	}
+	return request
+}
+
+//Value of the input in the request, a string of the length for a length
+func concrete(name string, value *z3.AST) interface{} {
+	switch s := value.String(); s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if _, measured := inputVariable(name); measured {
+		n := value.Int()
+		if n < 0 {
+			n = 0
+		}
+		return strings.Repeat("a", n)
+	}
+	return value.Int()
+}
+
+//Sets the value at the keys of the JSON object
+func setKey(body interface{}, keys []string, value interface{}) interface{} {
+	if len(keys) == 0 {
+		return value
+	}
+	object, ok := body.(map[string]interface{})
+	if !ok {
+		object = make(map[string]interface{})
+	}
+	object[keys[0]] = setKey(object[keys[0]], keys[1:], value)
+	return object
+}
+
+//Replaces the URL variables of the path, also the ones with a pattern
+//({id:[0-9]+}). Variables without a value are 0
+func fillVars(path string, vars map[string]string) string {
+	var filled strings.Builder
+	for {
+		start := strings.Index(path, "{")
+		end := strings.Index(path, "}")
+		if start < 0 || end < start {
+			filled.WriteString(path)
+			return filled.String()
+		}
+		name := strings.SplitN(path[start+1:end], ":", 2)[0]
+		value, ok := vars[name]
+		if !ok {
+			value = "0"
+		}
+		filled.WriteString(path[:start] + url.PathEscape(value))
+		path = path[end+1:]
+	}
+}
+
+//URL is the path with the query
+func (r *HTTPRequest) URL() string {
+	if len(r.Query) == 0 {
+		return r.Path
+	}
+	return r.Path + "?" + r.Query.Encode()
+}
+
+//JSON is the body encoded, empty without a body
+func (r *HTTPRequest) JSON() string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := json.Marshal(r.Body)
+	if err != nil {
+		return ""
+	}
+	return string(body)
+}
+
+//Curl is a command sending the request to the host (http://localhost:3000)
+func (r *HTTPRequest) Curl(host string) string {
+	command := fmt.Sprintf("curl -X %s '%s%s'", r.Method, strings.TrimSuffix(host, "/"), r.URL())
+	headers := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		command += fmt.Sprintf(" -H '%s: %s'", name, r.Header[name])
+	}
+	if body := r.JSON(); body != "" {
+		command += fmt.Sprintf(" -H 'Content-Type: application/json' -d '%s'", body)
+	}
+	return command
+}
@@ -12,309 +12,312 @@ import (
 )
 
 //---------- Labeling feature for Must/May-haves (rewrite) --------------
-//Assumptions: CFG tree already created
-func (paths *PathList) LabelCFG(curr Wrapper, logs []model.LogType, root Wrapper, stackInfo helper.StackTraceStruct) {
 
-	//Nil check
-	if curr == nil {
+//LabelCFG labels every wrapper of the graph the exception is in with
+//whether it ran before the exception. Blocks dominating the exception or
+//holding a log that was seen must have run, and so must the blocks that
+//dominate or post-dominate them. With complete logs, blocks holding a log
+//that wasn't seen (at a level that isn't disabled) must not have run, nor
+//the blocks they dominate or post-dominate (a log after the exception
+//never fires, the exception ends the graph). The others may have run,
+//blocks only reached through the exception are left alone
+//Assumptions: CFG tree already created
+func (paths *PathList) LabelCFG(exception Wrapper, logs []model.LogType) {
+	if exception == nil {
 		return
 	}
-	if curr == root{ //Exception node should be a must (make sure immediate block before entering exception block is labeled also)
-		curr.SetLabel(Must)
-		if len(curr.GetParents()) == 1{
-			curr.GetParents()[0].SetLabel(Must)
-			//fmt.Println("Parent labeled as must", curr.GetParents()[0])
-		}else if len(curr.GetParents()) == 2{ //Not sure if this ever occurs
-			// truePar := curr.GetParents()[0]
-			// falsePar := curr.GetParents()[1]
-			// fmt.Println("True par", truePar)
-			// fmt.Println("false par", falsePar)
+	g := newDominance(exception)
+
+	must := make([]bool, len(g.nodes))
+	mustNot := make([]bool, len(g.nodes))
+	for i, w := range g.nodes {
+		b, ok := w.(*BlockWrapper)
+		switch {
+		case w == exception:
+			must[i] = true
+		case !ok:
+		case seenLog(b, logs):
+			must[i] = true
+		case paths.CompleteLogs && paths.expectsLog(b):
+			mustNot[i] = true
 		}
 	}
+	g.spread(must, func(i int) []int { return []int{g.idom[i], g.ipdom[i]} })
+	g.spread(mustNot, func(i int) []int { return append(append([]int{}, g.domChildren[i]...), g.pdomChildren[i]...) })
+
+	for i, w := range g.nodes {
+		switch {
+		case w == nil:
+		case must[i]:
+			w.SetLabel(Must)
+		case mustNot[i]:
+			w.SetLabel(MustNot)
+		default:
+			w.SetLabel(May)
+		}
+	}
+}
 
-	wrapper := curr //holds current wrapper
-	var prv Wrapper
-
-	//Iterate up through parents up to root
-	for len(wrapper.GetParents()) > 0 && prv != wrapper {
-		prv = wrapper
-		if wrapper.GetLabel() == NoLabel {
-			switch wrap := wrapper.(type) {
-			case *FnWrapper:
-
-				//If it's a function in the stack trace, then it must run
-				//fnwrapper connected to blockWrapper succs
-				if CheckFnStatus(wrap, stackInfo) {
-					wrap.SetLabel(Must)
-				} else {
-					wrap.SetLabel(May)
-				}
-				// fmt.Println("Current wrapper in label", curr)
-
-			case *BlockWrapper: //BlockWrapper can represent a condition, but could be a statement, etc
-				//Check if it's a condition, if not set as must
-
-				//fmt.Println("Current wrapper in label", wrap)
-
-
-				//Check for possible log msg and log matchings
-				if strings.Contains(wrap.Block.String(), "entry") { //Entry is may
-					wrap.SetLabel(May)
-				}else if strings.Contains(wrap.Block.String(), "if.then") ||//If it is part of an if-then or if-else, it is labeled as may (parent can be overriden if log found)
-					strings.Contains(wrap.Block.String(), "if.else") {
-					fmt.Println("Block being processed in if/else", wrap.Block.String())
-					LabelIfElseBlock(curr, logs, root)
-				}else if wrap.GetCondition() != nil{ //Only true if a block has 2 successors
-					//wrap.SetLabel(Must)
-					wrap.SetLabel(May)
-				}else{
-					wrap.SetLabel(May) //label as May if no logs detected
-					//wrap.SetLabel(MustNot)
-				}
-
-				if CheckLogStatus(wrap.Block.Nodes, logs) { //If there's a matching log statement, then it has to be a must
-					wrap.SetLabel(Must)
-					//wrap.SetLabel(May)
-				}
-
-
-				//If two parents, go up to top and label down
-				if len(wrap.GetParents()) == 2 {
-					//If the wrapper also has two children, finish labeling other child wrapper first
-					if len(wrap.GetChildren()) == 2{
-						ProcessChildrenWraps(wrapper, logs, root)
-					}
-					//fmt.Println(wrap.Block.String(), " has two parents")
-					wrapper = GetTopAndLabel(wrap, logs, wrap, stackInfo, root)
-				}
+//Whether a log of the block was seen, by its position or its message
+func seenLog(b *BlockWrapper, logs []model.LogType) bool {
+	for _, event := range b.logEvents() {
+		for _, logType := range logs {
+			if event.matches(logType) {
+				return true
 			}
-		} else {
-			//fmt.Println("Wrapper is already labeled", wrapper)
-			// return
 		}
+	}
+	return false
+}
 
-		//Set next wrapper (parents) - two parent case should already be handled already by GetTopAndLabel
-		if len(wrapper.GetParents()) == 1 {
-			wrapper = wrapper.GetParents()[0]
+//Whether the block emits a log that would be in the logs had it run: the
+//block isn't a summary (its logs are conditional) and the level isn't disabled
+func (paths *PathList) expectsLog(b *BlockWrapper) bool {
+	if fn, ok := b.Outer.(*FnWrapper); ok && fn.Summarized() {
+		return false
+	}
+	for _, node := range b.Block.Nodes {
+		if isLogStmt(node) && !paths.levelDisabled(logLevel(node)) {
+			return true
 		}
 	}
+	return false
+}
 
-	//Label root as must
-	if len(wrapper.GetParents()) == 0 {
-		//wrapper.SetLabel(Must)
+func (paths *PathList) levelDisabled(level string) bool {
+	if level == "" {
+		return false
 	}
+	for _, disabled := range paths.DisabledLevels {
+		if levelOf(disabled) == level {
+			return true
+		}
+	}
+	return false
 }
 
-func ProcessChildrenWraps(wrapper Wrapper, logs []model.LogType, root Wrapper) {
+//logLevels are the levels log methods are named after (Debugf, Warning, Err)
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "err", "fatal", "panic"}
 
-	if wrapper == nil {
-		return
+//Level of a log statement from the methods of its chain (log.Warnf,
+//log.Debug().Msg), "" for logs without one like log.Printf
+func logLevel(node ast.Node) string {
+	stmt, ok := node.(*ast.ExprStmt)
+	if !ok {
+		return ""
 	}
+	expr := stmt.X
+	for {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok {
+			return ""
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return ""
+		}
+		if level := levelOf(sel.Sel.Name); level != "" {
+			return level
+		}
+		expr = sel.X
+	}
+}
 
-	for _, child := range wrapper.GetChildren(){
-		if wrapper.GetLabel() == NoLabel {
-			switch curr := wrapper.(type) {
-			case *BlockWrapper:
-				LabelIfElseBlock(curr, logs, root) //Should set to must or must not
-
-				//If it's an if.done, or something else, set it to may (may need extra logic later)
-				if curr.GetLabel() == NoLabel{
-					curr.SetLabel(May)
-				}
+//Level a name starts with, Errorf and Err are both error
+func levelOf(name string) string {
+	name = strings.ToLower(name)
+	for _, level := range logLevels {
+		if strings.HasPrefix(name, level) {
+			if level == "err" {
+				return "error"
 			}
+			return level
 		}
-
-		ProcessChildrenWraps(child, logs, root)
 	}
+	return ""
 }
-//Helper function to get topmost node where conditionals connect
-func GetTopAndLabel(wrapper Wrapper, logs []model.LogType, start Wrapper, stackInfo helper.StackTraceStruct, root Wrapper) Wrapper {
-
-	//if a new endNode is found,
-	//require that many more conditional
-	//nodes to be found, until the topmost
-	//one is found
-	curr := wrapper
-	totalCount := 0
-	done := false
-
-	//Go up to very top
-	for !done {
-
-		//Each time a block containing a condition is found, increment the count
-		switch curr := curr.(type) {
-		case *BlockWrapper:
-			condNode := curr.GetCondition()
-			if condNode != nil {
-				totalCount++
+
+//dominance holds the dominator and post-dominator trees of the graph an
+//exception is in. The exception ends the graph: nothing after it runs
+type dominance struct {
+	nodes        []Wrapper //the first is a virtual entry, the last a virtual exit
+	idom         []int     //immediate dominators
+	ipdom        []int     //immediate post-dominators
+	domChildren  [][]int
+	pdomChildren [][]int
+}
+
+func newDominance(exception Wrapper) *dominance {
+	//the entries are the wrappers without parents above the exception
+	entries := make([]Wrapper, 0)
+	seen := map[Wrapper]bool{exception: true}
+	stack := []Wrapper{exception}
+	for len(stack) > 0 {
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if len(w.GetParents()) == 0 {
+			entries = append(entries, w)
+		}
+		for _, p := range w.GetParents() {
+			if !seen[p] {
+				seen[p] = true
+				stack = append(stack, p)
 			}
 		}
+	}
 
-		//Move to next node up the tree
-		if len(curr.GetParents()) > 0 {
-			curr = curr.GetParents()[0]
+	g := &dominance{nodes: []Wrapper{nil}}
+	index := make(map[Wrapper]int)
+	var add func(w Wrapper) int
+	add = func(w Wrapper) int {
+		if i, ok := index[w]; ok {
+			return i
 		}
-
-		//If at very top, stop
-		if len(curr.GetParents()) == 0 {
-			// fmt.Println("Finished going up tree")
-			if totalCount == 0 {
-				fmt.Println("No conditions found")
+		index[w] = len(g.nodes)
+		g.nodes = append(g.nodes, w)
+		if w != exception {
+			for _, child := range w.GetChildren() {
+				add(child)
 			}
-			done = true
+		}
+		return index[w]
+	}
+	for _, entry := range entries {
+		add(entry)
+	}
+	g.nodes = append(g.nodes, nil)
+	exit := len(g.nodes) - 1
+
+	succs := make([][]int, len(g.nodes))
+	preds := make([][]int, len(g.nodes))
+	link := func(from, to int) {
+		succs[from] = append(succs[from], to)
+		preds[to] = append(preds[to], from)
+	}
+	for _, entry := range entries {
+		link(0, index[entry])
+	}
+	for i, w := range g.nodes {
+		if w == nil {
+			continue
+		}
+		if w == exception || len(w.GetChildren()) == 0 {
+			link(i, exit)
+			continue
+		}
+		for _, child := range w.GetChildren() {
+			link(i, index[child])
 		}
 	}
 
-	//Label topmost node as must
-	curr.SetLabel(Must)
-
-	//Go down through children to label nodes
-	LabelDown(curr, start, false, logs, stackInfo, totalCount, root)
-
-	return curr
+	g.idom = immediateDominators(succs, 0)
+	g.ipdom = immediateDominators(preds, exit)
+	g.domChildren = treeChildren(g.idom)
+	g.pdomChildren = treeChildren(g.ipdom)
+	return g
 }
 
-//Helper function used in GetTopAndLabel
-func LabelDown(curr Wrapper, start Wrapper, isLog bool, logs []model.LogType, stackInfo helper.StackTraceStruct, totalCount int, root Wrapper) {
-
-	isLogStmt := isLog
-	//If at bottom, return, or if there's already a label
-	if curr == start || totalCount == 0{
-		return
+//spread marks the nodes implied by the marked ones until nothing changes,
+//virtual and unreachable nodes are never marked
+func (g *dominance) spread(marked []bool, implied func(i int) []int) {
+	work := make([]int, 0)
+	for i, m := range marked {
+		if m {
+			work = append(work, i)
+		}
 	}
-
-	// fmt.Println("Current wrapper in label", curr)
-
-	//Set label downward | Process current node before processing child node
-	for _, child := range curr.GetChildren() {
-
-		//Type switch to get specific info
-		//var currNodes = []ast.Node{}
-		switch currType := curr.(type) {
-		case *BlockWrapper:
-
-			//If it's a condition, decrement the count
-			if currType.GetCondition() != nil {
-				totalCount--
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, j := range implied(i) {
+			if j >= 0 && g.nodes[j] != nil && !marked[j] {
+				marked[j] = true
+				work = append(work, j)
 			}
-
-			//Label only if not already labeled
-			//If it is a log stmt or matches regex then need to label as must
-			if curr.GetLabel() == NoLabel {
-				LabelIfElseBlock(currType, logs, root) //Matches logs found in an if/else block, and sets its parent's block if there is one
-
-				//if CheckLogStatus(currNodes, logs) {
-				//	isLogStmt = true
-				//	//curr.SetLabel(Must)
-				//	fmt.Println("Current block Matches log", currType.Block.String())
-				//} else {
-				//	curr.SetLabel(MustNot)
-				//	fmt.Println("Current block No Matches", currType.Block.String())
-				//}
-
-				//If it's part of a log block then label as must
-				//if isLog {
-				//	curr.SetLabel(Must)
-				//}
-
 		}
 	}
+}
 
+//Immediate dominators of the nodes reachable from the entry, computed
+//with the iterative algorithm of Cooper, Harvey and Kennedy. The entry is
+//its own dominator, unreachable nodes have -1
+func immediateDominators(succs [][]int, entry int) []int {
+	postorder := make([]int, 0, len(succs))
+	visited := make([]bool, len(succs))
+	var visit func(i int)
+	visit = func(i int) {
+		visited[i] = true
+		for _, succ := range succs[i] {
+			if !visited[succ] {
+				visit(succ)
+			}
+		}
+		postorder = append(postorder, i)
+	}
+	visit(entry)
+
+	rank := make([]int, len(succs))
+	preds := make([][]int, len(succs))
+	for r, i := range postorder {
+		rank[i] = r
+		for _, succ := range succs[i] {
+			preds[succ] = append(preds[succ], i)
+		}
+	}
 
-		LabelDown(child, start, isLogStmt, logs, stackInfo, totalCount, root)
+	idom := make([]int, len(succs))
+	for i := range idom {
+		idom[i] = -1
+	}
+	idom[entry] = entry
+	intersect := func(a, b int) int {
+		for a != b {
+			for rank[a] < rank[b] {
+				a = idom[a]
+			}
+			for rank[b] < rank[a] {
+				b = idom[b]
+			}
+		}
+		return a
 	}
-}
 
-func LabelIfElseBlock(currType Wrapper, logs []model.LogType, root Wrapper){
-
-	switch currType := currType.(type){
-	case *BlockWrapper:
-		if currType.Label == NoLabel {
-			//For if.then, if.else, label must Must/MustNot
-			if strings.Contains(currType.Block.String(), "if.then"){
-				//If Log match found in an if/else, then label current block and its parent as a must
-				if CheckLogStatus(currType.Block.Nodes, logs) {
-					currType.SetLabel(Must)
-					//fmt.Println(currType.Block.String(), " has a match")
-
-					//Need to set the status of the condition in parent's block as well
-					if len(currType.GetParents()) == 1{
-						if currType.GetParents()[0] != root.GetParents()[0]{ //don't overwrite the exception block's parents
-							currType.GetParents()[0].SetLabel(Must)
-						}
-					}
-				} else {
-					currType.SetLabel(MustNot)
-					//fmt.Println(currType.Block.String(), " -- no match")
-
-					//Need to set the status of the condition in parent's block as well
-					if len(currType.GetParents()) == 1 {					
-						//if currType.GetParents()[0].GetLabel() == NoLabel {
-						if currType.GetParents()[0] != root.GetParents()[0]{ //don't overwrite the exception block's parents
-							currType.GetParents()[0].SetLabel(MustNot)
-						}					
-					}
+	for changed := true; changed; {
+		changed = false
+		for r := len(postorder) - 1; r >= 0; r-- {
+			i := postorder[r]
+			if i == entry {
+				continue
+			}
+			newIdom := -1
+			for _, pred := range preds[i] {
+				if idom[pred] == -1 {
+					continue
 				}
-			}else if strings.Contains(currType.Block.String(), "if.else"){
-				if CheckLogStatus(currType.Block.Nodes, logs) {
-					currType.SetLabel(Must)
-					//fmt.Println(currType.Block.String(), " has a match")
-					//Need to set the status of the condition in parent's block as well
-					if len(currType.GetParents()) == 1 {
-						par := currType.GetParents()[0]
-						if par != root.GetParents()[0] && par.GetLabel() != MustNot{ //If a if.then is processed, don't overwrite its label
-							currType.GetParents()[0].SetLabel(Must)
-						}
-					}
-				}else{
-					currType.SetLabel(MustNot)
-					//fmt.Println(currType.Block.String(), " -- no match")
-					//Need to set the status of the condition in parent's block as well
-					if len(currType.GetParents()) == 1 {
-						par := currType.GetParents()[0]
-						if par != root.GetParents()[0] && par.GetLabel() != Must{ //don't overwrite the exception block's parents TODO: Needed?
-							currType.GetParents()[0].SetLabel(MustNot)
-						}
-					}
+				if newIdom == -1 {
+					newIdom = pred
+				} else {
+					newIdom = intersect(pred, newIdom)
 				}
 			}
+			if idom[i] != newIdom {
+				idom[i] = newIdom
+				changed = true
+			}
 		}
 	}
+	return idom
 }
 
-func isAssignment(node ast.Node) bool {
-	var isAssignment bool = false
-
-	//for _, node := range nodes{
-		//If any node is an assignment statement, then return true
-		if _, ok := node.(*ast.AssignStmt); ok {
-			isAssignment = ok
-			//break
-		}
-	//}
-	return isAssignment
-}
-
-//Helper for checking if FnWrapper is a may/must (checks function with stack to see if it is relevant)
-func CheckFnStatus(wrapper *FnWrapper, stackInfo helper.StackTraceStruct) bool {
-	var isMust = false
-
-	switch funcNode := wrapper.Fn.(type) {
-	case *ast.FuncDecl:
-		//Check if function is in the stack trace
-		for _, funcName := range stackInfo.FuncName {
-			fmt.Println("Node func:", funcNode.Name.Name, ", Stack Func:", funcName)
-			if funcNode.Name.Name == funcName {
-				isMust = true
-				fmt.Println("Function ", funcNode.Name.Name, " is in the stack trace")
-				break
-			}
+//Children of the nodes in the tree of immediate dominators
+func treeChildren(idom []int) [][]int {
+	children := make([][]int, len(idom))
+	for i, parent := range idom {
+		if parent != -1 && parent != i {
+			children[parent] = append(children[parent], i)
 		}
-	case *ast.FuncLit:
-		fmt.Println(funcNode)
 	}
-
-	return isMust
+	return children
 }
 
 //Helper function to check if a BlockWrapper contains a log, or if it matches a relevant regex
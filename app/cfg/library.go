@@ -0,0 +1,262 @@
+package cfg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/cfg"
+	"gopkg.in/yaml.v2"
+)
+
+//LibraryFunction summarizes a function whose body isn't in the project.
+//Its constraints are Go expressions over the names of its parameters and
+//results, bound to the arguments and assigned variables of every call
+type LibraryFunction struct {
+	Name    string   `yaml:"name" json:"name"`       //package and function, strconv.Atoi
+	Params  []string `yaml:"params" json:"params"`   //names of the parameters in the constraints
+	Results []string `yaml:"results" json:"results"` //names of the results in the constraints
+	Returns []string `yaml:"returns" json:"returns"` //conditions that hold when it returns
+	Panics  string   `yaml:"panics" json:"panics"`   //condition it panics under
+}
+
+//Library holds the summaries by function name
+type Library map[string]LibraryFunction
+
+//Summaries is consulted for calls no declaration is found for
+var Summaries = DefaultLibrary()
+
+//DefaultLibrary summarizes common functions of the standard library
+func DefaultLibrary() Library {
+	return NewLibrary([]LibraryFunction{
+		{Name: "len", Params: []string{"v"}, Results: []string{"n"}, Returns: []string{"n >= 0", "n == len(v)"}},
+		{Name: "cap", Params: []string{"v"}, Results: []string{"n"}, Returns: []string{"n >= 0", "n >= len(v)"}},
+		{Name: "errors.New", Params: []string{"text"}, Results: []string{"err"}, Returns: []string{"err != nil"}},
+		{Name: "fmt.Errorf", Params: []string{"format"}, Results: []string{"err"}, Returns: []string{"err != nil"}},
+		{Name: "os.Getenv", Params: []string{"key"}, Results: []string{"value"}, Returns: []string{"len(value) >= 0"}},
+		{Name: "strconv.Atoi", Params: []string{"s"}, Results: []string{"n", "err"}, Returns: []string{"err == nil || n == 0", "len(s) != 0 || err != nil"}},
+		{Name: "strconv.ParseInt", Params: []string{"s", "base", "bitSize"}, Results: []string{"n", "err"}, Returns: []string{"err == nil || n == 0", "len(s) != 0 || err != nil", "!(base == 1 || base < 0 || base > 36) || err != nil"}},
+		{Name: "strconv.Itoa", Params: []string{"i"}, Results: []string{"s"}, Returns: []string{"len(s) > 0", "i >= 0 || len(s) > 1"}},
+		{Name: "strings.Compare", Params: []string{"a", "b"}, Results: []string{"n"}, Returns: []string{"n >= -1 && n <= 1"}},
+		{Name: "strings.Contains", Params: []string{"s", "substr"}, Results: []string{"found"}, Returns: []string{"!found || len(s) >= len(substr)"}},
+		{Name: "strings.Count", Params: []string{"s", "substr"}, Results: []string{"n"}, Returns: []string{"n >= 0", "n <= len(s) + 1"}},
+		{Name: "strings.Index", Params: []string{"s", "substr"}, Results: []string{"i"}, Returns: []string{"i >= -1", "i == -1 || i <= len(s) - len(substr)"}},
+		{Name: "strings.LastIndex", Params: []string{"s", "substr"}, Results: []string{"i"}, Returns: []string{"i >= -1", "i == -1 || i <= len(s) - len(substr)"}},
+		{Name: "strings.IndexByte", Params: []string{"s", "c"}, Results: []string{"i"}, Returns: []string{"i >= -1", "i < len(s)"}},
+		{Name: "strings.Repeat", Params: []string{"s", "count"}, Results: []string{"r"}, Returns: []string{"len(r) == len(s) * count"}, Panics: "count < 0"},
+		{Name: "strings.Trim", Params: []string{"s", "cutset"}, Results: []string{"r"}, Returns: []string{"len(r) <= len(s)"}},
+		{Name: "strings.TrimSpace", Params: []string{"s"}, Results: []string{"r"}, Returns: []string{"len(r) <= len(s)"}},
+		{Name: "strings.TrimPrefix", Params: []string{"s", "prefix"}, Results: []string{"r"}, Returns: []string{"len(r) <= len(s)"}},
+		{Name: "strings.TrimSuffix", Params: []string{"s", "suffix"}, Results: []string{"r"}, Returns: []string{"len(r) <= len(s)"}},
+		{Name: "strings.ToLower", Params: []string{"s"}, Results: []string{"r"}, Returns: []string{"len(r) >= 0"}},
+		{Name: "strings.ToUpper", Params: []string{"s"}, Results: []string{"r"}, Returns: []string{"len(r) >= 0"}},
+		{Name: "strings.Split", Params: []string{"s", "sep"}, Results: []string{"parts"}, Returns: []string{"len(parts) >= 1 || len(s) == 0"}},
+		{Name: "regexp.MustCompile", Params: []string{"expr"}, Results: []string{"re"}, Returns: []string{"re != nil"}},
+		{Name: "time.NewTicker", Params: []string{"d"}, Results: []string{"t"}, Returns: []string{"t != nil"}, Panics: "d <= 0"},
+	})
+}
+
+//NewLibrary indexes the functions by name
+func NewLibrary(functions []LibraryFunction) Library {
+	library := make(Library, len(functions))
+	for _, f := range functions {
+		library[f.Name] = f
+	}
+	return library
+}
+
+//LoadLibrary reads a YAML (or JSON) list of summaries and checks that
+//their constraints are expressions
+func LoadLibrary(path string) (Library, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	functions := make([]LibraryFunction, 0)
+	if err := yaml.UnmarshalStrict(data, &functions); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	for _, f := range functions {
+		if f.Name == "" {
+			return nil, fmt.Errorf("reading %s: a summary has no name", path)
+		}
+		for _, condition := range append(append([]string{}, f.Returns...), f.Panics) {
+			if condition == "" {
+				continue
+			}
+			if _, err := parser.ParseExpr(condition); err != nil {
+				return nil, fmt.Errorf("reading %s: %s: %q: %v", path, f.Name, condition, err)
+			}
+		}
+	}
+	return NewLibrary(functions), nil
+}
+
+//Lookup finds the summary of the function called, calls of methods and
+//of functions declared in the project have none
+func (l Library) Lookup(call *ast.CallExpr) (LibraryFunction, bool) {
+	var name string
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		//builtins aren't declared
+		if fn.Obj != nil {
+			return LibraryFunction{}, false
+		}
+		name = fn.Name
+	case *ast.SelectorExpr:
+		//imported packages aren't declared in the file
+		pkg, ok := fn.X.(*ast.Ident)
+		if !ok || pkg.Obj != nil {
+			return LibraryFunction{}, false
+		}
+		name = pkg.Name + "." + fn.Sel.Name
+	}
+	f, ok := l[name]
+	return f, ok
+}
+
+//wrap stands in for the body of the function at the call. Its only block
+//holds the constraints on the results, the condition it panics under is
+//kept on the wrapper
+func (f LibraryFunction) wrap(caller *FnWrapper, call *ast.CallExpr) *FnWrapper {
+	bound := make(map[string]ast.Expr)
+	for i, param := range f.Params {
+		if i < len(call.Args) {
+			bound[param] = call.Args[i]
+		}
+	}
+	for i, result := range resultsOf(fnBody(caller.Fn), call) {
+		if result != nil && i < len(f.Results) {
+			bound[f.Results[i]] = result
+		}
+	}
+	return f.wrapBound(bound)
+}
+
+//wrapBound stands in for the function with its parameters and results
+//bound to the given expressions
+func (f LibraryFunction) wrapBound(bound map[string]ast.Expr) *FnWrapper {
+	fn := &FnWrapper{
+		Parents:      make([]Wrapper, 0),
+		ParamsToArgs: make(map[*ast.Object]ast.Expr),
+		Library:      &f,
+		bound:        bound,
+		name:         f.Name,
+		summarized:   true,
+	}
+	block := &BlockWrapper{
+		Block:   &cfg.Block{Nodes: make([]ast.Node, 0)},
+		Parents: []Wrapper{fn},
+		Succs:   make([]Wrapper, 0),
+		Outer:   fn,
+	}
+	for _, condition := range f.Returns {
+		if expr := bindCondition(condition, bound); expr != nil {
+			block.Block.Nodes = append(block.Block.Nodes, expr)
+		}
+	}
+	if f.Panics != "" {
+		fn.Panics = bindCondition(f.Panics, bound)
+	}
+	//calls it says nothing about stay unknown, in conditions too
+	if len(block.Block.Nodes) == 0 && fn.Panics == nil {
+		return nil
+	}
+	fn.FirstBlock = block
+	return fn
+}
+
+//Parses the condition and puts the bound expressions in place of the
+//names, nil when it mentions one that isn't bound
+func bindCondition(condition string, bound map[string]ast.Expr) ast.Expr {
+	expr, err := parser.ParseExpr(condition)
+	if err != nil {
+		return nil
+	}
+	complete := true
+	expr = astutil.Apply(expr, nil, func(c *astutil.Cursor) bool {
+		id, ok := c.Node().(*ast.Ident)
+		if !ok {
+			return true
+		}
+		//function names and fields stay as they are
+		if call, ok := c.Parent().(*ast.CallExpr); ok && call.Fun == id {
+			return true
+		}
+		if sel, ok := c.Parent().(*ast.SelectorExpr); ok && sel.Sel == id {
+			return true
+		}
+		if value, ok := bound[id.Name]; ok {
+			c.Replace(value)
+		} else if id.Name != "nil" && id.Name != "true" && id.Name != "false" {
+			complete = false
+		}
+		return true
+	}).(ast.Expr)
+	if !complete {
+		return nil
+	}
+	return expr
+}
+
+//Variables the results of the call are assigned to, the blank identifier
+//leaves a result unbound
+func resultsOf(body *ast.BlockStmt, call *ast.CallExpr) []ast.Expr {
+	results := make([]ast.Expr, 0)
+	if body == nil {
+		return results
+	}
+	ast.Inspect(body, func(node ast.Node) bool {
+		if stmt, ok := node.(*ast.AssignStmt); ok {
+			if len(stmt.Rhs) == 1 && stmt.Rhs[0] == call {
+				results = append(results, stmt.Lhs...)
+			} else {
+				for i, rhs := range stmt.Rhs {
+					if rhs == call && i < len(stmt.Lhs) {
+						results = append(results, stmt.Lhs[i])
+					}
+				}
+			}
+		}
+		return len(results) == 0
+	})
+	for i, result := range results {
+		if id, ok := result.(*ast.Ident); ok && id.Name == "_" {
+			results[i] = nil
+		}
+	}
+	return results
+}
+
+//Conditions of the library functions called at the end of the block to
+//panic, the panic of a trace ending in such a call is in there. Their
+//variables are the caller's, named like its conditions
+func (b *BlockWrapper) libraryPanics() []ast.Node {
+	conditions := make([]ast.Node, 0)
+	for _, succ := range b.Succs {
+		if fn, ok := succ.(*FnWrapper); ok && fn.Panics != nil {
+			condition := fn.panics
+			if condition == nil {
+				condition = bindCondition(fn.Library.Panics, (&ssaBuilder{}).bindings(fn, nil))
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
+//isLibrary tells if the block holds the constraints of a library function
+func (b *BlockWrapper) isLibrary() bool {
+	fn, ok := b.Outer.(*FnWrapper)
+	return ok && fn.Library != nil
+}
+
+//Whether the identifier is the builtin, also once it is named after the
+//function it is in (F.len)
+func isBuiltin(fn ast.Expr, name string) bool {
+	id, ok := fn.(*ast.Ident)
+	return ok && (id.Name == name || strings.HasSuffix(id.Name, "."+name))
+}
@@ -0,0 +1,256 @@
+package cfg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sourcecrawler/app/model"
+)
+
+//LogEvent is a log statement a path goes through
+type LogEvent struct {
+	Stmt     ast.Node
+	Position token.Position
+	Level    string
+	Optional bool //in the summary of a call, it may not have been written
+	//calls of the logging wrappers the log is written through, their log
+	//types are at the calls
+	Calls []token.Position
+}
+
+//LogAlignment pairs the logs a path writes with the logs observed.
+//Observed holds, for every event of the path, the index of the observed
+//log it is, -1 when it is none of them
+type LogAlignment struct {
+	Observed   []int
+	Consistent bool
+	Conflict   string //why the path can't have written the logs observed
+}
+
+//Whether the event can be the observed log, by where the log type is or
+//by its regex when the location doesn't match
+func (e LogEvent) matches(logType model.LogType) bool {
+	if logType.FilePath != "" {
+		for _, position := range append([]token.Position{e.Position}, e.Calls...) {
+			if position.Line == logType.LineNumber && filepath.Clean(position.Filename) == filepath.Clean(logType.FilePath) {
+				return true
+			}
+		}
+	}
+	return CheckLogStatus([]ast.Node{e.Stmt}, []model.LogType{logType})
+}
+
+func (e LogEvent) String() string {
+	return fmt.Sprintf("the log at %s", e.Position)
+}
+
+//Log statements of the block in the order they run
+func (b *BlockWrapper) logEvents() []LogEvent {
+	events := make([]LogEvent, 0)
+	fn, _ := b.Outer.(*FnWrapper)
+	for _, node := range b.Block.Nodes {
+		if !isLogStmt(node) {
+			continue
+		}
+		event := LogEvent{Stmt: node, Level: logLevel(node), Optional: fn != nil && fn.Summarized()}
+		if fset := b.GetFileSet(); fset != nil {
+			event.Position = fset.Position(node.Pos())
+			event.Calls = wrapperCalls(fn, node.(*ast.ExprStmt).X.(*ast.CallExpr).Args, fset)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+//Positions of the calls of the logging wrappers a log with the arguments
+//is written through, from the innermost. A call counts while the message
+//comes from the parameters it passes
+func wrapperCalls(fn *FnWrapper, args []ast.Expr, fset *token.FileSet) []token.Position {
+	calls := make([]token.Position, 0)
+	for ; fn != nil && fn.Call != nil && usesParams(args, fn); fn = fn.caller() {
+		calls = append(calls, fset.Position(fn.Call.Pos()))
+		args = fn.Call.Args
+	}
+	return calls
+}
+
+//Whether one of the expressions reads a parameter of the function
+func usesParams(exprs []ast.Expr, fn *FnWrapper) bool {
+	uses := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Obj != nil {
+				if _, ok := fn.ParamsToArgs[id.Obj]; ok {
+					uses = true
+				}
+			}
+			return !uses
+		})
+	}
+	return uses
+}
+
+//Events of the statements up to the node, all of them without a node
+func eventsUntil(events []LogEvent, node token.Pos) []LogEvent {
+	if node == token.NoPos {
+		return events
+	}
+	until := make([]LogEvent, 0, len(events))
+	for _, event := range events {
+		if event.Stmt.Pos() <= node {
+			until = append(until, event)
+		}
+	}
+	return until
+}
+
+//Node of the block spanning the line, NoPos when there's none
+func (b *BlockWrapper) nodeOnLine(line int) token.Pos {
+	fset := b.GetFileSet()
+	if line <= 0 || fset == nil {
+		return token.NoPos
+	}
+	for _, node := range b.Block.Nodes {
+		if fset.Position(node.Pos()).Line <= line && line <= fset.Position(node.End()).Line {
+			return node.Pos()
+		}
+	}
+	return token.NoPos
+}
+
+//Aligns the logs the path writes with the order of the logs observed
+//(one log type per message, repeated as often as it was seen). A path
+//can't have run when a log it writes was observed but doesn't fit in the
+//order. With complete logs it can't either when it writes a log that
+//wasn't observed, or when a message of one of the logs of the graph is
+//left over. Those paths are labeled MustNot
+func (path *Path) align(paths *PathList, observed []model.LogType, fromGraph []bool) {
+	path.Alignment = paths.align(path.Logs, observed, fromGraph)
+	if !path.Alignment.Consistent {
+		path.DidExecute = MustNot
+	}
+}
+
+//Which observed logs one of the statements can have written
+func fromGraph(stmts []LogEvent, observed []model.LogType) []bool {
+	written := make([]bool, len(observed))
+	for j, logType := range observed {
+		for _, event := range stmts {
+			if event.matches(logType) {
+				written[j] = true
+				break
+			}
+		}
+	}
+	return written
+}
+
+//Log statements of the graph on the way to the block, the ones of the
+//block up to the exception. Loops are gone around from their headers
+func graphLogs(start Wrapper, exception token.Pos) []LogEvent {
+	stmts := make([]LogEvent, 0)
+	seen := map[Wrapper]bool{start: true}
+	stack := []Wrapper{start}
+	for len(stack) > 0 {
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		parents := w.GetParents()
+		if b, ok := w.(*BlockWrapper); ok {
+			events := b.logEvents()
+			if w == start {
+				events = eventsUntil(events, exception)
+			}
+			stmts = append(stmts, events...)
+			if b.HeadOf != nil {
+				for _, latch := range b.HeadOf.Latches {
+					parents = append(parents, latch)
+				}
+			}
+		}
+		for _, parent := range parents {
+			if !seen[parent] {
+				seen[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+	return stmts
+}
+
+//align matches as many events as possible with the observed logs without
+//changing the order of either (a longest common subsequence), then
+//checks that what is left over could have gone unseen
+func (paths *PathList) align(events []LogEvent, observed []model.LogType, fromGraph []bool) *LogAlignment {
+	n, m := len(events), len(observed)
+	best := make([][]int, n+1)
+	for i := range best {
+		best[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			best[i][j] = best[i+1][j]
+			if best[i][j+1] > best[i][j] {
+				best[i][j] = best[i][j+1]
+			}
+			if events[i].matches(observed[j]) && best[i+1][j+1]+1 > best[i][j] {
+				best[i][j] = best[i+1][j+1] + 1
+			}
+		}
+	}
+
+	alignment := &LogAlignment{Observed: make([]int, n), Consistent: true}
+	aligned := make([]bool, m)
+	written := make(map[ast.Node]bool)
+	for i, j := 0, 0; i < n; {
+		switch {
+		case j < m && events[i].matches(observed[j]) && best[i][j] == best[i+1][j+1]+1:
+			alignment.Observed[i] = j
+			aligned[j] = true
+			written[events[i].Stmt] = true
+			i++
+			j++
+		case j < m && best[i][j] == best[i][j+1]:
+			j++
+		default:
+			alignment.Observed[i] = -1
+			i++
+		}
+	}
+
+	for i, event := range events {
+		if alignment.Observed[i] != -1 || event.Optional || paths.levelDisabled(event.Level) {
+			continue
+		}
+		seen := false
+		for _, logType := range observed {
+			if event.matches(logType) {
+				seen = true
+				break
+			}
+		}
+		switch {
+		case seen && !written[event.Stmt]:
+			alignment.Conflict = fmt.Sprintf("%s was observed in another order", event)
+		case seen && paths.CompleteLogs:
+			alignment.Conflict = fmt.Sprintf("%s is written more times than it was observed", event)
+		case paths.CompleteLogs:
+			alignment.Conflict = fmt.Sprintf("%s wasn't observed", event)
+		default:
+			continue
+		}
+		alignment.Consistent = false
+		return alignment
+	}
+
+	if paths.CompleteLogs {
+		for j := range observed {
+			if fromGraph[j] && !aligned[j] {
+				alignment.Conflict = fmt.Sprintf("observed log %d isn't written by the path", j+1)
+				alignment.Consistent = false
+				return alignment
+			}
+		}
+	}
+	return alignment
+}
@@ -0,0 +1,649 @@
+package cfg
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/cfg"
+)
+
+//DefaultLoopBound is how many iterations of a loop a path goes through
+//when the path list doesn't set it
+const DefaultLoopBound = 3
+
+//Loop is a loop of a function. Going around it is only possible through
+//the back edges from the latches to the header, those are kept out of
+//Succs/Parents so the wrapped graph never has cycles
+type Loop struct {
+	Header  *BlockWrapper
+	Latches []Wrapper
+}
+
+//Replaces a latch by the blocks that now end the iteration (a latch
+//split around a call ends with the second half or the callee's returns)
+func (l *Loop) replaceLatch(old Wrapper, latches ...Wrapper) {
+	ret := make([]Wrapper, 0, len(l.Latches))
+	for _, latch := range l.Latches {
+		if latch != old {
+			ret = append(ret, latch)
+		}
+	}
+	for _, latch := range latches {
+		ret = append(ret, latch)
+		if b, ok := latch.(*BlockWrapper); ok {
+			b.LatchOf = append(b.LatchOf, l)
+		}
+	}
+	l.Latches = ret
+}
+
+//Wrappers of the loop, the header and inner loops included
+func (l *Loop) body() map[Wrapper]bool {
+	body := map[Wrapper]bool{l.Header: true}
+	//the header dominates the loop, so walking up
+	//from the latches stops at it
+	stack := append([]Wrapper{}, l.Latches...)
+	for len(stack) > 0 {
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if body[w] {
+			continue
+		}
+		body[w] = true
+		stack = append(stack, w.GetParents()...)
+		//inner loops are only reached through their back edges
+		if b, ok := w.(*BlockWrapper); ok && b.HeadOf != nil && b.HeadOf != l {
+			stack = append(stack, b.HeadOf.Latches...)
+		}
+	}
+	return body
+}
+
+// ------------------ Detection ----------------------
+
+//backEdge goes from the end of an iteration back to the loop header
+type backEdge struct {
+	from, to *cfg.Block
+}
+
+//Finds the back edges of the blocks reachable from the entry: edges
+//whose target dominates their source. Edges that still close a cycle
+//(a goto into the middle of a loop) are cut the same way, with their
+//target as the header.
+func findBackEdges(entry *cfg.Block) []backEdge {
+	postorder := postorderBlocks(entry)
+	idom := dominators(entry, postorder)
+
+	edges := make([]backEdge, 0)
+	for i := len(postorder) - 1; i >= 0; i-- {
+		for _, succ := range postorder[i].Succs {
+			if dominates(idom, succ, postorder[i]) {
+				edges = append(edges, backEdge{postorder[i], succ})
+			}
+		}
+	}
+
+	//irreducible cycles are retreating edges of a depth first search
+	onStack := make(map[*cfg.Block]bool)
+	visited := make(map[*cfg.Block]bool)
+	var visit func(b *cfg.Block)
+	visit = func(b *cfg.Block) {
+		visited[b] = true
+		onStack[b] = true
+		for _, succ := range b.Succs {
+			if onStack[succ] && !dominates(idom, succ, b) {
+				edges = append(edges, backEdge{b, succ})
+			} else if !visited[succ] && !dominates(idom, succ, b) {
+				visit(succ)
+			}
+		}
+		onStack[b] = false
+	}
+	visit(entry)
+
+	return edges
+}
+
+func postorderBlocks(entry *cfg.Block) []*cfg.Block {
+	order := make([]*cfg.Block, 0)
+	visited := make(map[*cfg.Block]bool)
+	var visit func(b *cfg.Block)
+	visit = func(b *cfg.Block) {
+		visited[b] = true
+		for _, succ := range b.Succs {
+			if !visited[succ] {
+				visit(succ)
+			}
+		}
+		order = append(order, b)
+	}
+	visit(entry)
+	return order
+}
+
+//Immediate dominators of the blocks, computed with the iterative
+//algorithm of Cooper, Harvey and Kennedy. The entry is its own dominator.
+func dominators(entry *cfg.Block, postorder []*cfg.Block) map[*cfg.Block]*cfg.Block {
+	index := make(map[*cfg.Block]int, len(postorder))
+	preds := make(map[*cfg.Block][]*cfg.Block, len(postorder))
+	for i, b := range postorder {
+		index[b] = i
+	}
+	for _, b := range postorder {
+		for _, succ := range b.Succs {
+			preds[succ] = append(preds[succ], b)
+		}
+	}
+
+	idom := map[*cfg.Block]*cfg.Block{entry: entry}
+	intersect := func(a, b *cfg.Block) *cfg.Block {
+		for a != b {
+			for index[a] < index[b] {
+				a = idom[a]
+			}
+			for index[b] < index[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := len(postorder) - 1; i >= 0; i-- {
+			b := postorder[i]
+			if b == entry {
+				continue
+			}
+			var newIdom *cfg.Block
+			for _, pred := range preds[b] {
+				if _, ok := idom[pred]; !ok {
+					continue
+				}
+				if newIdom == nil {
+					newIdom = pred
+				} else {
+					newIdom = intersect(pred, newIdom)
+				}
+			}
+			if idom[b] != newIdom {
+				idom[b] = newIdom
+				changed = true
+			}
+		}
+	}
+	return idom
+}
+
+//Checks if every path from the entry to b goes through a
+func dominates(idom map[*cfg.Block]*cfg.Block, a, b *cfg.Block) bool {
+	for {
+		if a == b {
+			return true
+		}
+		next, ok := idom[b]
+		if !ok || next == b {
+			return false
+		}
+		b = next
+	}
+}
+
+// ------------------ Unrolling ----------------------
+
+//loopInfo is what the traversal needs to know about a loop, once the
+//cfg is expanded and in ssa form
+type loopInfo struct {
+	body  map[Wrapper]bool
+	defs  map[string]bool   //ssa names assigned in the loop
+	heads map[string]string //ssa names holding a variable's value at the head of an iteration, to the variable
+	phis  []*Phi            //phis of the header, their value after the loop is the one of the last iteration
+	objs  map[string]*ast.Object
+}
+
+func newLoopInfo(l *Loop) *loopInfo {
+	info := &loopInfo{
+		body:  l.body(),
+		defs:  make(map[string]bool),
+		heads: make(map[string]string),
+		objs:  make(map[string]*ast.Object),
+	}
+
+	uses := make(map[string]bool)
+	for w := range info.body {
+		if b, ok := w.(*BlockWrapper); ok && b.Block != nil {
+			//the phis of the header merge the values from before the loop
+			if b.SSA != nil && b != l.Header {
+				for _, phi := range b.SSA.Phis {
+					info.defs[phi.Value.Name()] = true
+				}
+			}
+			//the edges of the header's phis are from before the loop
+			if b.SSA != nil && b == l.Header {
+				info.phis = b.SSA.Phis
+			} else if b.SSA != nil {
+				for _, phi := range b.SSA.Phis {
+					for _, edge := range phi.Edges {
+						uses[edge.Name()] = true
+						info.objs[edge.Name()] = edge.Obj
+					}
+				}
+			}
+			//results of calls and variables written through a field
+			if b.SSA != nil {
+				for _, instr := range b.SSA.Instrs {
+					for _, def := range instr.Defs {
+						info.defs[def.Name()] = true
+					}
+				}
+			}
+			nodes := b.ssaNodes()
+			if condition := b.ssaCondition(); condition != nil {
+				nodes = append(nodes, condition)
+			}
+			for _, node := range nodes {
+				if assign, ok := node.(*ast.AssignStmt); ok {
+					for _, lhs := range assign.Lhs {
+						if id, ok := lhs.(*ast.Ident); ok {
+							info.defs[id.Name] = true
+						}
+					}
+				}
+				ast.Inspect(node, func(n ast.Node) bool {
+					if id, ok := n.(*ast.Ident); ok {
+						uses[id.Name] = true
+						if id.Obj != nil {
+							info.objs[id.Name] = id.Obj
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	//variables changed by the loop are carried from one iteration
+	//to the next, the versions read before any assignment hold the
+	//value at the head of the iteration
+	carried := make(map[string]bool)
+	for name := range info.defs {
+		carried[ssaBase(name)] = true
+	}
+	for name := range uses {
+		if !info.defs[name] && carried[ssaBase(name)] {
+			info.heads[name] = ssaBase(name)
+		}
+	}
+	return info
+}
+
+//Strips the ssa index from a name (2main.x -> main.x)
+func ssaBase(name string) string {
+	return strings.TrimLeft(name, "0123456789")
+}
+
+//activeLoop is a loop the path is in and the iteration it is at
+type activeLoop struct {
+	loop      *Loop
+	info      *loopInfo
+	iteration int
+	pending   map[string][]string //variable -> head values of the later iteration, waiting for the value at the end of this one
+}
+
+//loopState is where a path is in the loops it goes through, outermost
+//first. Going backwards from the exception the last iteration is 0 and
+//every back edge taken moves to the iteration before it.
+type loopState []activeLoop
+
+func (paths *PathList) loopInfoFor(l *Loop) *loopInfo {
+	if paths.loopInfos == nil {
+		paths.loopInfos = make(map[*Loop]*loopInfo)
+	}
+	info, ok := paths.loopInfos[l]
+	if !ok {
+		info = newLoopInfo(l)
+		paths.loopInfos[l] = info
+	}
+	return info
+}
+
+//Collects the loops that can be reached going backwards from the start
+func (paths *PathList) findLoops(start Wrapper) {
+	paths.loops = make([]*Loop, 0)
+	visited := make(map[Wrapper]bool)
+	stack := []Wrapper{start}
+	for len(stack) > 0 {
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if w == nil || visited[w] {
+			continue
+		}
+		visited[w] = true
+		stack = append(stack, w.GetParents()...)
+		if b, ok := w.(*BlockWrapper); ok && b.HeadOf != nil {
+			paths.loops = append(paths.loops, b.HeadOf)
+			stack = append(stack, b.HeadOf.Latches...)
+		}
+	}
+}
+
+//Updates the state for the block: loops the block isn't part of are
+//left, loops it is part of are entered at their last iteration. After
+//a loop the phis of its header hold their value in the last iteration
+func (paths *PathList) enterLoops(b *BlockWrapper, state loopState) (loopState, []ast.Node) {
+	links := make([]ast.Node, 0)
+	ret := make(loopState, 0, len(state))
+	active := make(map[*Loop]bool)
+	for _, a := range state {
+		if a.info.body[b] {
+			ret = append(ret, a)
+			active[a.loop] = true
+		}
+	}
+	for _, l := range paths.loops {
+		if info := paths.loopInfoFor(l); !active[l] && info.body[b] {
+			ret = append(ret, activeLoop{loop: l, info: info})
+		}
+	}
+	//outer loops contain the inner ones
+	sort.SliceStable(ret, func(i, j int) bool {
+		return len(ret[i].info.body) > len(ret[j].info.body)
+	})
+	for i, a := range ret {
+		if active[a.loop] {
+			continue
+		}
+		outside := append(append(loopState{}, ret[:i]...), ret[i+1:]...)
+		for _, phi := range a.info.phis {
+			links = append(links, linkStmt(phi.Value.Obj, outside.rename(phi.Value.Name()), ret.rename(phi.Value.Name())))
+		}
+	}
+	return ret, links
+}
+
+//Gives the name of the ssa variable in the current iterations. Names
+//assigned in a loop get the iterations of the loops they are assigned in
+//(nothing for the last iteration), head values always get them so the
+//iterations can be chained together.
+func (state loopState) rename(name string) string {
+	iterations := make([]string, 0)
+	head, last := false, true
+	for _, a := range state {
+		_, isHead := a.info.heads[name]
+		if isHead || a.info.defs[name] {
+			iterations = append(iterations, strconv.Itoa(a.iteration))
+			head = head || isHead
+			last = last && a.iteration == 0
+		}
+	}
+	switch {
+	case head:
+		return name + "@" + strings.Join(iterations, ".")
+	case len(iterations) > 0 && !last:
+		return name + "#" + strings.Join(iterations, ".")
+	}
+	return name
+}
+
+func (state loopState) find(l *Loop) int {
+	for i, a := range state {
+		if a.loop == l {
+			return i
+		}
+	}
+	return -1
+}
+
+//Links the head values waiting on the variables assigned in the block
+//to their last assignment, which ends the iteration
+func (state loopState) resolvePending(b *BlockWrapper) (loopState, []ast.Node) {
+	links := make([]ast.Node, 0)
+	ret := append(loopState{}, state...)
+	//phis come before the assignments of the block
+	defined := make([]*ast.Ident, 0)
+	if b.SSA != nil {
+		for _, phi := range b.SSA.Phis {
+			defined = append(defined, &ast.Ident{Name: phi.Value.Name(), Obj: phi.Value.Obj})
+		}
+	}
+	for _, node := range b.ssaNodes() {
+		if assign, ok := node.(*ast.AssignStmt); ok {
+			for _, lhs := range assign.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					defined = append(defined, id)
+				}
+			}
+		}
+	}
+	for n := len(defined) - 1; n >= 0; n-- {
+		id := defined[n]
+		variable := ssaBase(id.Name)
+		for i, a := range ret {
+			waiting := a.pending[variable]
+			if len(waiting) == 0 || !a.info.defs[id.Name] {
+				continue
+			}
+			for _, name := range waiting {
+				links = append(links, linkStmt(id.Obj, name, state.rename(id.Name)))
+			}
+			ret[i].pending = copyPending(a.pending)
+			delete(ret[i].pending, variable)
+		}
+	}
+	return ret, links
+}
+
+//At the header of a loop the head values waiting on variables that
+//weren't assigned in this iteration get the head values of this one
+func (state loopState) resolveAtHeader(l *Loop) (loopState, []ast.Node) {
+	links := make([]ast.Node, 0)
+	i := state.find(l)
+	if i == -1 {
+		return state, links
+	}
+	ret := append(loopState{}, state...)
+	for _, head := range sortedHeads(ret[i].info) {
+		for _, name := range ret[i].pending[ret[i].info.heads[head]] {
+			links = append(links, linkStmt(ret[i].info.objs[head], name, ret.rename(head)))
+		}
+	}
+	ret[i].pending = nil
+	return ret, links
+}
+
+//Moves the path from the header of an iteration to the end of the one before it
+func (state loopState) previousIteration(l *Loop) loopState {
+	i := state.find(l)
+	ret := append(loopState{}, state...)
+	ret[i].pending = make(map[string][]string)
+	for _, head := range sortedHeads(ret[i].info) {
+		variable := ret[i].info.heads[head]
+		ret[i].pending[variable] = append(ret[i].pending[variable], state.rename(head))
+	}
+	ret[i].iteration++
+	return ret
+}
+
+//Leaves the loop through its header, the head values of the first
+//iteration are the values from before the loop. The phis of the header
+//get theirs through the edge the path enters the loop from
+func (state loopState) exit(l *Loop) (loopState, []ast.Node) {
+	links := make([]ast.Node, 0)
+	i := state.find(l)
+	if i == -1 {
+		return state, links
+	}
+	outside := append(append(loopState{}, state[:i]...), state[i+1:]...)
+	phis := make(map[string]bool)
+	for _, phi := range state[i].info.phis {
+		phis[phi.Value.Name()] = true
+	}
+	for _, head := range sortedHeads(state[i].info) {
+		if phis[head] {
+			continue
+		}
+		links = append(links, linkStmt(state[i].info.objs[head], state.rename(head), outside.rename(head)))
+	}
+	return outside, links
+}
+
+func sortedHeads(info *loopInfo) []string {
+	heads := make([]string, 0, len(info.heads))
+	for head := range info.heads {
+		heads = append(heads, head)
+	}
+	sort.Strings(heads)
+	return heads
+}
+
+func copyPending(pending map[string][]string) map[string][]string {
+	ret := make(map[string][]string, len(pending))
+	for k, v := range pending {
+		ret[k] = v
+	}
+	return ret
+}
+
+//Builds lhs = rhs between two versions of a variable, like
+//for any other assignment the sort comes from the declaration
+func linkStmt(obj *ast.Object, lhs, rhs string) ast.Node {
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{&ast.Ident{Name: lhs, Obj: obj}},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{&ast.Ident{Name: rhs, Obj: obj}},
+	}
+}
+
+//Copies the node with its variables renamed for the current iterations,
+//the node itself is returned when no name changes
+func (state loopState) renamed(node ast.Node) ast.Node {
+	if len(state) == 0 {
+		return node
+	}
+	changes := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && state.rename(id.Name) != id.Name {
+			changes = true
+		}
+		return !changes
+	})
+	if !changes {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *ast.AssignStmt:
+		ret := *node
+		ret.Lhs = state.renamedList(node.Lhs)
+		ret.Rhs = state.renamedList(node.Rhs)
+		return &ret
+	case *ast.ExprStmt:
+		ret := *node
+		ret.X = state.renamedExpr(node.X)
+		return &ret
+	case *ast.IncDecStmt:
+		ret := *node
+		ret.X = state.renamedExpr(node.X)
+		return &ret
+	case ast.Expr:
+		return state.renamedExpr(node)
+	}
+	return node
+}
+
+func (state loopState) renamedNodes(nodes []ast.Node) []ast.Node {
+	ret := make([]ast.Node, len(nodes))
+	for i, node := range nodes {
+		ret[i] = state.renamed(node)
+	}
+	return ret
+}
+
+func (state loopState) renamedList(exprs []ast.Expr) []ast.Expr {
+	ret := make([]ast.Expr, len(exprs))
+	for i, expr := range exprs {
+		ret[i] = state.renamedExpr(expr)
+	}
+	return ret
+}
+
+func (state loopState) renamedExpr(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		ret := *expr
+		ret.Name = state.rename(expr.Name)
+		return &ret
+	case *ast.BinaryExpr:
+		ret := *expr
+		ret.X = state.renamedExpr(expr.X)
+		ret.Y = state.renamedExpr(expr.Y)
+		return &ret
+	case *ast.UnaryExpr:
+		ret := *expr
+		ret.X = state.renamedExpr(expr.X)
+		return &ret
+	case *ast.ParenExpr:
+		ret := *expr
+		ret.X = state.renamedExpr(expr.X)
+		return &ret
+	case *ast.StarExpr:
+		ret := *expr
+		ret.X = state.renamedExpr(expr.X)
+		return &ret
+	case *ast.SelectorExpr:
+		ret := *expr
+		ret.X = state.renamedExpr(expr.X)
+		return &ret
+	case *ast.IndexExpr:
+		ret := *expr
+		ret.X = state.renamedExpr(expr.X)
+		ret.Index = state.renamedExpr(expr.Index)
+		return &ret
+	case *ast.SliceExpr:
+		ret := *expr
+		ret.X = state.renamedExpr(expr.X)
+		if expr.Low != nil {
+			ret.Low = state.renamedExpr(expr.Low)
+		}
+		if expr.High != nil {
+			ret.High = state.renamedExpr(expr.High)
+		}
+		if expr.Max != nil {
+			ret.Max = state.renamedExpr(expr.Max)
+		}
+		return &ret
+	case *ast.CallExpr:
+		ret := *expr
+		ret.Args = state.renamedList(expr.Args)
+		return &ret
+	case *ast.KeyValueExpr:
+		ret := *expr
+		ret.Value = state.renamedExpr(expr.Value)
+		return &ret
+	case *ast.CompositeLit:
+		ret := *expr
+		ret.Elts = state.renamedList(expr.Elts)
+		return &ret
+	}
+	return expr
+}
+
+//Adds the links between iterations, they always hold
+func appendMust(stmts []ast.Node, labels []ExecutionLabel, links []ast.Node) ([]ast.Node, []ExecutionLabel) {
+	for _, link := range links {
+		stmts = append(stmts, link)
+		labels = append(labels, Must)
+	}
+	return stmts, labels
+}
+
+func copyNodes(nodes []ast.Node) []ast.Node {
+	return append(make([]ast.Node, 0, len(nodes)), nodes...)
+}
+
+func copyLabels(labels []ExecutionLabel) []ExecutionLabel {
+	return append(make([]ExecutionLabel, 0, len(labels)), labels...)
+}
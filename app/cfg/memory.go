@@ -0,0 +1,372 @@
+package cfg
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//---------- Memory of the constraints --------------
+//Fields are variables of their own named after the variable holding them
+//(F.s.Count), what a pointer points to is named after it (*F.p) and so
+//are the fields reached through it (F.p.Count). A pointer parameter
+//points to what its argument does, the fields of the caller's variable
+//are the ones it reads and writes, so do copies of a pointer and
+//addresses assigned to one. Pointers have a flag telling if they are nil,
+//named after them (F.p == nil).
+
+//Location of the memory the expression reads, empty when it's not a
+//variable. Pointers dereferenced to reach it are added to derefs
+func (s *ssaBuilder) location(expr ast.Expr, fn *FnWrapper, versions map[string]*Value, derefs *[]ast.Expr) string {
+	switch expr := unparen(expr).(type) {
+	case *ast.Ident:
+		if expr.Obj == nil || expr.Obj.Kind != ast.Var {
+			return ""
+		}
+		if fn != nil {
+			return fn.qualified(expr)
+		}
+		return expr.Name
+	case *ast.SelectorExpr:
+		var base string
+		if isPointer(expr.X) {
+			base, _ = s.pointee(expr.X, fn, versions, derefs)
+		} else {
+			base = s.location(expr.X, fn, versions, derefs)
+		}
+		if base == "" {
+			return ""
+		}
+		return base + "." + expr.Sel.Name
+	case *ast.StarExpr:
+		if !isPointer(expr.X) {
+			return ""
+		}
+		base, addressed := s.pointee(expr.X, fn, versions, derefs)
+		if base == "" || addressed {
+			return base
+		}
+		return "*" + base
+	}
+	return ""
+}
+
+//target is what a pointer points to, with the pointers dereferenced to
+//reach it
+type target struct {
+	name      string
+	addressed bool //the variable itself, p := &x points to x
+	derefs    []ast.Expr
+}
+
+//Name of what the pointer points to and whether it is the variable
+//itself (p := &x points to x)
+func (s *ssaBuilder) pointee(expr ast.Expr, fn *FnWrapper, versions map[string]*Value, derefs *[]ast.Expr) (string, bool) {
+	if id, ok := unparen(expr).(*ast.Ident); ok && fn != nil && id.Obj != nil {
+		//a copy of a pointer, or an address, points where its version
+		//was assigned to
+		if alias, ok := s.aliases[versions[fn.qualified(id)]]; ok {
+			if derefs != nil {
+				*derefs = append(*derefs, alias.derefs...)
+			}
+			return alias.name, alias.addressed
+		}
+		//parameters point where their arguments do
+		if arg, ok := fn.ParamsToArgs[id.Obj]; ok {
+			if caller := fn.caller(); caller != nil {
+				if addr, ok := unparen(arg).(*ast.UnaryExpr); ok && addr.Op == token.AND {
+					return s.location(addr.X, caller, versions, derefs), true
+				}
+				if isPointer(arg) {
+					return s.pointee(arg, caller, versions, derefs)
+				}
+			}
+		}
+	}
+	if derefs != nil {
+		*derefs = append(*derefs, s.convert(copyAST(expr), fn, versions).(ast.Expr))
+	}
+	return s.location(expr, fn, versions, derefs), false
+}
+
+//What the pointers on the left point to when the right side is another
+//pointer or an address, by the object of the pointer
+func (s *ssaBuilder) aliasesOf(assign *ast.AssignStmt, fn *FnWrapper, versions map[string]*Value) map[*ast.Object]target {
+	aliases := make(map[*ast.Object]target)
+	if fn == nil || len(assign.Lhs) != len(assign.Rhs) {
+		return aliases
+	}
+	for i, lhs := range assign.Lhs {
+		id, ok := lhs.(*ast.Ident)
+		if !ok || id.Obj == nil {
+			continue
+		}
+		switch rhs := unparen(assign.Rhs[i]).(type) {
+		case *ast.UnaryExpr:
+			if rhs.Op != token.AND {
+				continue
+			}
+			if name := s.location(rhs.X, fn, versions, nil); name != "" {
+				aliases[id.Obj] = target{name: name, addressed: true}
+			}
+		case *ast.Ident:
+			if !isPointer(rhs) {
+				continue
+			}
+			derefs := make([]ast.Expr, 0)
+			if name, addressed := s.pointee(rhs, fn, versions, &derefs); name != "" {
+				aliases[id.Obj] = target{name: name, addressed: addressed, derefs: derefs}
+			}
+		}
+	}
+	return aliases
+}
+
+//Identifier standing for the version of the location the expression reads
+func (s *ssaBuilder) memory(name string, expr ast.Expr, versions map[string]*Value) *ast.Ident {
+	if value, ok := versions[name]; ok {
+		return &ast.Ident{Name: value.Name(), NamePos: expr.Pos(), Obj: value.Obj}
+	}
+	return &ast.Ident{Name: name, NamePos: expr.Pos(), Obj: locationObj(name, expr)}
+}
+
+//Object of the location, declared with the type of the expression so it
+//gets the sort of it. Its data is the variable holding it
+func locationObj(name string, expr ast.Expr) *ast.Object {
+	return &ast.Object{Kind: ast.Var, Name: name, Decl: &ast.Field{Type: typeOf(expr)}, Data: rootObject(expr)}
+}
+
+//The locations reached through the variable are other memory once it is
+//assigned, they get new versions
+func (s *ssaBuilder) reassigned(name string, versions map[string]*Value, pos token.Pos) []*Value {
+	stale := make([]string, 0)
+	for location := range s.locations {
+		if strings.HasPrefix(location, name+".") || location == "*"+name || strings.HasPrefix(location, "*"+name+".") {
+			stale = append(stale, location)
+		}
+	}
+	sort.Strings(stale)
+	defs := make([]*Value, 0, len(stale))
+	for _, location := range stale {
+		value := s.define(location, s.locations[location], pos)
+		versions[location] = value
+		defs = append(defs, value)
+	}
+	return defs
+}
+
+//Type the expression is declared with, nil when it isn't spelled out
+func typeOf(expr ast.Expr) ast.Expr {
+	switch expr := unparen(expr).(type) {
+	case *ast.Ident:
+		if expr.Obj == nil {
+			return nil
+		}
+		switch decl := expr.Obj.Decl.(type) {
+		case *ast.Field:
+			return decl.Type
+		case *ast.ValueSpec:
+			if decl.Type != nil {
+				return decl.Type
+			}
+			for i, name := range decl.Names {
+				if name.Obj == expr.Obj && i < len(decl.Values) {
+					return valueType(decl.Values[i])
+				}
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range decl.Lhs {
+				lhs, ok := lhs.(*ast.Ident)
+				if !ok || lhs.Obj != expr.Obj {
+					continue
+				}
+				if len(decl.Lhs) == len(decl.Rhs) {
+					return valueType(decl.Rhs[i])
+				}
+				//v, ok := m[k]
+				if index, ok := unparen(decl.Rhs[0]).(*ast.IndexExpr); ok && len(decl.Lhs) == 2 && len(decl.Rhs) == 1 {
+					if m, ok := typeOf(index.X).(*ast.MapType); ok && i == 0 {
+						return m.Value
+					} else if ok {
+						return ast.NewIdent("bool")
+					}
+				}
+			}
+		}
+	case *ast.SelectorExpr:
+		if st := structOfType(typeOf(expr.X)); st != nil {
+			for _, field := range st.Fields.List {
+				for _, name := range field.Names {
+					if name.Name == expr.Sel.Name {
+						return field.Type
+					}
+				}
+			}
+		}
+	case *ast.StarExpr:
+		if ptr, ok := typeOf(expr.X).(*ast.StarExpr); ok {
+			return ptr.X
+		}
+	}
+	return nil
+}
+
+//Type of the value assigned, a copy of a variable has its type
+func valueType(expr ast.Expr) ast.Expr {
+	if id, ok := unparen(expr).(*ast.Ident); ok {
+		return typeOf(id)
+	}
+	return literalType(expr)
+}
+
+//Type of the value made by a literal, an address, new or make
+func literalType(expr ast.Expr) ast.Expr {
+	switch expr := unparen(expr).(type) {
+	case *ast.CompositeLit:
+		return expr.Type
+	case *ast.UnaryExpr:
+		if expr.Op != token.AND {
+			return nil
+		}
+		if t := literalType(expr.X); t != nil {
+			return &ast.StarExpr{X: t}
+		}
+		if t := typeOf(expr.X); t != nil {
+			return &ast.StarExpr{X: t}
+		}
+	case *ast.CallExpr:
+		if isBuiltin(expr.Fun, "new") && len(expr.Args) == 1 {
+			return &ast.StarExpr{X: expr.Args[0]}
+		}
+		if isBuiltin(expr.Fun, "make") && len(expr.Args) > 0 {
+			return expr.Args[0]
+		}
+	}
+	return nil
+}
+
+//Struct the type is or points to, declared in the file
+func structOfType(t ast.Expr) *ast.StructType {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return structOfType(t.X)
+	case *ast.StructType:
+		return t
+	case *ast.Ident:
+		if t.Obj != nil {
+			if spec, ok := t.Obj.Decl.(*ast.TypeSpec); ok {
+				return structOfType(spec.Type)
+			}
+		}
+	}
+	return nil
+}
+
+func isPointer(expr ast.Expr) bool {
+	_, ok := typeOf(expr).(*ast.StarExpr)
+	return ok
+}
+
+//Whether the expression is the nil identifier, also once it is named
+//after the function it is in
+func isNil(expr ast.Expr) bool {
+	id, ok := unparen(expr).(*ast.Ident)
+	return ok && id.Obj == nil && isBuiltin(id, "nil")
+}
+
+//Whether the value is the address of a new variable, those aren't nil
+func isAddress(expr ast.Expr) bool {
+	switch expr := unparen(expr).(type) {
+	case *ast.UnaryExpr:
+		return expr.Op == token.AND
+	case *ast.CallExpr:
+		return isBuiltin(expr.Fun, "new")
+	}
+	return false
+}
+
+//Flag of the pointer telling if it is nil
+func (c *z3Converter) nilFlag(expr ast.Expr) *z3.AST {
+	return c.ctx.Const(c.ctx.Symbol(types.ExprString(expr)+" == nil"), c.ctx.BoolSort())
+}
+
+//Comparison of a pointer to nil, nil for the other ones
+func (c *z3Converter) nilComparison(expr *ast.BinaryExpr) *z3.AST {
+	if expr.Op != token.EQL && expr.Op != token.NEQ {
+		return nil
+	}
+	var flag *z3.AST
+	switch {
+	case isNil(expr.Y) && isPointer(expr.X):
+		flag = c.nilFlag(expr.X)
+	case isNil(expr.X) && isPointer(expr.Y):
+		flag = c.nilFlag(expr.Y)
+	default:
+		return nil
+	}
+	if expr.Op == token.NEQ {
+		return flag.Not()
+	}
+	return flag
+}
+
+//Assignment to a pointer, the flag is the one of the value
+func (c *z3Converter) pointerAssignment(lhs, rhs ast.Expr) *z3.AST {
+	flag := c.nilFlag(lhs)
+	switch {
+	case isNil(rhs):
+		return flag
+	case isAddress(rhs):
+		return flag.Not()
+	case isPointer(rhs):
+		return flag.Eq(c.nilFlag(rhs))
+	}
+	return nil
+}
+
+//Constant of the field or pointed value, with the sort of its type
+func (c *z3Converter) location(name string, expr ast.Expr, obj *ast.Object) *z3.AST {
+	if obj == nil {
+		t := typeOf(expr)
+		if t == nil {
+			return nil
+		}
+		obj = locationObj(name, expr)
+	}
+	return c.convert(&ast.Ident{Name: name, Obj: obj})
+}
+
+//Conditions of the pointers the block dereferences to be nil
+func (b *BlockWrapper) nilPanics() []ast.Expr {
+	conditions := make([]ast.Expr, 0)
+	if b.SSA == nil {
+		return conditions
+	}
+	for _, ptr := range b.SSA.derefs {
+		conditions = append(conditions, &ast.BinaryExpr{X: ptr, Op: token.EQL, Y: ast.NewIdent("nil")})
+	}
+	return conditions
+}
+
+//DescribeAssignment is how the input is shown, the pointers by whether
+//they are nil (s == nil, s != nil) and the keys by whether the map has
+//them (k in m, k not in m)
+func DescribeAssignment(name string, value *z3.AST) string {
+	if strings.HasSuffix(name, " == nil") {
+		if value.String() == "true" {
+			return name
+		}
+		return strings.TrimSuffix(name, " == nil") + " != nil"
+	}
+	if isMembership(name) {
+		if value.String() == "true" {
+			return name
+		}
+		return strings.Replace(name, " in ", " not in ", 1)
+	}
+	return name + " = " + value.String()
+}
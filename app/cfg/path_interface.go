@@ -1,9 +1,15 @@
 package cfg
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
-	"reflect"
+	"go/printer"
+	"go/token"
+	"hash/fnv"
+	"sort"
+	"sourcecrawler/app/model"
+	"time"
 )
 
 // ---- Represents a possible execution path --------
@@ -12,37 +18,149 @@ type Path struct {
 	ExecStatus	[]ExecutionLabel			//Parallel array with Expressions
 	Stmts       map[ast.Node]ExecutionLabel 
 	DidExecute	ExecutionLabel  			//Determine if an entire branch has not executed (based on absence of log stmts)
-	CopyExpressions []ast.Node
-	CopyExecStatus []ExecutionLabel
+	Logs		[]LogEvent				//Log statements in the order they run
+	Alignment	*LogAlignment			//Logs matched with the ones observed, set as the path is added
 }
 
 //List of paths
 type PathList struct {
-	Paths   []Path
-	SsaInts map[string]int
-	Regexes []string //List of all regex strings in the paths
+	Paths     []Path
+	SsaInts   map[string]int
+	Regexes   []string //List of all regex strings in the paths
+	MaxPaths  int      //Most paths kept, the likeliest ones, 0 is no limit
+	MaxDepth  int      //Paths going through more blocks are dropped, 0 is no limit
+	LoopBound int      //Most iterations of a loop in a path
+	//The traversal stops after it with the paths found, 0 is no limit
+	Timeout time.Duration
+	//A limit dropped paths or stopped the traversal
+	Truncated bool
+	//Every log that fired is in the logs given, the ones missing didn't
+	CompleteLogs bool
+	//Levels that aren't logged, missing logs at these levels say nothing
+	DisabledLevels []string
+	//Line of the exception in the block the traversal starts at, what
+	//follows it in the block didn't run. 0 is the end of the block
+	ExceptionLine int
+	//Logs observed, the paths are aligned with them as they are added
+	//so the limits keep the ones that can have run
+	Observed []model.LogType
+	//Block of a panic a deferred function recovered, the deferred calls
+	//after it are reached from it alone
+	Recovered Wrapper
 	//StkTrcInfo	[]handler.StackTraceStruct
+
+	loops     []*Loop
+	loopInfos map[*Loop]*loopInfo
+	hashes    map[uint64]bool //of the paths added, kept or not
+	depth     int             //blocks of the path being gathered
+	exception token.Pos       //node of the exception in the first block
+	fromGraph []bool          //the observed logs the graph can have written
+	deadline  time.Time
+	//deferred calls of the path being gathered and their defer statements
+	deferred, registered map[*ast.DeferStmt]int
 }
 
-//Adds a path to the list
+//Adds a path to the list, once the list is full it takes the place of
+//the least likely path if it's likelier
 func (p *PathList) AddNewPath(path Path) {
-	do := true
-	for _, aPath := range p.Paths {
-		if reflect.DeepEqual(aPath, path) {
-			do = false
-			break
-		}
+	if p.hashes == nil {
+		p.hashes = make(map[uint64]bool)
 	}
-	if do {
+	if p.Observed != nil {
+		path.align(p, p.Observed, p.fromGraph)
+	}
+	hash := path.hash()
+	if p.hashes[hash] {
+		return
+	}
+	p.hashes[hash] = true
+	if p.MaxPaths <= 0 || len(p.Paths) < p.MaxPaths {
 		p.Paths = append(p.Paths, path)
+		return
+	}
+	p.Truncated = true
+	worst := 0
+	for i := range p.Paths {
+		if p.Paths[worst].likelier(p.Paths[i]) {
+			worst = i
+		}
+	}
+	if path.likelier(p.Paths[worst]) {
+		p.Paths[worst] = path
+	}
+}
+
+//Sorts the paths from the likeliest
+func (p *PathList) prioritize() {
+	sort.SliceStable(p.Paths, func(i, j int) bool {
+		return p.Paths[i].likelier(p.Paths[j])
+	})
+}
+
+//Whether the traversal can't go further, past the time or the depth
+func (p *PathList) stopped() bool {
+	if !p.deadline.IsZero() && time.Now().After(p.deadline) {
+		p.Truncated = true
+		return true
+	}
+	if p.MaxDepth > 0 && p.depth >= p.MaxDepth {
+		p.Truncated = true
+		return true
+	}
+	return false
+}
+
+//Whether the path is more likely to have run than the other: paths that
+//ran before the ones that didn't, then with fewer conditions that may not
+//have held, then the shortest
+func (path Path) likelier(other Path) bool {
+	if (path.DidExecute == MustNot) != (other.DidExecute == MustNot) {
+		return other.DidExecute == MustNot
+	}
+	if mays, others := path.mays(), other.mays(); mays != others {
+		return mays < others
+	}
+	return len(path.Expressions) < len(other.Expressions)
+}
+
+//Number of nodes of the path that may not have run
+func (path Path) mays() int {
+	n := 0
+	for _, label := range path.ExecStatus {
+		if label == May {
+			n++
+		}
+	}
+	return n
+}
+
+//Hash of the nodes of the path with where they are and their labels,
+//and of its logs
+func (path Path) hash() uint64 {
+	h := fnv.New64a()
+	fset := token.NewFileSet()
+	for i, node := range path.Expressions {
+		var bf bytes.Buffer
+		printer.Fprint(&bf, fset, node)
+		fmt.Fprintf(h, "%s@%d", bf.String(), node.Pos())
+		if i < len(path.ExecStatus) {
+			fmt.Fprintf(h, ":%d", path.ExecStatus[i])
+		}
+		h.Write([]byte{0})
+	}
+	fmt.Fprintf(h, "%d", path.DidExecute)
+	for _, log := range path.Logs {
+		fmt.Fprintf(h, "|%s", log.Position)
 	}
+	return h.Sum64()
 }
 
 //Instantiates a new instance of a path list
 func CreateNewPath() *PathList {
 	return &PathList{
-		Paths:   make([]Path, 0),
-		SsaInts: make(map[string]int),
+		Paths:     make([]Path, 0),
+		SsaInts:   make(map[string]int),
+		LoopBound: DefaultLoopBound,
 	}
 }
 
@@ -6,139 +6,25 @@ import (
 	"go/ast"
 	"go/printer"
 	"go/token"
+	"go/types"
 	"sourcecrawler/app/helper"
 	"strconv"
 	"strings"
 
 	"github.com/mitchellh/go-z3"
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/cfg"
 )
 
-// Deletes the keys of any assignments that aren't user input (were assigned a value)
+// Deletes the keys of any assignments that aren't user input (were assigned
+// a value or don't come from a source, see InputSources)
 func FilterToUserInput(block Wrapper, nodes []ast.Node, assignments map[string]*z3.AST) {
-	isAssigned := make(map[*ast.Object]bool)
-
-	for _, node := range nodes {
-		ast.Inspect(node, func(id ast.Node) bool {
-			if id, ok := id.(*ast.Ident); ok && id.Obj != nil && id.Obj.Decl != nil {
-				switch id.Obj.Decl.(type) {
-				case *ast.AssignStmt:
-					assigned := hasAssignment(nodes, id)
-					if _, ok := assignments[id.Name]; ok && assigned {
-						delete(assignments, id.Name)
-					}
-				case *ast.Field:
-					if _, ok := assignments[id.Name]; ok {
-						filterFnArgsUserInput(block, nodes, isAssigned, id)
-						if assigned, ok := isAssigned[id.Obj]; ok {
-							if assigned {
-								delete(assignments, id.Name)
-							}
-						}
-					}
-				}
-			}
-			return true
-		})
-		// switch node := node.(type) {
-		// case *ast.AssignStmt:
-		// 	for _, l := range node.Lhs {
-		// 		if id, ok := l.(*ast.Ident); ok {
-		// 			if _, ok := assignments[id.Name]; ok {
-		// 				delete(assignments, id.Name)
-		// 			}
-		// 		}
-		// 	}
-		// default:
-
-		// }
-	}
-}
-
-func hasAssignment(nodes []ast.Node, id *ast.Ident) bool {
-	isAssigned := false
-	for _, node := range nodes {
-		ast.Inspect(node, func(node ast.Node) bool {
-			switch node := node.(type) {
-			case *ast.AssignStmt:
-				for i, l := range node.Lhs {
-					if foundID, ok := l.(*ast.Ident); ok && id.Name == foundID.Name {
-						switch r := node.Rhs[i].(type) {
-						case *ast.SelectorExpr:
-							if id, ok := r.X.(*ast.Ident); ok {
-								if id.Name == "os" {
-									args := false
-									ast.Inspect(r.Sel, func(node ast.Node) bool {
-										if argsID, ok := node.(*ast.Ident); ok {
-											if argsID.Name == "Args" {
-												args = true
-												return false
-											}
-										}
-										return true
-									})
-									if !args {
-										isAssigned = true
-									}
-								}
-							}
-						default:
-							isAssigned = true
-						}
-						return false
-					}
-				}
-			}
-			return true
-		})
-	}
-	return isAssigned
-}
-
-func filterFnArgsUserInput(block Wrapper, constraints []ast.Node, isAssigned map[*ast.Object]bool, id *ast.Ident) {
-	if block == nil || id.Obj == nil || id.Obj.Decl == nil {
-		return
-	}
-
-	switch block := block.(type) {
-	case *FnWrapper:
-		var fnType *ast.FuncType
-		switch fn := block.Fn.(type) {
-		case *ast.FuncDecl:
-			fnType = fn.Type
-		case *ast.FuncLit:
-			fnType = fn.Type
+	sources := InputSources(block, nodes, assignments)
+	for name := range assignments {
+		if _, ok := sources[name]; !ok {
+			delete(assignments, name)
 		}
-
-		if fnType != nil || fnType.Params == nil {
-			return
-		}
-
-		foundArg := false
-		for _, field := range fnType.Params.List {
-			if f, ok := id.Obj.Decl.(*ast.Field); ok && field == f {
-				foundArg = true
-				arg := block.ParamsToArgs[id.Obj]
-				if argID, ok := arg.(*ast.Ident); ok && id.Obj != nil {
-					if _, ok := isAssigned[argID.Obj]; !ok {
-						// return isUserInput[id.Obj]
-						assigned := hasAssignment(constraints, argID)
-						isAssigned[argID.Obj] = assigned
-					}
-					isAssigned[id.Obj] = isAssigned[argID.Obj]
-					// return isUserInput[id.Obj]
-				}
-				return
-			}
-		}
-
-		if !foundArg {
-			filterFnArgsUserInput(block.GetOuterWrapper(), constraints, isAssigned, id)
-		}
-	case *BlockWrapper:
-		filterFnArgsUserInput(block.GetOuterWrapper(), constraints, isAssigned, id)
 	}
-	// return false
 }
 
 func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AST {
@@ -146,19 +32,46 @@ func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AS
 		// fmt.Println("returning nil")
 		return nil
 	}
+	c := &z3Converter{ctx: ctx, fset: fset}
+	e := c.convert(expr)
+	//the results of divisions and bitwise operations are constants
+	//defined by the constraints, they go with the condition using them
+	if e != nil && len(c.defs) > 0 && isCondition(expr) {
+		e = e.And(c.defs...)
+	}
+	return e
+}
+
+func (c *z3Converter) convert(expr ast.Node) *z3.AST {
+	if expr == nil {
+		return nil
+	}
+	ctx, fset := c.ctx, c.fset
 	// fmt.Println("checking", expr, reflect.TypeOf(expr))
 	switch expr := expr.(type) {
 	case *ast.AssignStmt:
+		//x += y isn't an equation, it is lowered to x = x + y first
+		if _, ok := assignOps[expr.Tok]; ok {
+			return nil
+		}
 		var e *z3.AST
 		for i, l := range expr.Lhs {
+			if i >= len(expr.Rhs) {
+				//v, ok := x.(T) isn't known
+				break
+			}
 			r := expr.Rhs[i]
-			lhs := ConvertExprToZ3(ctx, l, fset)
-			rhs := ConvertExprToZ3(ctx, r, fset)
-			if lhs != nil && rhs != nil {
+			var eq *z3.AST
+			if isPointer(l) {
+				eq = c.pointerAssignment(l, r)
+			} else if lhs, rhs := c.convert(l), c.convert(r); lhs != nil && rhs != nil {
+				eq = lhs.Eq(rhs)
+			}
+			if eq != nil {
 				if e == nil {
-					e = lhs.Eq(rhs)
+					e = eq
 				} else {
-					e = e.And(lhs.Eq(rhs))
+					e = e.And(eq)
 				}
 			}
 		}
@@ -166,14 +79,28 @@ func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AS
 	case *ast.BasicLit:
 		switch expr.Kind {
 		case token.INT:
-			v, err := strconv.Atoi(expr.Value)
+			v, err := strconv.ParseInt(expr.Value, 0, 64)
 			// fmt.Println("literal value", v)
 			if err == nil {
-				return ctx.Int(v, ctx.IntSort())
+				return c.integer(v)
 			}
 		}
 		return nil
 	case *ast.Ident:
+		//nil is the zero value of errors, pointers and interfaces
+		if expr.Obj == nil && isBuiltin(expr, "nil") {
+			return ctx.Int(0, ctx.IntSort())
+		}
+		if expr.Obj == nil && isBuiltin(expr, "true") {
+			return ctx.True()
+		}
+		if expr.Obj == nil && isBuiltin(expr, "false") {
+			return ctx.False()
+		}
+		//pointers are only compared to nil, by their flag
+		if isPointer(expr) {
+			return nil
+		}
 		if expr.Obj != nil {
 			// fmt.Println("nonnil obj")
 			switch decl := expr.Obj.Decl.(type) {
@@ -197,11 +124,23 @@ func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AS
 					//case *ast.StarExpr:
 					//case *ast.SelectorExpr:
 				}
+			case *ast.ValueSpec:
+				//var n int
+				var bf bytes.Buffer
+				printer.Fprint(&bf, fset, expr)
+				if name := typeName(expr); name == "bool" {
+					return ctx.Const(ctx.Symbol(bf.String()), ctx.BoolSort())
+				} else if strings.Contains(name, "int") {
+					return ctx.Const(ctx.Symbol(bf.String()), ctx.IntSort())
+				}
 			case *ast.AssignStmt:
 				for _, id := range decl.Lhs {
 					if id.(*ast.Ident).Obj == expr.Obj {
 						var bf bytes.Buffer
 						printer.Fprint(&bf, fset, expr)
+						if typeName(expr) == "bool" {
+							return ctx.Const(ctx.Symbol(bf.String()), ctx.BoolSort())
+						}
 						return ctx.Const(ctx.Symbol(bf.String()), ctx.IntSort())
 						// rhs := decl.Rhs[i]
 						// switch rhs := rhs.(type) {
@@ -228,7 +167,7 @@ func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AS
 		}
 		return nil
 	case *ast.UnaryExpr:
-		inner := ConvertExprToZ3(ctx, expr.X, fset)
+		inner := c.convert(expr.X)
 		switch expr.Op {
 		case token.NOT:
 			return inner.Not()
@@ -239,8 +178,14 @@ func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AS
 		}
 		return inner
 	case *ast.BinaryExpr:
-		left := ConvertExprToZ3(ctx, expr.X, fset)
-		right := ConvertExprToZ3(ctx, expr.Y, fset)
+		if flag := c.nilComparison(expr); flag != nil {
+			return flag
+		}
+		left := c.convert(expr.X)
+		right := c.convert(expr.Y)
+		if assert, ok := expr.X.(*ast.TypeAssertExpr); ok && assert.Type == nil {
+			right = dynamicType(ctx, expr.Y)
+		}
 		if left == nil || right == nil {
 			// fmt.Println("can't combine", left, right)
 			return nil
@@ -260,12 +205,7 @@ func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AS
 		case token.EQL:
 			return left.Eq(right)
 		case token.NEQ:
-			//if int, Eq().Not()
-			if _, err := strconv.Atoi(right.String()); err == nil {
-				return left.Eq(right).Not()
-			}
-			//if variable, Distinct()
-			return left.Distinct(right)
+			return left.Eq(right).Not()
 		case token.LSS:
 			return left.Lt(right)
 		case token.GTR:
@@ -274,45 +214,61 @@ func ConvertExprToZ3(ctx *z3.Context, expr ast.Node, fset *token.FileSet) *z3.AS
 			return left.Le(right)
 		case token.GEQ:
 			return left.Ge(right)
-		case token.XOR:
-			return left.Xor(right)
+		case token.QUO:
+			quotient, _ := c.divide(expr, left, right)
+			return quotient
+		case token.REM:
+			_, remainder := c.divide(expr, left, right)
+			return remainder
+		case token.AND, token.OR, token.XOR, token.AND_NOT:
+			return c.bitwise(expr, left, right)
+		case token.SHL, token.SHR:
+			return c.shift(expr, left)
 		}
 	case *ast.ParenExpr:
-		return ConvertExprToZ3(ctx, expr.X, fset)
+		return c.convert(expr.X)
+	case *ast.CallExpr:
+		for _, builtin := range []string{"len", "cap"} {
+			if isBuiltin(expr.Fun, builtin) && len(expr.Args) == 1 {
+				return c.length(builtin, expr.Args[0])
+			}
+		}
 	case *ast.SelectorExpr:
-		oldName := expr.Sel.Name
-		//exprStr(expr.X)
-		expr.Sel.Name = fmt.Sprintf("%v.%v", fmt.Sprint(expr.X), oldName)
-		ident := ConvertExprToZ3(ctx, expr.Sel, fset)
-		expr.Sel.Name = oldName
-		return ident
+		//fields are named after the variable holding them
+		return c.location(types.ExprString(expr), expr, expr.Sel.Obj)
 	case *ast.StarExpr:
-		return ConvertExprToZ3(ctx, expr.X, fset)
+		return c.location(types.ExprString(expr), expr, nil)
+	case *ast.TypeAssertExpr:
+		//v.(type) is the dynamic type of v
+		if expr.Type == nil {
+			var bf bytes.Buffer
+			printer.Fprint(&bf, fset, expr)
+			return ctx.Const(ctx.Symbol(bf.String()), ctx.IntSort())
+		}
 	}
 	return nil
 }
 
-func SSAconversion(expr ast.Expr, ssaInts map[string]int) map[string]struct{} {
-	changedVars := make(map[string]struct{})
-	ast.Inspect(expr, func(node ast.Node) bool {
-		switch node := node.(type) {
-		case *ast.Ident:
-			if i, ok := ssaInts[node.Name]; ok && i != 0 {
-				if !strings.Contains("0123456789", string(node.Name[0])) {
-					changedVars[node.Name] = struct{}{}
-					node.Name = fmt.Sprint(i, node.Name)
-				}
-			}
-		}
-		return true
-	})
-	return changedVars
+//Operators of the shorthand assignments (x op= y is x = x op y)
+var assignOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN:     token.ADD,     // +=
+	token.SUB_ASSIGN:     token.SUB,     // -=
+	token.MUL_ASSIGN:     token.MUL,     // *=
+	token.QUO_ASSIGN:     token.QUO,     // /=
+	token.REM_ASSIGN:     token.REM,     // %=
+	token.AND_ASSIGN:     token.AND,     // &=
+	token.OR_ASSIGN:      token.OR,      // |=
+	token.XOR_ASSIGN:     token.XOR,     // ^=
+	token.SHL_ASSIGN:     token.SHL,     // <<=
+	token.SHR_ASSIGN:     token.SHR,     // >>=
+	token.AND_NOT_ASSIGN: token.AND_NOT, // &^=
 }
 
 // Converts shorthand assignment forms (or IncDec) to their
-// lengthier regular token.ASSIGN counterpart.
+// lengthier regular token.ASSIGN counterpart. The calls on the
+// right are replaced by their results (see callResults).
 //
-// Note: The identifier is copied because otherwise the
+// Note: The left hand side is copied because otherwise the
 // left and right hand side would always share the exact same
 // identifier which we would not want.
 func RessignmentConversion(node ast.Node, fset *token.FileSet) (*ast.AssignStmt, bool) {
@@ -323,57 +279,31 @@ func RessignmentConversion(node ast.Node, fset *token.FileSet) (*ast.AssignStmt,
 
 	switch node := node.(type) {
 	case *ast.AssignStmt:
-		for _, r := range node.Rhs {
-			call := false
-			ast.Inspect(r, func(node ast.Node) bool {
-				if _, ok := node.(*ast.CallExpr); ok {
-					call = true
-					return false
-				}
-				return true
-			})
-			if call {
-				return nil, false
+		rhs := callResults(node)
+		op, ok := assignOps[node.Tok]
+		if !ok {
+			if rhs == nil {
+				return node, true
 			}
+			ret := *node
+			ret.Rhs = rhs
+			return &ret, true
 		}
-
-		switch node.Tok {
-		case token.ADD_ASSIGN: // +=
-			tok = token.ADD
-		case token.SUB_ASSIGN: // -=
-			tok = token.SUB
-		case token.MUL_ASSIGN: // *=
-			tok = token.MUL
-		case token.QUO_ASSIGN: // /=
-			tok = token.SUB
-		case token.REM_ASSIGN: // %=
-			tok = token.REM
-		default:
-			return node, true
+		if rhs == nil {
+			rhs = node.Rhs
 		}
 
 		stmt.TokPos = node.TokPos
 		for i, l := range node.Lhs {
-			l, ok := l.(*ast.Ident)
-			if !ok {
-				continue
-			}
-			leftCopy := &ast.Ident{
-				NamePos: l.NamePos,
-				Name:    l.Name,
-				Obj:     l.Obj,
-			}
-			stmt.Lhs = append(stmt.Lhs, leftCopy)
-			rightCopy := &ast.Ident{
-				Name:    l.Name,
-				NamePos: l.NamePos,
-				Obj:     l.Obj,
+			if i >= len(rhs) {
+				break
 			}
+			stmt.Lhs = append(stmt.Lhs, copyAST(l).(ast.Expr))
 			bin := &ast.BinaryExpr{
-				X:     rightCopy,
+				X:     copyAST(l).(ast.Expr),
 				OpPos: node.TokPos,
-				Op:    tok,
-				Y:     node.Rhs[i],
+				Op:    op,
+				Y:     rhs[i],
 			}
 			stmt.Rhs = append(stmt.Rhs, bin)
 		}
@@ -389,16 +319,8 @@ func RessignmentConversion(node ast.Node, fset *token.FileSet) (*ast.AssignStmt,
 
 		stmt.TokPos = node.TokPos
 		stmt.Lhs = append(stmt.Lhs, node.X)
-		var id *ast.Ident
-		if node, ok := node.X.(*ast.Ident); ok {
-			id = &ast.Ident{
-				Name:    node.Name,
-				NamePos: node.NamePos,
-				Obj:     node.Obj,
-			}
-		}
 		bin := &ast.BinaryExpr{
-			X:     id,
+			X:     copyAST(node.X).(ast.Expr),
 			OpPos: node.TokPos,
 			Op:    tok,
 			Y:     &ast.BasicLit{Value: "1", Kind: token.INT, ValuePos: node.TokPos},
@@ -409,8 +331,96 @@ func RessignmentConversion(node ast.Node, fset *token.FileSet) (*ast.AssignStmt,
 	return stmt, false
 }
 
+//The right side of the assignment with its calls replaced by identifiers
+//standing for their results, nil without calls to replace. A result
+//shares the object of the variable it is assigned to, so it has the same
+//sort, calls in other operations than arithmetic stay as they are. The
+//results are named after the function with parentheses (compute(),
+//strconv.Atoi().1 for the second of many), no variable is named like them
+func callResults(assign *ast.AssignStmt) []ast.Expr {
+	//x, err := f()
+	if len(assign.Lhs) > 1 && len(assign.Rhs) == 1 {
+		call, ok := unparen(assign.Rhs[0]).(*ast.CallExpr)
+		if !ok || keepsCall(call) {
+			return nil
+		}
+		rhs := make([]ast.Expr, len(assign.Lhs))
+		for i, lhs := range assign.Lhs {
+			rhs[i] = callResult(call, fmt.Sprint(".", i), lhs)
+		}
+		return rhs
+	}
+
+	replaced := false
+	rhs := make([]ast.Expr, len(assign.Rhs))
+	for i, expr := range assign.Rhs {
+		var lhs ast.Expr
+		if i < len(assign.Lhs) {
+			lhs = assign.Lhs[i]
+		}
+		rhs[i] = astutil.Apply(copyAST(expr), func(c *astutil.Cursor) bool {
+			switch node := c.Node().(type) {
+			case *ast.CallExpr:
+				if !keepsCall(node) {
+					c.Replace(callResult(node, "", lhs))
+					replaced = true
+				}
+				return false
+			case *ast.ParenExpr:
+				return true
+			case *ast.UnaryExpr:
+				return node.Op == token.SUB || node.Op == token.ADD || node.Op == token.XOR
+			case *ast.BinaryExpr:
+				switch node.Op {
+				case token.ADD, token.SUB, token.MUL, token.QUO, token.REM, token.AND, token.OR, token.XOR, token.AND_NOT:
+					return true
+				}
+			}
+			return false
+		}, nil).(ast.Expr)
+	}
+	if !replaced {
+		return nil
+	}
+	return rhs
+}
+
+//Builtins and conversions to basic types have their own constraints or none
+func keepsCall(call *ast.CallExpr) bool {
+	id, ok := unparen(call.Fun).(*ast.Ident)
+	return ok && id.Obj == nil && types.Universe.Lookup(id.Name) != nil
+}
+
+func callResult(call *ast.CallExpr, suffix string, lhs ast.Expr) *ast.Ident {
+	result := &ast.Ident{Name: types.ExprString(call.Fun) + "()" + suffix, NamePos: call.Pos()}
+	if id, ok := lhs.(*ast.Ident); ok {
+		result.Obj = id.Obj
+	}
+	return result
+}
+
+//isCallResult tells if the identifier stands for the result of a call
+func isCallResult(id *ast.Ident) bool {
+	return strings.Contains(id.Name, "()")
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	if paren, ok := expr.(*ast.ParenExpr); ok {
+		return unparen(paren.X)
+	}
+	return expr
+}
+
 //Method to get condition, nil if not a conditional (specific to block wrapper) - used in traverse function
 func (b *BlockWrapper) GetCondition() ast.Node {
+	//Case tests build their condition from the statement
+	if b.Case != nil {
+		if cond := b.Case.Condition(); cond != nil {
+			return cond
+		}
+		return nil
+	}
+
 	//Conditional block
 	if len(b.Succs) == 2 && b.Block != nil && len(b.Block.Nodes) > 0 {
 		//conditional is last node in a block
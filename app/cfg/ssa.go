@@ -0,0 +1,594 @@
+package cfg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+//---------- SSA form of the expanded graph --------------
+
+//Value is a version of a variable. Variables are named after the function
+//they belong to (F.x), versions after the first have their number in
+//front of it (2F.x)
+type Value struct {
+	Var     string
+	Version int
+	Obj     *ast.Object
+	Pos     token.Pos //where the version is assigned
+}
+
+//Name is how the constraints refer to the value
+func (v *Value) Name() string {
+	if v.Version == 0 {
+		return v.Var
+	}
+	return fmt.Sprint(v.Version, v.Var)
+}
+
+func (v *Value) String() string {
+	return v.Name()
+}
+
+//Instr is a node of a block in ssa form. Node is a copy of the source
+//node (assignments spelled out, x += 1 is x = x + 1) with the variables
+//named by their versions, the source node isn't changed
+type Instr struct {
+	Node   ast.Node
+	Source ast.Node
+	Defs   []*Value //versions the node assigns
+}
+
+//Pos of the source node
+func (i *Instr) Pos() token.Pos {
+	return i.Source.Pos()
+}
+
+//Phi is the version a variable has at a block with more than one
+//parent, it is the version of the parent the path comes from
+type Phi struct {
+	Value *Value
+	Edges map[Wrapper]*Value
+}
+
+//SSABlock is the ssa form of a block
+type SSABlock struct {
+	Phis      []*Phi
+	Instrs    []*Instr
+	Condition ast.Node //condition of a branching block, over the versions at its end
+	out       map[string]*Value
+	derefs    []ast.Expr //pointers the block dereferences
+}
+
+//Nodes of the block in ssa form
+func (s *SSABlock) Nodes() []ast.Node {
+	nodes := make([]ast.Node, 0, len(s.Instrs))
+	for _, instr := range s.Instrs {
+		nodes = append(nodes, instr.Node)
+	}
+	return nodes
+}
+
+//ssaBuilder numbers the versions of every variable across the graph, the
+//copies of a function called twice get versions of their own
+type ssaBuilder struct {
+	versions  map[string]int
+	fset      *token.FileSet
+	locations map[string]*ast.Object //fields and pointed values read or written so far
+	derefs    []ast.Expr
+	aliases   map[*Value]target //what the versions of pointers copied or addresses point to
+}
+
+//Builds the ssa form of the blocks reachable from the root, parents come
+//before their children so a block starts from the versions its parents
+//end with. Blocks with parents ending with different versions of a
+//variable get a phi for it, so do loop headers for the variables the
+//loop assigns
+func buildSSA(root Wrapper) {
+	order := topologicalOrder(root)
+	reached := make(map[Wrapper]bool, len(order))
+	for _, w := range order {
+		reached[w] = true
+	}
+
+	s := &ssaBuilder{versions: make(map[string]int), fset: root.GetFileSet(), locations: make(map[string]*ast.Object), aliases: make(map[*Value]target)}
+	out := make(map[Wrapper]map[string]*Value, len(order))
+	for _, w := range order {
+		parents := make([]Wrapper, 0)
+		for _, parent := range w.GetParents() {
+			if reached[parent] {
+				parents = append(parents, parent)
+			}
+		}
+
+		switch w := w.(type) {
+		case *FnWrapper:
+			in := make(map[string]*Value)
+			if len(parents) > 0 {
+				in = out[parents[0]]
+			}
+			if w.Library != nil && w.Library.Panics != "" {
+				w.panics = bindCondition(w.Library.Panics, s.bindings(w, in))
+			}
+			out[w] = in
+		case *BlockWrapper:
+			w.SSA = s.block(w, parents, out)
+			out[w] = w.SSA.out
+		}
+	}
+}
+
+func (s *ssaBuilder) block(b *BlockWrapper, parents []Wrapper, out map[Wrapper]map[string]*Value) *SSABlock {
+	block := &SSABlock{Phis: make([]*Phi, 0), Instrs: make([]*Instr, 0), out: make(map[string]*Value)}
+	//the value of a variable the loop assigns comes around the back
+	//edges too, the latches aren't parents
+	carried := make(map[string]bool)
+	if b.HeadOf != nil {
+		carried = s.assignedIn(b.HeadOf)
+	}
+	switch {
+	case len(parents) == 0:
+	case len(parents) == 1 && len(carried) == 0:
+		for name, value := range out[parents[0]] {
+			block.out[name] = value
+		}
+	default:
+		block.Phis = s.phis(b, parents, out, carried)
+		for _, parent := range parents {
+			for name, value := range out[parent] {
+				block.out[name] = value
+			}
+		}
+		for _, phi := range block.Phis {
+			block.out[phi.Value.Var] = phi.Value
+		}
+	}
+
+	s.derefs = make([]ast.Expr, 0)
+	fn, _ := b.Outer.(*FnWrapper)
+	if b.isLibrary() {
+		//the constraints are bound again, to the caller's variables in ssa form
+		bound := s.bindings(fn, block.out)
+		for _, condition := range fn.Library.Returns {
+			if expr := bindCondition(condition, bound); expr != nil && len(block.Instrs) < len(b.Block.Nodes) {
+				block.Instrs = append(block.Instrs, &Instr{Node: expr, Source: b.Block.Nodes[len(block.Instrs)], Defs: make([]*Value, 0)})
+			}
+		}
+	} else if b.Block != nil {
+		for _, node := range b.Block.Nodes {
+			block.Instrs = append(block.Instrs, s.instr(node, fn, block.out))
+		}
+	}
+
+	if condition := b.GetCondition(); condition != nil {
+		if n := len(block.Instrs); n > 0 && block.Instrs[n-1].Source == condition {
+			block.Condition = block.Instrs[n-1].Node
+		} else {
+			block.Condition = s.convert(copyAST(condition), fn, block.out)
+		}
+	}
+	block.derefs = s.derefs
+	return block
+}
+
+//Phis for the variables the parents end with different versions of and
+//the carried ones, the ones a parent never assigned are at their first
+//version there
+func (s *ssaBuilder) phis(b *BlockWrapper, parents []Wrapper, out map[Wrapper]map[string]*Value, carried map[string]bool) []*Phi {
+	names := make([]string, 0)
+	objs := make(map[string]*ast.Object)
+	for _, parent := range parents {
+		for name, value := range out[parent] {
+			if _, ok := objs[name]; !ok {
+				names = append(names, name)
+				objs[name] = value.Obj
+			}
+		}
+	}
+
+	//versions are numbered in the same order every time
+	sort.Strings(names)
+	phis := make([]*Phi, 0)
+	for _, name := range names {
+		edges := make(map[Wrapper]*Value, len(parents))
+		same := true
+		for _, parent := range parents {
+			value, ok := out[parent][name]
+			if !ok {
+				value = &Value{Var: name, Obj: objs[name]}
+			}
+			edges[parent] = value
+			same = same && value == edges[parents[0]]
+		}
+		if same && !carried[name] {
+			continue
+		}
+		var pos token.Pos
+		if b.Block != nil && len(b.Block.Nodes) > 0 {
+			pos = b.Block.Nodes[0].Pos()
+		}
+		phis = append(phis, &Phi{Value: s.define(name, objs[name], pos), Edges: edges})
+	}
+	return phis
+}
+
+func (s *ssaBuilder) define(name string, obj *ast.Object, pos token.Pos) *Value {
+	s.versions[name]++
+	return &Value{Var: name, Version: s.versions[name], Obj: obj, Pos: pos}
+}
+
+//Converts a copy of the node: assignments define new versions of the
+//variables on their left, after their right side is read. Sends,
+//receives and deletes are assignments to the channel or map
+func (s *ssaBuilder) instr(node ast.Node, fn *FnWrapper, versions map[string]*Value) *Instr {
+	if instr := s.change(node, fn, versions); instr != nil {
+		return instr
+	}
+	instr := &Instr{Source: node, Defs: make([]*Value, 0)}
+	switch node.(type) {
+	case *ast.AssignStmt, *ast.IncDecStmt:
+		assign, _ := RessignmentConversion(node, s.fset)
+		if assign == nil {
+			//calls don't assign values the constraints know of
+			instr.Node = s.convert(copyAST(node), fn, versions)
+			return instr
+		}
+		aliases := s.aliasesOf(assign, fn, versions)
+		assign = copyAST(assign).(*ast.AssignStmt)
+		if m, key := s.commaOk(assign, fn, versions); m != nil {
+			assign.Rhs = []ast.Expr{element(m, key), membership(m, key)}
+		} else {
+			for i, rhs := range assign.Rhs {
+				//every call gives a new result
+				ast.Inspect(rhs, func(node ast.Node) bool {
+					if id, ok := node.(*ast.Ident); ok && isCallResult(id) {
+						instr.Defs = append(instr.Defs, s.assign(id, fn, nil))
+					}
+					return true
+				})
+				assign.Rhs[i] = s.convert(rhs, fn, versions).(ast.Expr)
+			}
+		}
+		//the values received leave the buffers, v, ok := <-ch isn't known
+		chans := receives(node)
+		for len(chans) > 0 && len(assign.Rhs) < len(assign.Lhs) {
+			assign.Rhs = append(assign.Rhs, assign.Rhs[0])
+		}
+		for _, ch := range chans {
+			lhs, rhs, defs := s.buffered(ch, token.SUB, fn, versions)
+			assign.Lhs, assign.Rhs = append(assign.Lhs, lhs...), append(assign.Rhs, rhs...)
+			instr.Defs = append(instr.Defs, defs...)
+		}
+		for i, lhs := range assign.Lhs {
+			if _, ok := lhs.(*ast.CallExpr); ok {
+				//buffers of the channels received from
+				continue
+			}
+			if index, ok := lhs.(*ast.IndexExpr); ok && isMap(index.X) {
+				//the map has the key once it is written
+				key := s.convert(index.Index, fn, versions).(ast.Expr)
+				prev := s.convert(copyAST(index.X), fn, versions).(ast.Expr)
+				if m, defs := s.redefine(index.X, fn, versions); m != nil {
+					assign.Lhs[i] = written(element(m, key), prev)
+					assign.Lhs, assign.Rhs = append(assign.Lhs, written(membership(m, key), prev)), append(assign.Rhs, ast.NewIdent("true"))
+					instr.Defs = append(instr.Defs, defs...)
+					continue
+				}
+			}
+			if id, defs := s.redefine(lhs, fn, versions); id != nil {
+				//fields and pointed values are variables of their own
+				assign.Lhs[i] = id
+				instr.Defs = append(instr.Defs, defs...)
+			} else if id := assignedIdent(lhs); id != nil {
+				//a write to an element gives a new version of the
+				//variable holding it, the rest of the left side is read
+				s.convert(lhs, fn, versions)
+				id.Name = ssaBase(id.Name)
+				instr.Defs = append(instr.Defs, s.assign(id, fn, versions))
+			} else {
+				assign.Lhs[i] = s.convert(lhs, fn, versions).(ast.Expr)
+			}
+		}
+		for _, def := range instr.Defs {
+			if alias, ok := aliases[def.Obj]; ok {
+				s.aliases[def] = alias
+			}
+		}
+		lhs, rhs := made(assign)
+		assign.Lhs, assign.Rhs = append(assign.Lhs, lhs...), append(assign.Rhs, rhs...)
+		instr.Node = assign
+	case ast.Expr, *ast.ExprStmt, *ast.ReturnStmt:
+		instr.Node = s.convert(copyAST(node), fn, versions)
+	default:
+		instr.Node = node
+	}
+	return instr
+}
+
+//Names the assigned identifier after a new version of its variable
+func (s *ssaBuilder) assign(id *ast.Ident, fn *FnWrapper, versions map[string]*Value) *Value {
+	if fn != nil {
+		id.Name = fn.qualified(id)
+	}
+	value := s.define(id.Name, id.Obj, id.Pos())
+	if versions != nil {
+		versions[id.Name] = value
+	}
+	id.Name = value.Name()
+	return value
+}
+
+//Names the variables of the copied node after their function and
+//versions, fields and pointed values become identifiers of their own
+func (s *ssaBuilder) convert(node ast.Node, fn *FnWrapper, versions map[string]*Value) ast.Node {
+	return astutil.Apply(node, func(c *astutil.Cursor) bool {
+		switch node := c.Node().(type) {
+		case *ast.FuncLit:
+			//closures are named by their own wrapper
+			return false
+		case *ast.SelectorExpr, *ast.StarExpr:
+			//methods are called on the variable
+			if call, ok := c.Parent().(*ast.CallExpr); ok && call.Fun == node {
+				return true
+			}
+			expr := node.(ast.Expr)
+			if name := s.location(expr, fn, versions, &s.derefs); name != "" {
+				if _, ok := s.locations[name]; !ok {
+					s.locations[name] = locationObj(name, expr)
+				}
+				c.Replace(s.memory(name, expr, versions))
+				return false
+			}
+		case *ast.Ident:
+			if fn != nil {
+				node.Name = fn.qualified(node)
+			}
+			if value, ok := versions[node.Name]; ok {
+				node.Name = value.Name()
+			}
+		}
+		return true
+	}, func(c *astutil.Cursor) bool {
+		//elements of maps are variables of their own
+		if index, ok := c.Node().(*ast.IndexExpr); ok && isMap(index.X) {
+			c.Replace(element(index.X, index.Index))
+		}
+		return true
+	})
+}
+
+//Arguments and results of a library function at its call, named like the caller's variables
+func (s *ssaBuilder) bindings(fn *FnWrapper, versions map[string]*Value) map[string]ast.Expr {
+	bound := make(map[string]ast.Expr, len(fn.bound))
+	caller := fn.caller()
+	for name, expr := range fn.bound {
+		bound[name] = s.convert(copyAST(expr), caller, versions).(ast.Expr)
+	}
+	return bound
+}
+
+//Variables assigned in the loop, named after their function
+func (s *ssaBuilder) assignedIn(l *Loop) map[string]bool {
+	names := make(map[string]bool)
+	for w := range l.body() {
+		b, ok := w.(*BlockWrapper)
+		if !ok || b.Block == nil || b.isLibrary() {
+			continue
+		}
+		fn, _ := b.Outer.(*FnWrapper)
+		for _, node := range b.Block.Nodes {
+			var lhs []ast.Expr
+			switch node := node.(type) {
+			case *ast.AssignStmt:
+				lhs = node.Lhs
+			case *ast.IncDecStmt:
+				lhs = []ast.Expr{node.X}
+			}
+			lhs = append(lhs, changedBy(node)...)
+			for _, expr := range lhs {
+				if name := s.location(expr, fn, nil, nil); name != "" {
+					names[name] = true
+				} else if id := assignedIdent(expr); id != nil {
+					name := id.Name
+					if fn != nil {
+						name = fn.qualified(id)
+					}
+					names[name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+//Variable changed by assigning to the expression, the one holding the
+//field or element for the ones on the left of an assignment
+func assignedIdent(expr ast.Expr) *ast.Ident {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if expr.Name == "_" {
+			return nil
+		}
+		return expr
+	case *ast.SelectorExpr:
+		return assignedIdent(expr.X)
+	case *ast.IndexExpr:
+		return assignedIdent(expr.X)
+	case *ast.StarExpr:
+		return assignedIdent(expr.X)
+	case *ast.ParenExpr:
+		return assignedIdent(expr.X)
+	}
+	return nil
+}
+
+//Wrappers reachable from the root, every one after its parents
+func topologicalOrder(root Wrapper) []Wrapper {
+	found := make([]Wrapper, 0)
+	seen := make(map[Wrapper]bool)
+	stack := []Wrapper{root}
+	for len(stack) > 0 {
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if w == nil || seen[w] {
+			continue
+		}
+		seen[w] = true
+		found = append(found, w)
+		children := w.GetChildren()
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, children[i])
+		}
+	}
+
+	waiting := make(map[Wrapper]int, len(found))
+	for _, w := range found {
+		for _, parent := range w.GetParents() {
+			if seen[parent] {
+				waiting[w]++
+			}
+		}
+	}
+	order := make([]Wrapper, 0, len(found))
+	done := make(map[Wrapper]bool, len(found))
+	var visit func(w Wrapper)
+	visit = func(w Wrapper) {
+		done[w] = true
+		order = append(order, w)
+		for _, child := range w.GetChildren() {
+			if child == nil || done[child] {
+				continue
+			}
+			if waiting[child]--; waiting[child] == 0 {
+				visit(child)
+			}
+		}
+	}
+	for _, w := range found {
+		if !done[w] && waiting[w] == 0 {
+			visit(w)
+		}
+	}
+	//a cycle would leave blocks waiting, they come last
+	for _, w := range found {
+		if !done[w] {
+			visit(w)
+		}
+	}
+	return order
+}
+
+//caller of a library function, its constraints are named after it
+func (fn *FnWrapper) caller() *FnWrapper {
+	for _, parent := range fn.Parents {
+		if caller, ok := parent.GetOuterWrapper().(*FnWrapper); ok {
+			return caller
+		}
+	}
+	return nil
+}
+
+//Nodes of the block with the versions of its variables, the source nodes
+//when the ssa form isn't built
+func (b *BlockWrapper) ssaNodes() []ast.Node {
+	if b.SSA != nil {
+		return b.SSA.Nodes()
+	}
+	if b.Block == nil {
+		return nil
+	}
+	return b.Block.Nodes
+}
+
+//Condition of the block over the versions of its variables
+func (b *BlockWrapper) ssaCondition() ast.Node {
+	if b.SSA != nil {
+		return b.SSA.Condition
+	}
+	if condition := b.GetCondition(); condition != nil {
+		fn, _ := b.Outer.(*FnWrapper)
+		return (&ssaBuilder{}).convert(copyAST(condition), fn, nil)
+	}
+	return nil
+}
+
+//Versions defined in the block in the order they are, phis first
+func (b *BlockWrapper) ssaDefs() []*Value {
+	defs := make([]*Value, 0)
+	if b.SSA == nil {
+		return defs
+	}
+	for _, phi := range b.SSA.Phis {
+		defs = append(defs, phi.Value)
+	}
+	for _, instr := range b.SSA.Instrs {
+		defs = append(defs, instr.Defs...)
+	}
+	return defs
+}
+
+//Assignments choosing the versions of the phis for a path coming from the parent
+func (b *BlockWrapper) phiLinks(parent Wrapper) []ast.Node {
+	links := make([]ast.Node, 0)
+	if b.SSA == nil {
+		return links
+	}
+	for _, phi := range b.SSA.Phis {
+		if edge, ok := phi.Edges[parent]; ok {
+			links = append(links, linkStmt(phi.Value.Obj, phi.Value.Name(), edge.Name()))
+		}
+	}
+	return links
+}
+
+//Deep copy of the syntax tree under the node. Objects, scopes and
+//comments aren't part of the tree, the copy shares them with the source
+func copyAST(node ast.Node) ast.Node {
+	if node == nil {
+		return nil
+	}
+	return copyValue(reflect.ValueOf(node)).Interface().(ast.Node)
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		switch v.Interface().(type) {
+		case *ast.Object, *ast.Scope, *ast.CommentGroup:
+			return v
+		}
+		ret := reflect.New(v.Elem().Type())
+		ret.Elem().Set(copyValue(v.Elem()))
+		return ret
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.New(v.Type()).Elem()
+		ret.Set(copyValue(v.Elem()))
+		return ret
+	case reflect.Struct:
+		ret := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			ret.Field(i).Set(copyValue(v.Field(i)))
+		}
+		return ret
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			ret.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,663 @@
+package cfg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"sourcecrawler/app/helper"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/ssa"
+)
+
+//---------- Graphs built from golang.org/x/tools/go/ssa --------------
+
+//SSAProgram is the project in the ssa form of golang.org/x/tools/go/ssa.
+//The graphs of its functions are wrapped like the ones built from go/cfg,
+//with the ssa form of every block taken from the typed ssa values
+//(phis, field addresses, calls) instead of converted from the syntax
+type SSAProgram struct {
+	Program *ssa.Program
+	Fset    *token.FileSet
+	Files   []*ast.File
+	pkgs    []*ssa.Package
+}
+
+//NewSSAProgram builds the ssa form of every package of the project,
+//packages it imports are only declared
+func NewSSAProgram(proj *helper.TypedProject) (p *SSAProgram, err error) {
+	defer func() {
+		//the builder can't make sense of code that doesn't type check
+		if r := recover(); r != nil {
+			p, err = nil, fmt.Errorf("building the ssa form of %s: %v", proj.Root, r)
+		}
+	}()
+
+	p = &SSAProgram{
+		//debug information keeps the syntax of the functions, and
+		//places the statements that don't compute anything in blocks
+		Program: ssa.NewProgram(proj.Fset, ssa.BuildSerially|ssa.GlobalDebug),
+		Fset:    proj.Fset,
+		Files:   make([]*ast.File, 0),
+		pkgs:    make([]*ssa.Package, 0),
+	}
+	created := make(map[*types.Package]bool)
+	var declare func(pkg *types.Package)
+	declare = func(pkg *types.Package) {
+		for _, imp := range pkg.Imports() {
+			if !created[imp] {
+				created[imp] = true
+				p.Program.CreatePackage(imp, nil, nil, true)
+				declare(imp)
+			}
+		}
+	}
+
+	for _, typed := range proj.Pkgs {
+		if typed.Types == nil {
+			continue
+		}
+		created[typed.Types] = true
+		//external test packages share the import path
+		importable := !strings.HasSuffix(typed.Name, "_test")
+		p.pkgs = append(p.pkgs, p.Program.CreatePackage(typed.Types, typed.Files, typed.Info, importable))
+		p.Files = append(p.Files, typed.Files...)
+	}
+	for _, pkg := range p.pkgs {
+		declare(pkg.Pkg)
+	}
+	for _, pkg := range p.pkgs {
+		pkg.Build()
+	}
+	return p, nil
+}
+
+//Root is the outermost wrapper, it holds the files of the program
+func (p *SSAProgram) Root() *FnWrapper {
+	return &FnWrapper{
+		Parents: make([]Wrapper, 0),
+		Fset:    p.Fset,
+		ASTs:    p.Files,
+	}
+}
+
+//Function finds a function or method of the package by name, nil when
+//there is none
+func (p *SSAProgram) Function(pkgName, name string) *ssa.Function {
+	for _, pkg := range p.pkgs {
+		if !strings.Contains(pkg.Pkg.Name(), pkgName) {
+			continue
+		}
+		names := make([]string, 0, len(pkg.Members))
+		for member := range pkg.Members {
+			names = append(names, member)
+		}
+		sort.Strings(names)
+
+		for _, member := range names {
+			if fn, ok := pkg.Members[member].(*ssa.Function); ok && strings.EqualFold(fn.Name(), name) {
+				return fn
+			}
+		}
+		for _, member := range names {
+			t, ok := pkg.Members[member].(*ssa.Type)
+			if !ok {
+				continue
+			}
+			for _, recv := range []types.Type{t.Type(), types.NewPointer(t.Type())} {
+				methods := p.Program.MethodSets.MethodSet(recv)
+				for i := 0; i < methods.Len(); i++ {
+					fn := p.Program.MethodValue(methods.At(i))
+					if fn != nil && fn.Pkg == pkg && strings.EqualFold(fn.Name(), name) {
+						return fn
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//NewSSAFnWrapper wraps the function, its blocks are built by ExpandSSA
+//once the wrapper has its outer wrapper
+func NewSSAFnWrapper(fn *ssa.Function) *FnWrapper {
+	w := &FnWrapper{
+		Parents:      make([]Wrapper, 0),
+		ParamsToArgs: make(map[*ast.Object]ast.Expr),
+		SSAFn:        fn,
+	}
+	if fn != nil {
+		w.Fn = fn.Syntax()
+		if w.Fn == nil {
+			w.name = fn.Name()
+		}
+	}
+	return w
+}
+
+//ExpandSSA builds the blocks of the function and of the functions it
+//calls, within the same limits as ExpandCFG. Calls of functions outside
+//of the project are replaced by their summaries
+func ExpandSSA(w *FnWrapper) {
+	e := &ssaExpander{
+		clones:   make(map[*ssa.Function]int),
+		versions: make(map[string]int),
+		files:    make(map[*token.File]*ast.File),
+	}
+	e.expand(w, make([]*FnWrapper, 0), nil, nil)
+}
+
+//ssaExpander builds the wrapped graph of a function from its ssa form,
+//copies of a function get names of their own
+type ssaExpander struct {
+	clones   map[*ssa.Function]int
+	versions map[string]int
+	files    map[*token.File]*ast.File
+}
+
+//ssaFrame is a copy of a function at one call
+type ssaFrame struct {
+	fn     *FnWrapper
+	prefix string                 //values are named after it, F or F(2)
+	addrs  map[ssa.Value]ast.Expr //what the addresses point to
+	defs   map[ssa.Value]ast.Expr //right side of the registers
+	stored map[string]ast.Expr    //values stored earlier in the block, by location
+	stores map[string]bool        //locations the function stores to
+	first  map[*ssa.BasicBlock]*BlockWrapper
+	last   map[*ssa.BasicBlock]*BlockWrapper //blocks are split at the calls
+}
+
+//Builds the blocks of the function. args are the values of the parameters
+//(and free variables) in the caller, results the caller's values for the
+//results. It returns the blocks the function returns from
+func (e *ssaExpander) expand(w *FnWrapper, stack []*FnWrapper, args []ast.Expr, results []ast.Expr) []Wrapper {
+	exits := make([]Wrapper, 0)
+	fn := w.SSAFn
+	if fn == nil || len(fn.Blocks) == 0 {
+		return exits
+	}
+
+	e.clones[fn]++
+	f := &ssaFrame{
+		fn:     w,
+		prefix: w.Name(),
+		addrs:  make(map[ssa.Value]ast.Expr),
+		defs:   make(map[ssa.Value]ast.Expr),
+		first:  make(map[*ssa.BasicBlock]*BlockWrapper),
+		last:   make(map[*ssa.BasicBlock]*BlockWrapper),
+		stores: make(map[string]bool),
+	}
+	if n := e.clones[fn]; n > 1 {
+		f.prefix = fmt.Sprintf("%s(%d)", f.prefix, n)
+	}
+	stack = append(stack, w)
+	f.locations()
+
+	for _, bb := range fn.Blocks {
+		//the recover block is only reached by panicking
+		if bb.Index != 0 && len(bb.Preds) == 0 {
+			continue
+		}
+		b := f.piece()
+		f.stored = make(map[string]ast.Expr)
+		f.first[bb] = b
+		if bb.Index == 0 {
+			w.FirstBlock = b
+			b.AddParent(w)
+			//the parameters are bound to the arguments
+			bound := make([]ssa.Value, 0, len(fn.Params)+len(fn.FreeVars))
+			for _, param := range fn.Params {
+				bound = append(bound, param)
+			}
+			for _, free := range fn.FreeVars {
+				bound = append(bound, free)
+			}
+			for i, v := range bound {
+				if i < len(args) && args[i] != nil {
+					e.assign(b, f.value(v), args[i], fn.Pos())
+				}
+			}
+		}
+
+		for _, instr := range bb.Instrs {
+			//phis are at the declaration of their variable
+			if _, ok := instr.(*ssa.Phi); !ok {
+				e.addSource(b, instr.Pos())
+			}
+			switch instr := instr.(type) {
+			case *ssa.Phi:
+				//filled in once the predecessors are built
+			case *ssa.Call:
+				if next := e.call(f, b, instr, stack); next != nil {
+					b = next
+				}
+				//the callee may have stored to any location
+				f.stored = make(map[string]ast.Expr)
+			case *ssa.Store:
+				if loc := f.location(instr.Addr); loc != nil {
+					f.stored[types.ExprString(loc)] = f.value(instr.Val)
+				}
+			case *ssa.If:
+				cond, ok := f.defs[instr.Cond]
+				if !ok {
+					cond = f.value(instr.Cond)
+				}
+				b.SSA.Condition = copyAST(cond)
+			case *ssa.Return:
+				for i, result := range instr.Results {
+					if i < len(results) && results[i] != nil {
+						e.assign(b, copyAST(results[i]).(ast.Expr), f.value(result), instr.Pos())
+					}
+				}
+				exits = append(exits, b)
+			case ssa.Value:
+				if rhs := f.rhs(instr); rhs != nil {
+					f.defs[instr] = rhs
+					e.assign(b, f.value(instr), rhs, instr.Pos())
+				}
+			}
+		}
+		f.last[bb] = b
+	}
+
+	for _, bb := range fn.Blocks {
+		if _, ok := f.first[bb]; !ok {
+			continue
+		}
+		for _, succ := range bb.Succs {
+			header, latch := f.first[succ], f.last[bb]
+			if !succ.Dominates(bb) {
+				latch.AddChild(header)
+				header.AddParent(latch)
+				continue
+			}
+			//back edges become loops instead of successors
+			if header.HeadOf == nil {
+				header.HeadOf = &Loop{Header: header}
+			}
+			header.HeadOf.Latches = append(header.HeadOf.Latches, latch)
+			latch.LatchOf = append(latch.LatchOf, header.HeadOf)
+		}
+	}
+	for _, bb := range fn.Blocks {
+		if b, ok := f.first[bb]; ok {
+			e.phis(f, bb, b)
+		}
+	}
+	return exits
+}
+
+//Every edge of a phi defines a version of it at the end of the
+//predecessor, the phi is the version of the parent the path comes from.
+//Edges coming back around a loop are carried by the loop instead
+func (e *ssaExpander) phis(f *ssaFrame, bb *ssa.BasicBlock, b *BlockWrapper) {
+	for _, instr := range bb.Instrs {
+		phi, ok := instr.(*ssa.Phi)
+		if !ok {
+			continue
+		}
+		id := f.value(phi).(*ast.Ident)
+		value := &Value{Var: id.Name, Obj: id.Obj, Pos: phi.Pos()}
+		edges := make(map[Wrapper]*Value)
+		for i, pred := range bb.Preds {
+			last, ok := f.last[pred]
+			if !ok || i >= len(phi.Edges) {
+				continue
+			}
+			e.versions[id.Name]++
+			edge := &Value{Var: id.Name, Version: e.versions[id.Name], Obj: id.Obj, Pos: phi.Pos()}
+			instr := e.assign(last, &ast.Ident{Name: edge.Name(), Obj: id.Obj}, f.value(phi.Edges[i]), phi.Pos())
+			instr.Defs = []*Value{edge}
+			if !bb.Dominates(pred) {
+				edges[last] = edge
+			}
+		}
+		b.SSA.Phis = append(b.SSA.Phis, &Phi{Value: value, Edges: edges})
+	}
+}
+
+//Appends lhs = rhs to the block
+func (e *ssaExpander) assign(b *BlockWrapper, lhs, rhs ast.Expr, pos token.Pos) *Instr {
+	instr := &Instr{
+		Node:   &ast.AssignStmt{Lhs: []ast.Expr{lhs}, Tok: token.ASSIGN, TokPos: pos, Rhs: []ast.Expr{rhs}},
+		Source: e.source(b, pos),
+		Defs:   make([]*Value, 0),
+	}
+	if id, ok := lhs.(*ast.Ident); ok {
+		instr.Defs = append(instr.Defs, &Value{Var: id.Name, Obj: id.Obj, Pos: pos})
+	}
+	b.SSA.Instrs = append(b.SSA.Instrs, instr)
+	return instr
+}
+
+//Expands the call at the end of the block, returns the block the
+//rest of the caller's block goes on in, nil when the call is left as is
+func (e *ssaExpander) call(f *ssaFrame, b *BlockWrapper, call *ssa.Call, stack []*FnWrapper) *BlockWrapper {
+	args := make([]ast.Expr, 0, len(call.Call.Args))
+	for _, arg := range call.Call.Args {
+		args = append(args, f.value(arg))
+	}
+	results := f.results(call)
+
+	var callee *FnWrapper
+	exits := make([]Wrapper, 0)
+	if fn := call.Call.StaticCallee(); fn != nil && len(fn.Blocks) > 0 && fn.Syntax() != nil {
+		callee = NewSSAFnWrapper(fn)
+		callee.SetOuterWrapper(f.fn)
+		if !callee.shouldExpand(stack) {
+			callee.summarize()
+			exits = append(exits, callee.FirstBlock)
+		} else {
+			//the bindings of a closure are its free variables
+			if closure, ok := call.Call.Value.(*ssa.MakeClosure); ok {
+				for _, binding := range closure.Bindings {
+					args = append(args, f.value(binding))
+				}
+			}
+			exits = e.expand(callee, stack, args, results)
+		}
+	} else if summary, ok := Summaries[calleeName(call)]; ok {
+		bound := make(map[string]ast.Expr)
+		for i, param := range summary.Params {
+			if i < len(args) {
+				bound[param] = args[i]
+			}
+		}
+		for i, result := range summary.Results {
+			if i < len(results) && results[i] != nil {
+				bound[result] = results[i]
+			}
+		}
+		if callee = summary.wrapBound(bound); callee == nil {
+			return nil
+		}
+		callee.SetOuterWrapper(f.fn)
+		library := callee.FirstBlock.(*BlockWrapper)
+		library.SSA = &SSABlock{Phis: make([]*Phi, 0), Instrs: make([]*Instr, 0)}
+		for _, node := range library.Block.Nodes {
+			library.SSA.Instrs = append(library.SSA.Instrs, &Instr{Node: node, Source: e.source(b, call.Pos()), Defs: make([]*Value, 0)})
+		}
+		callee.panics = callee.Panics
+		exits = append(exits, library)
+	} else {
+		return nil
+	}
+
+	b.connectCallTo(callee)
+	next := f.piece()
+	for _, exit := range exits {
+		exit.AddChild(next)
+		next.AddParent(exit)
+	}
+	return next
+}
+
+//Fills in what the addresses of the function point to and the locations
+//it stores to
+func (f *ssaFrame) locations() {
+	for _, bb := range f.fn.SSAFn.Blocks {
+		for _, instr := range bb.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.FieldAddr:
+				field := structOf(instr.X.Type()).Field(instr.Field)
+				f.addrs[instr] = &ast.SelectorExpr{X: f.value(instr.X), Sel: typedIdent(field.Name(), field.Type())}
+			case *ssa.IndexAddr:
+				f.addrs[instr] = &ast.IndexExpr{X: f.value(instr.X), Index: f.value(instr.Index)}
+			case *ssa.Alloc:
+				f.addrs[instr] = typedIdent(f.prefix+"."+instr.Comment, deref(instr.Type()))
+			case *ssa.Store:
+				if loc := f.location(instr.Addr); loc != nil {
+					f.stores[types.ExprString(loc)] = true
+				}
+			}
+		}
+	}
+}
+
+//Location the address points to, nil when it isn't known
+func (f *ssaFrame) location(addr ssa.Value) ast.Expr {
+	if loc, ok := f.addrs[addr]; ok {
+		return loc
+	}
+	if global, ok := addr.(*ssa.Global); ok {
+		return typedIdent(global.Pkg.Pkg.Name()+"."+global.Name(), deref(global.Type()))
+	}
+	return nil
+}
+
+//Values the caller gets the results of the call in, nil for the ones it
+//doesn't use
+func (f *ssaFrame) results(call *ssa.Call) []ast.Expr {
+	n := call.Call.Signature().Results().Len()
+	results := make([]ast.Expr, n)
+	if n == 1 {
+		results[0] = f.value(call)
+		return results
+	}
+	if refs := call.Referrers(); refs != nil {
+		for _, ref := range *refs {
+			if extract, ok := ref.(*ssa.Extract); ok && extract.Index < n {
+				results[extract.Index] = f.value(extract)
+			}
+		}
+	}
+	return results
+}
+
+//Name of the called function the way summaries are named (strconv.Atoi, len)
+func calleeName(call *ssa.Call) string {
+	switch fn := call.Call.Value.(type) {
+	case *ssa.Builtin:
+		return fn.Name()
+	case *ssa.Function:
+		if fn.Pkg != nil && fn.Signature.Recv() == nil {
+			return fn.Pkg.Pkg.Name() + "." + fn.Name()
+		}
+		if fn.Object() != nil && fn.Object().Pkg() != nil && fn.Signature.Recv() == nil {
+			return fn.Object().Pkg().Name() + "." + fn.Name()
+		}
+	}
+	return ""
+}
+
+//Right side of the register defined by the instruction, nil when its
+//value isn't known (calls, loads through unknown pointers)
+func (f *ssaFrame) rhs(v ssa.Value) ast.Expr {
+	switch v := v.(type) {
+	case *ssa.BinOp:
+		return &ast.BinaryExpr{X: f.value(v.X), OpPos: v.Pos(), Op: v.Op, Y: f.value(v.Y)}
+	case *ssa.UnOp:
+		switch v.Op {
+		case token.MUL:
+			//a load reads the value stored before it in the block. The
+			//location changes with the stores elsewhere in the function,
+			//its value isn't known then, loads of the others share it
+			loc := f.location(v.X)
+			if loc == nil {
+				return nil
+			}
+			if val, ok := f.stored[types.ExprString(loc)]; ok {
+				return copyAST(val).(ast.Expr)
+			}
+			if f.stores[types.ExprString(loc)] {
+				return nil
+			}
+			return copyAST(loc).(ast.Expr)
+		case token.ARROW:
+			return nil
+		}
+		return &ast.UnaryExpr{OpPos: v.Pos(), Op: v.Op, X: f.value(v.X)}
+	case *ssa.Field:
+		field := structOf(v.X.Type()).Field(v.Field)
+		return &ast.SelectorExpr{X: f.value(v.X), Sel: typedIdent(field.Name(), field.Type())}
+	case *ssa.Index:
+		return &ast.IndexExpr{X: f.value(v.X), Index: f.value(v.Index)}
+	case *ssa.Convert:
+		if sort := sortOf(v.Type()); sort != "" && sort == sortOf(v.X.Type()) {
+			return f.value(v.X)
+		}
+	case *ssa.ChangeType:
+		return f.value(v.X)
+	}
+	return nil
+}
+
+//Expression for the value: registers, parameters and free variables are
+//named after the function (F.t3, F.x), with the sort of their type
+func (f *ssaFrame) value(v ssa.Value) ast.Expr {
+	switch v := v.(type) {
+	case *ssa.Const:
+		return constExpr(v)
+	case *ssa.Function:
+		return ast.NewIdent(v.Name())
+	case *ssa.Builtin:
+		return ast.NewIdent(v.Name())
+	case *ssa.Global:
+		return ast.NewIdent(v.Pkg.Pkg.Name() + "." + v.Name())
+	}
+	return typedIdent(f.prefix+"."+v.Name(), v.Type())
+}
+
+func constExpr(c *ssa.Const) ast.Expr {
+	if c.Value == nil {
+		if c.IsNil() {
+			return ast.NewIdent("nil")
+		}
+		//zero values of structs and arrays
+		return ast.NewIdent(c.String())
+	}
+	switch c.Value.Kind() {
+	case constant.Int:
+		return &ast.BasicLit{Kind: token.INT, Value: c.Value.ExactString()}
+	case constant.Float:
+		return &ast.BasicLit{Kind: token.FLOAT, Value: c.Value.String()}
+	case constant.String:
+		return &ast.BasicLit{Kind: token.STRING, Value: c.Value.ExactString()}
+	}
+	return ast.NewIdent(c.Value.String())
+}
+
+//Identifier declared with the sort of the type, the way the z3
+//translator expects: integers, and nil-able values compared to nil, are
+//ints. Other types have no sort, constraints over them are left out
+func typedIdent(name string, t types.Type) *ast.Ident {
+	id := &ast.Ident{Name: name}
+	if sort := sortOf(t); sort != "" {
+		//sized integers keep their width for the bitwise operations
+		if b, ok := t.Underlying().(*types.Basic); ok && b.Info()&types.IsInteger != 0 {
+			sort = types.Typ[b.Kind()].Name()
+		}
+		id.Obj = &ast.Object{Kind: ast.Var, Name: name, Decl: &ast.Field{Type: ast.NewIdent(sort)}}
+	}
+	return id
+}
+
+func sortOf(t types.Type) string {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return "bool"
+		case t.Info()&types.IsInteger != 0:
+			return "int"
+		}
+	case *types.Pointer, *types.Interface, *types.Map, *types.Slice, *types.Chan, *types.Signature:
+		return "int"
+	}
+	return ""
+}
+
+func deref(t types.Type) types.Type {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
+func structOf(t types.Type) *types.Struct {
+	s, _ := deref(t).Underlying().(*types.Struct)
+	if s == nil {
+		return types.NewStruct(nil, nil)
+	}
+	return s
+}
+
+//New block of the function, in ssa form from the start
+func (f *ssaFrame) piece() *BlockWrapper {
+	return &BlockWrapper{
+		Block:   &cfg.Block{Nodes: make([]ast.Node, 0), Live: true},
+		Parents: make([]Wrapper, 0),
+		Succs:   make([]Wrapper, 0),
+		Outer:   f.fn,
+		SSA:     &SSABlock{Phis: make([]*Phi, 0), Instrs: make([]*Instr, 0)},
+	}
+}
+
+//Adds the statement at the position to the nodes of the block, the
+//labeler and the logs go by them
+func (e *ssaExpander) addSource(b *BlockWrapper, pos token.Pos) {
+	node := e.sourceAt(b, pos)
+	if node == nil {
+		return
+	}
+	if n := len(b.Block.Nodes); n > 0 && b.Block.Nodes[n-1] == node {
+		return
+	}
+	b.Block.Nodes = append(b.Block.Nodes, node)
+}
+
+//Source of an instruction of the block, the position itself when there is no statement there
+func (e *ssaExpander) source(b *BlockWrapper, pos token.Pos) ast.Node {
+	if node := e.sourceAt(b, pos); node != nil {
+		return node
+	}
+	return &ast.BadExpr{From: pos, To: pos}
+}
+
+//The innermost statement at the position, or the condition of the
+//statement when the position is in its header (if, for, switch)
+func (e *ssaExpander) sourceAt(b *BlockWrapper, pos token.Pos) ast.Node {
+	fset := b.GetFileSet()
+	if !pos.IsValid() || fset == nil {
+		return nil
+	}
+	tf := fset.File(pos)
+	if tf == nil {
+		return nil
+	}
+	file, ok := e.files[tf]
+	if !ok {
+		for _, f := range b.GetASTs() {
+			if fset.File(f.Pos()) == tf {
+				file = f
+				break
+			}
+		}
+		e.files[tf] = file
+	}
+	if file == nil {
+		return nil
+	}
+
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var inner ast.Node
+	for _, node := range path {
+		switch node.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt,
+			*ast.BlockStmt, *ast.CaseClause, *ast.CommClause, *ast.LabeledStmt, *ast.FuncDecl, *ast.FuncLit:
+			if _, ok := inner.(ast.Expr); ok {
+				return inner
+			}
+			return nil
+		case ast.Stmt:
+			return node
+		}
+		inner = node
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package cfg
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"hash/fnv"
+	"sourcecrawler/app/helper"
+	"strconv"
+
+	"github.com/mitchellh/go-z3"
+	"golang.org/x/tools/go/cfg"
+)
+
+//SwitchCase is the case a block tests for in a switch, type switch or
+//select statement. The block's first successor is the case's body and the
+//second one is the next test (or the default case)
+type SwitchCase struct {
+	Stmt   ast.Stmt //*ast.SwitchStmt, *ast.TypeSwitchStmt or *ast.SelectStmt
+	Clause ast.Stmt //*ast.CaseClause or *ast.CommClause
+	Value  ast.Expr //case expression or type, nil in a select
+	cond   ast.Expr
+}
+
+//Condition holds when the case is taken, it is built once so the
+//traversal sees the same node every time. Select cases have no
+//condition, they are taken when their channel is ready
+func (c *SwitchCase) Condition() ast.Expr {
+	if c.cond != nil {
+		return c.cond
+	}
+
+	switch stmt := c.Stmt.(type) {
+	case *ast.SwitchStmt:
+		if stmt.Tag == nil {
+			c.cond = c.Value
+		} else {
+			c.cond = &ast.BinaryExpr{
+				X:     stmt.Tag,
+				OpPos: c.Value.Pos(),
+				Op:    token.EQL,
+				Y:     c.Value,
+			}
+		}
+	case *ast.TypeSwitchStmt:
+		//v.(type) == "T", the type is kept as a string so it isn't
+		//mistaken for a variable
+		if assert := typeSwitchAssert(stmt); assert != nil {
+			c.cond = &ast.BinaryExpr{
+				X:     assert,
+				OpPos: c.Value.Pos(),
+				Op:    token.EQL,
+				Y: &ast.BasicLit{
+					ValuePos: c.Value.Pos(),
+					Kind:     token.STRING,
+					Value:    strconv.Quote(types.ExprString(c.Value)),
+				},
+			}
+		}
+	}
+	return c.cond
+}
+
+//The v.(type) a type switch is on
+func typeSwitchAssert(stmt *ast.TypeSwitchStmt) *ast.TypeAssertExpr {
+	var expr ast.Expr
+	switch assign := stmt.Assign.(type) {
+	case *ast.AssignStmt:
+		if len(assign.Rhs) == 1 {
+			expr = assign.Rhs[0]
+		}
+	case *ast.ExprStmt:
+		expr = assign.X
+	}
+	assert, _ := expr.(*ast.TypeAssertExpr)
+	return assert
+}
+
+//Dynamic types are told apart by a number derived from their name
+func dynamicType(ctx *z3.Context, expr ast.Expr) *z3.AST {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil
+	}
+	name, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return ctx.Int(int(h.Sum32()&0x7fffffff), ctx.IntSort())
+}
+
+//Body of a function declaration or literal
+func fnBody(fn ast.Node) *ast.BlockStmt {
+	switch fn := fn.(type) {
+	case *ast.FuncDecl:
+		return fn.Body
+	case *ast.FuncLit:
+		return fn.Body
+	}
+	return nil
+}
+
+//findCases marks the blocks of a function that test for a case. Case
+//expressions are the last node of their test, type switch and select
+//tests have no nodes so they are found by following the chain of tests
+//from the block the statement starts in
+func findCases(body *ast.BlockStmt, blocks map[*cfg.Block]*BlockWrapper) {
+	if body == nil {
+		return
+	}
+
+	last := make(map[ast.Node]*cfg.Block)
+	for block := range blocks {
+		if len(block.Nodes) > 0 {
+			last[block.Nodes[len(block.Nodes)-1]] = block
+		}
+	}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.SwitchStmt:
+			for _, clause := range stmt.Body.List {
+				cc := clause.(*ast.CaseClause)
+				for _, value := range cc.List {
+					if block, ok := last[value]; ok && len(block.Succs) == 2 {
+						blocks[block].Case = &SwitchCase{Stmt: stmt, Clause: cc, Value: value}
+					}
+				}
+			}
+		case *ast.TypeSwitchStmt:
+			tests := make([]*SwitchCase, 0)
+			for _, clause := range stmt.Body.List {
+				cc := clause.(*ast.CaseClause)
+				for _, typ := range cc.List {
+					tests = append(tests, &SwitchCase{Stmt: stmt, Clause: cc, Value: typ})
+				}
+			}
+			markTests(last[stmt.Assign], tests, blocks)
+		case *ast.SelectStmt:
+			//every channel operation is evaluated before the tests
+			var start ast.Node
+			tests := make([]*SwitchCase, 0)
+			for _, clause := range stmt.Body.List {
+				if cc := clause.(*ast.CommClause); cc.Comm != nil {
+					start = cc.Comm
+					tests = append(tests, &SwitchCase{Stmt: stmt, Clause: cc})
+				}
+			}
+			if start != nil {
+				markTests(last[start], tests, blocks)
+			}
+		}
+		return true
+	})
+}
+
+//The next test is always the block the previous one falls to
+func markTests(block *cfg.Block, tests []*SwitchCase, blocks map[*cfg.Block]*BlockWrapper) {
+	for _, test := range tests {
+		if block == nil || len(block.Succs) != 2 {
+			return
+		}
+		if b, ok := blocks[block]; ok {
+			b.Case = test
+		}
+		block = block.Succs[1]
+	}
+}
+
+//Whether the statement is a call the configured recognizer takes for a
+//log, the way the project's log types are found
+func isLogStmt(node ast.Node) bool {
+	if stmt, ok := node.(*ast.ExprStmt); ok {
+		if call, ok := stmt.X.(*ast.CallExpr); ok {
+			return helper.Recognizer.IsLogCall(call)
+		}
+	}
+	return false
+}
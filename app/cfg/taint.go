@@ -0,0 +1,401 @@
+package cfg
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//---------- Inputs of the program --------------
+//The solver finds values for every variable the path doesn't assign, only
+//the ones coming from outside of the program are inputs. A variable is
+//tainted by the sources its value is read from: the parameters of the
+//entry function, the command line, the environment, flags, requests,
+//decoded JSON and files. The inputs reported are the tainted variables
+//the path doesn't compute from others.
+
+//SourceKind is where an input comes from
+type SourceKind int
+
+const (
+	NoSource    SourceKind = iota
+	Parameter              //parameter of the entry function
+	Arguments              //os.Args, flag.Args
+	Environment            //os.Getenv, os.LookupEnv
+	Flag                   //flag.Int, flag.StringVar...
+	Request                //fields and methods of an http.Request
+	JSON                   //targets of json.Unmarshal and Decode
+	File                   //reads of files and of the standard input
+)
+
+func (k SourceKind) String() string {
+	return [...]string{"none", "parameter", "arguments", "environment", "flag", "request", "json", "file"}[k]
+}
+
+//Sources of the calls to package functions (os.Getenv)
+var sourceCalls = map[string]SourceKind{
+	"os.Getenv": Environment, "os.LookupEnv": Environment, "os.Environ": Environment,
+	"flag.Arg": Arguments, "flag.Args": Arguments, "flag.NArg": Arguments,
+	"flag.Bool": Flag, "flag.Int": Flag, "flag.Int64": Flag, "flag.Uint": Flag, "flag.Uint64": Flag,
+	"flag.String": Flag, "flag.Float64": Flag, "flag.Duration": Flag,
+	"ioutil.ReadFile": File, "os.ReadFile": File, "ioutil.ReadAll": File, "io.ReadAll": File,
+	"mux.Vars": Request,
+}
+
+//Sources of the variables whose address is given to the calls, by the
+//name of the function or method (json.Unmarshal(data, &v))
+var targetCalls = map[string]SourceKind{
+	"json.Unmarshal": JSON, "Decode": JSON,
+	"flag.BoolVar": Flag, "flag.IntVar": Flag, "flag.Int64Var": Flag, "flag.UintVar": Flag, "flag.Uint64Var": Flag,
+	"flag.StringVar": Flag, "flag.Float64Var": Flag, "flag.DurationVar": Flag,
+	"fmt.Scan": File, "fmt.Scanf": File, "fmt.Scanln": File, "fmt.Fscan": File, "fmt.Fscanf": File, "fmt.Fscanln": File,
+	"io.ReadFull": File, "Read": File,
+}
+
+//Methods reading from a reader, bufio.Reader.ReadString
+var readMethods = map[string]bool{"ReadString": true, "ReadLine": true, "ReadBytes": true, "ReadRune": true, "ReadByte": true}
+
+//taint finds the sources of the variables of a path
+type taint struct {
+	params  map[*ast.Object][]*FnWrapper //functions of the parameters, one for each call
+	targets map[*ast.Object]SourceKind
+	assigns map[*ast.Object][]ast.Expr //values assigned to the variables
+	sources map[*ast.Object]SourceKind
+	visited map[*ast.Object]bool
+}
+
+//Taint of the variables of the functions in the graph of the block
+func newTaint(block Wrapper) *taint {
+	t := &taint{
+		params:  make(map[*ast.Object][]*FnWrapper),
+		targets: make(map[*ast.Object]SourceKind),
+		assigns: make(map[*ast.Object][]ast.Expr),
+		sources: make(map[*ast.Object]SourceKind),
+		visited: make(map[*ast.Object]bool),
+	}
+	if block == nil {
+		return t
+	}
+	root := block
+	for root.GetOuterWrapper() != nil {
+		root = root.GetOuterWrapper()
+	}
+	for _, w := range topologicalOrder(root) {
+		fn, ok := w.(*FnWrapper)
+		if !ok || fn.Fn == nil {
+			continue
+		}
+		var fnType *ast.FuncType
+		var body *ast.BlockStmt
+		switch decl := fn.Fn.(type) {
+		case *ast.FuncDecl:
+			fnType, body = decl.Type, decl.Body
+		case *ast.FuncLit:
+			fnType, body = decl.Type, decl.Body
+		}
+		if fnType == nil || fnType.Params == nil {
+			continue
+		}
+		for _, field := range fnType.Params.List {
+			for _, name := range field.Names {
+				if name.Obj != nil {
+					t.params[name.Obj] = append(t.params[name.Obj], fn)
+				}
+			}
+		}
+		if body != nil {
+			t.findTargets(body)
+			t.findAssignments(body)
+		}
+	}
+	return t
+}
+
+//Variables the body reads inputs into, by passing their address
+func (t *taint) findTargets(body *ast.BlockStmt) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		kind, ok := targetCalls[callName(call)]
+		if !ok {
+			if sel, isSel := call.Fun.(*ast.SelectorExpr); isSel {
+				kind, ok = targetCalls[sel.Sel.Name]
+			}
+		}
+		if !ok {
+			return true
+		}
+		for _, arg := range call.Args {
+			if addr, isAddr := unparen(arg).(*ast.UnaryExpr); isAddr && addr.Op == token.AND {
+				arg = addr.X
+			}
+			if obj := rootObject(arg); obj != nil && obj.Kind == ast.Var {
+				t.targets[obj] = kind
+			}
+		}
+		return true
+	})
+}
+
+//Values the body assigns to its variables, the declarations among them
+func (t *taint) findAssignments(body *ast.BlockStmt) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		assign, ok := node.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+		for i, lhs := range assign.Lhs {
+			id, ok := lhs.(*ast.Ident)
+			if !ok || id.Obj == nil || len(assign.Rhs) == 0 {
+				continue
+			}
+			rhs := assign.Rhs[0]
+			if len(assign.Lhs) == len(assign.Rhs) {
+				rhs = assign.Rhs[i]
+			}
+			t.assigns[id.Obj] = append(t.assigns[id.Obj], rhs)
+		}
+		return true
+	})
+}
+
+//Source of the variable, NoSource when it isn't read from one
+func (t *taint) variable(obj *ast.Object) SourceKind {
+	if obj == nil {
+		return NoSource
+	}
+	if kind, ok := t.sources[obj]; ok {
+		return kind
+	}
+	if t.visited[obj] {
+		return NoSource
+	}
+	t.visited[obj] = true
+	kind := t.targets[obj]
+	if kind == NoSource {
+		kind = t.declared(obj)
+	}
+	//or assigned from one after it's declared
+	for _, value := range t.assigns[obj] {
+		if kind != NoSource {
+			break
+		}
+		kind = t.expr(value)
+	}
+	t.sources[obj] = kind
+	return kind
+}
+
+//Source of the value the variable is declared with
+func (t *taint) declared(obj *ast.Object) SourceKind {
+	switch decl := obj.Decl.(type) {
+	case *ast.Field:
+		if isRequest(decl.Type) {
+			return Request
+		}
+		fns, ok := t.params[obj]
+		if !ok {
+			//receivers and parameters of functions out of the graph
+			return Parameter
+		}
+		for _, fn := range fns {
+			arg, ok := fn.ParamsToArgs[obj]
+			if !ok {
+				//the entry function isn't called
+				return Parameter
+			}
+			if kind := t.expr(arg); kind != NoSource {
+				return kind
+			}
+		}
+	case *ast.AssignStmt:
+		for i, lhs := range decl.Lhs {
+			if id, ok := lhs.(*ast.Ident); ok && id.Obj == obj {
+				if len(decl.Lhs) == len(decl.Rhs) {
+					return t.expr(decl.Rhs[i])
+				}
+				return t.expr(decl.Rhs[0])
+			}
+		}
+	case *ast.ValueSpec:
+		for i, name := range decl.Names {
+			if name.Obj == obj && i < len(decl.Values) {
+				return t.expr(decl.Values[i])
+			}
+		}
+	}
+	return NoSource
+}
+
+//Source of the first tainted value the expression reads
+func (t *taint) expr(expr ast.Expr) SourceKind {
+	kind := NoSource
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if kind != NoSource {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.SelectorExpr:
+			if selectorName(node) == "os.Args" {
+				kind = Arguments
+			}
+		case *ast.CallExpr:
+			kind = sourceCall(node)
+		case *ast.Ident:
+			if node.Obj != nil && node.Obj.Kind == ast.Var {
+				kind = t.variable(node.Obj)
+			}
+		}
+		return true
+	})
+	return kind
+}
+
+//Source of the value the call returns
+func sourceCall(call *ast.CallExpr) SourceKind {
+	if kind, ok := sourceCalls[callName(call)]; ok {
+		return kind
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && readMethods[sel.Sel.Name] {
+		return File
+	}
+	return NoSource
+}
+
+//Name of the package function called (os.Getenv), empty for the others
+func callName(call *ast.CallExpr) string {
+	if sel, ok := unparen(call.Fun).(*ast.SelectorExpr); ok {
+		return selectorName(sel)
+	}
+	return ""
+}
+
+//Name of a package member (os.Args), empty for the others
+func selectorName(sel *ast.SelectorExpr) string {
+	if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Obj == nil {
+		return pkg.Name + "." + sel.Sel.Name
+	}
+	return ""
+}
+
+//Whether the type is an http.Request or a pointer to one
+func isRequest(t ast.Expr) bool {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	sel, ok := t.(*ast.SelectorExpr)
+	return ok && selectorName(sel) == "http.Request"
+}
+
+//Variable holding the memory the expression reads, for fields, pointed
+//values and map elements the one they are in
+func rootObject(expr ast.Expr) *ast.Object {
+	id := assignedIdent(unparen(expr))
+	if id == nil || id.Obj == nil {
+		return nil
+	}
+	switch data := id.Obj.Data.(type) {
+	case *ast.Object:
+		return data
+	case *entry:
+		return data.root
+	}
+	return id.Obj
+}
+
+//InputSources are the sources of the variables of the assignments that
+//are inputs of the program, the others aren't in it
+func InputSources(block Wrapper, nodes []ast.Node, assignments map[string]*z3.AST) map[string]SourceKind {
+	t := newTaint(block)
+	idents, assigned := pathVariables(nodes)
+
+	sources := make(map[string]SourceKind)
+	for name := range assignments {
+		//constants standing for the results of operations
+		if strings.HasPrefix(name, "[") {
+			continue
+		}
+		if _, ok := assigned[name]; ok {
+			continue
+		}
+		variable, measured := inputVariable(name)
+		id, ok := idents[variable]
+		if !ok {
+			continue
+		}
+		if rhs, ok := assigned[variable]; ok && !measured {
+			//only values read from a source as they are, os.Args[1]
+			if kind := t.direct(id, rhs); kind != NoSource {
+				sources[name] = kind
+			}
+			continue
+		}
+		if kind := t.variable(rootObject(id)); kind != NoSource {
+			sources[name] = kind
+		}
+	}
+	return sources
+}
+
+//Variables of the path by name, and the values assigned to them
+func pathVariables(nodes []ast.Node) (map[string]*ast.Ident, map[string]ast.Expr) {
+	idents := make(map[string]*ast.Ident)
+	assigned := make(map[string]ast.Expr)
+	for _, node := range nodes {
+		ast.Inspect(node, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.Ident:
+				if _, ok := idents[node.Name]; !ok && node.Obj != nil {
+					idents[node.Name] = node
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range node.Lhs {
+					if i < len(node.Rhs) {
+						assigned[types.ExprString(lhs)] = node.Rhs[i]
+					} else {
+						assigned[types.ExprString(lhs)] = node.Rhs[0]
+					}
+				}
+			}
+			return true
+		})
+	}
+	return idents, assigned
+}
+
+//Variable an assignment is about. Pointers are inputs by whether they are
+//nil, values by their length too, assigning them doesn't give it
+func inputVariable(name string) (string, bool) {
+	variable := strings.TrimSuffix(name, " == nil")
+	measured := false
+	for _, builtin := range []string{"len(", "cap("} {
+		if strings.HasPrefix(variable, builtin) && strings.HasSuffix(variable, ")") {
+			variable = strings.TrimSuffix(strings.TrimPrefix(variable, builtin), ")")
+			measured = true
+		}
+	}
+	return variable, measured
+}
+
+//Source of the variable when the path assigns it a value the constraints
+//don't know, read from a source and not from other variables
+func (t *taint) direct(id *ast.Ident, rhs ast.Expr) SourceKind {
+	known := false
+	ast.Inspect(rhs, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && id.Obj != nil {
+			known = true
+		}
+		return !known
+	})
+	if known || id.Obj == nil {
+		return NoSource
+	}
+	return t.declared(id.Obj)
+}
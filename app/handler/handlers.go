@@ -6,7 +6,9 @@ import (
 	"os"
 	"sourcecrawler/app/helper"
 	"sourcecrawler/app/unsafe"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-z3"
 
@@ -14,7 +16,6 @@ import (
 	"go/ast"
 	"go/printer"
 	"net/http"
-	"regexp"
 	"sourcecrawler/app/cfg"
 	"sourcecrawler/app/model"
 	_ "strings" //
@@ -53,6 +54,11 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		StackTrace  string   `json:"stackTrace"`
 		LogMessages []string `json:"logMessages"` //it holds raw log statements
 		ProjectRoot string   `json:"projectRoot"`
+		//the messages are every log written until the panic, the
+		//logs that are missing show what didn't run
+		CompleteLogs   bool     `json:"completeLogs"`
+		DisabledLevels []string `json:"disabledLevels"` //levels that weren't logged (debug)
+		ServiceURL     string   `json:"serviceUrl"`     //where the sliced service runs, for the requests reproducing the panic
 	}{}
 
 	decoder := json.NewDecoder(r.Body)
@@ -69,19 +75,22 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	//1 -- parse stack trace for functions that led to exception
 	parsedStack := helper.ParsePanic(request.ProjectRoot, request.StackTrace)
 
-	//2 -- Parse project for log statements with regex + line + file name
-	logTypes := helper.ParseProject(request.ProjectRoot)
+	//2 -- Get the log statements with regex + line + file name for the project's revision
+	logTypes, err := indexedLogTypes(db, request.ProjectRoot)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	// Matching log messages to a regex (only returns used regexes)
+	// Matching log messages to their log type the way a log source is
+	// found, in the order they were written and as many times as they were
 	seenLogTypes := []model.LogType{}
+	seenMessages := []string{}
+	matcher := newLogMatcher(logTypes)
 	for _, msg := range request.LogMessages {
-		for _, value := range logTypes {
-			matched, _ := regexp.MatchString(value.Regex, msg)
-			if matched {
-				seenLogTypes = append(seenLogTypes, value)
-				//fmt.Println("Valid regexes:", value.Regex)
-				break
-			}
+		if logType, _ := matcher.find(msg); logType != nil {
+			seenLogTypes = append(seenLogTypes, logType.logType)
+			seenMessages = append(seenMessages, msg)
 		}
 	}
 
@@ -90,28 +99,55 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Filtered log", m.Regex)
 	}
 
-	topLevelWrapper := cfg.SetupPersistentData(request.ProjectRoot)
+	//the graphs come from the syntax or from the typed ssa form
+	var ssaProgram *cfg.SSAProgram
+	var topLevelWrapper *cfg.FnWrapper
+	if settings.Slicer.Backend == "ssa" {
+		ssaProgram, err = cfg.NewSSAProgram(helper.LoadTypedProject(request.ProjectRoot))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		topLevelWrapper = ssaProgram.Root()
+	} else {
+		topLevelWrapper = cfg.SetupPersistentData(request.ProjectRoot)
+	}
+	topLevelWrapper.MaxCallDepth = settings.Slicer.MaxCallDepth
+	topLevelWrapper.CallContext = settings.Slicer.CallContext
 
 	// ==== Tested, should be getting the correct info
 	stack := parsedStack //likely only one stack trace
+	topLevelWrapper.Trace = &stack
 	entryPackage := stack.PackageName[len(stack.FileName)-1]
 	entryName := stack.FuncName[len(stack.FuncName)-1]
+
+	//a goroutine is sliced from the function that started it
+	if stack.CreatedBy != nil {
+		entryPackage = stack.CreatedBy.PackageName
+		entryName = stack.CreatedBy.FuncName
+		topLevelWrapper.CreatedBy = stack.CreatedBy
+	}
 	//fmt.Println("entryPackage:", entryPackage)
 	//fmt.Println("entryName:", entryName)
 
+	//a panic in an http handler is sliced from the outermost handler, the
+	//server calling it isn't in the project
+	var endpoint *cfg.Endpoint
+	isHandler := false
+	for i := len(stack.FuncName) - 1; i >= 0; i-- {
+		if decl := findFuncDecl(topLevelWrapper.ASTs, stack.PackageName[i], stack.FuncName[i]); decl != nil && cfg.IsHandler(decl.Type) {
+			entryPackage, entryName = stack.PackageName[i], stack.FuncName[i]
+			endpoint = cfg.FindEndpoint(topLevelWrapper.ASTs, entryName)
+			isHandler = true
+			fmt.Println("Entry handler:", entryName, endpoint)
+			break
+		}
+	}
+
 	//grab the entry function (tested)
 	var entryFnNode ast.Node
-	for _, file := range topLevelWrapper.ASTs {
-		if strings.Contains(file.Name.Name, entryPackage) {
-			for _, decl := range file.Decls {
-				if decl, ok := decl.(*ast.FuncDecl); ok {
-					if strings.EqualFold(decl.Name.Name, entryName) {
-						entryFnNode = decl
-						break
-					}
-				}
-			}
-		}
+	if decl := findFuncDecl(topLevelWrapper.ASTs, entryPackage, entryName); decl != nil {
+		entryFnNode = decl
 	}
 
 	//Test print entry function (Good)
@@ -124,9 +160,16 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	//expand the cfg
-	entryWrapper := cfg.NewFnWrapper(entryFnNode, nil)
-	entryWrapper.SetOuterWrapper(topLevelWrapper)
-	cfg.ExpandCFG(entryWrapper)
+	var entryWrapper *cfg.FnWrapper
+	if ssaProgram != nil {
+		entryWrapper = cfg.NewSSAFnWrapper(ssaProgram.Function(entryPackage, entryName))
+		entryWrapper.SetOuterWrapper(topLevelWrapper)
+		cfg.ExpandSSA(entryWrapper)
+	} else {
+		entryWrapper = cfg.NewFnWrapper(entryFnNode, nil)
+		entryWrapper.SetOuterWrapper(topLevelWrapper)
+		cfg.ExpandCFG(entryWrapper)
+	}
 
 	//find the block originating the exceptionp
 	exceptionBlock := cfg.FindPanicWrapper(entryWrapper, &stack)
@@ -137,15 +180,33 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	pathList := cfg.CreateNewPath()
+	pathList.MaxPaths = settings.Slicer.MaxPaths
+	pathList.MaxDepth = settings.Slicer.MaxDepth
+	pathList.Timeout = time.Duration(settings.Slicer.TimeoutMs) * time.Millisecond
+	pathList.LoopBound = settings.Slicer.LoopBound
+	pathList.CompleteLogs = request.CompleteLogs
+	pathList.DisabledLevels = request.DisabledLevels
+	pathList.Observed = seenLogTypes
+	if line, err := strconv.Atoi(stack.LineNum[0]); err == nil {
+		pathList.ExceptionLine = line
+	}
+	//the recovering handler ran from the panic it recovered
+	if stack.Recovered {
+		pathList.Recovered = cfg.FindRecoveredWrapper(entryWrapper, &stack)
+	}
 
 	//label the tree starting from the exception block
-	pathList.LabelCFG(exceptionBlock, seenLogTypes, exceptionBlock, stack)
+	pathList.LabelCFG(exceptionBlock, seenLogTypes)
 
 	//rename variables to ssa form
 	cfg.ConvertCFGtoSSAForm(entryWrapper)
 
-	//gather the paths
-	paths := pathList.TraverseCFG(exceptionBlock, exceptionBlock)
+	//gather the paths, those writing logs in another order than they were seen didn't run
+	pathList.TraverseCFG(exceptionBlock, exceptionBlock)
+	paths := pathList.Paths
+	if pathList.Truncated {
+		fmt.Println("The limits stopped the traversal, kept the", len(paths), "likeliest paths")
+	}
 
 	//Print labels on each constraint
 	cnt := 1
@@ -156,8 +217,8 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 
 		//Should print each constraint with its label
 		for index := range path.Expressions {
-			printer.Fprint(os.Stdout, topLevelWrapper.GetFileSet(), path.CopyExpressions[index])
-			fmt.Print(" ---- ", path.CopyExecStatus[index])
+			printer.Fprint(os.Stdout, topLevelWrapper.GetFileSet(), path.Expressions[index])
+			fmt.Print(" ---- ", path.ExecStatus[index])
 			fmt.Println()
 		}
 	}
@@ -167,25 +228,32 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	//Print paths
 	for i, path := range paths {
 		fmt.Println("----------- PATH", i+1, " --", path.DidExecute)
-		for _, expr := range path.CopyExpressions {
+		for _, expr := range path.Expressions {
 			printer.Fprint(os.Stdout, topLevelWrapper.Fset, expr)
 			fmt.Println()
 		}
+		for _, match := range alignLogs(path, seenMessages) {
+			fmt.Println("log", match.Position, "--", match.Message)
+		}
+		if path.Alignment != nil && !path.Alignment.Consistent {
+			fmt.Println("Logs don't match:", path.Alignment.Conflict)
+		}
 		fmt.Println()
 	}
 
 	//transform to z3
 	config := z3.NewConfig()
+	if settings.Solver.TimeoutMs > 0 {
+		config.SetParamValue("timeout", strconv.Itoa(settings.Solver.TimeoutMs))
+	}
 	ctx := z3.NewContext(config)
 	config.Close()
 	defer ctx.Close()
 
-	s := ctx.NewSolver()
-	defer s.Close()
-
 	type PathResp struct {
-		Path  []string `json:"path"`
-		Label string   `json:"label"`
+		Path  []string   `json:"path"`
+		Label string     `json:"label"`
+		Logs  []LogMatch `json:"logs"` //logs of the path, in order, with the messages they wrote
 	}
 
 	respPath := make([]PathResp, 0)
@@ -195,7 +263,7 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		if path.DidExecute != cfg.MustNot {
 			finalPaths = append(finalPaths, path)
 			p := make([]string, 0)
-			for _, cond := range path.CopyExpressions {
+			for _, cond := range path.Expressions {
 				var b bytes.Buffer
 				printer.Fprint(&b, topLevelWrapper.Fset, cond)
 				p = append(p, b.String())
@@ -203,15 +271,24 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 			respPath = append(respPath, PathResp{
 				Path:  p,
 				Label: path.DidExecute.String(),
+				Logs:  alignLogs(path, seenMessages),
 			})
 		}
 	}
 
 	mustAssignments := make([]string, 0)
+	inputs := make([]Input, 0)
+	requests := make([]HTTPRequest, 0)
+	serviceURL := request.ServiceURL
+	if serviceURL == "" {
+		serviceURL = "http://localhost:8080"
+	}
 
 	//solve and display each path
 	assignments := make([]map[string]*z3.AST, 0)
 	for _, path := range finalPaths {
+		//every path is solved on its own
+		s := ctx.NewSolver()
 		var z3group *z3.AST
 		for _, expr := range path.Expressions {
 			z3group = cfg.ConvertExprToZ3(ctx, expr, topLevelWrapper.Fset)
@@ -222,30 +299,103 @@ func SliceProgram(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 
 		if v := s.Check(); v != z3.True {
 			fmt.Println("Unsolvable")
+			s.Close()
 			continue
 		}
 		m := s.Model()
 		newAssignments := m.Assignments()
+		sources := cfg.InputSources(exceptionBlock, path.Expressions, newAssignments)
+		if isHandler {
+			values := cfg.RequestValues(exceptionBlock, path.Expressions, newAssignments)
+			req := cfg.BuildRequest(endpoint, values, newAssignments)
+			fmt.Println(req.Curl(serviceURL))
+			requests = append(requests, HTTPRequest{
+				Method: req.Method,
+				URL:    req.URL(),
+				Body:   req.JSON(),
+				Curl:   req.Curl(serviceURL),
+			})
+		}
 		cfg.FilterToUserInput(exceptionBlock, path.Expressions, newAssignments)
 		assignments = append(assignments, newAssignments)
 
 		for name, val := range newAssignments {
-			a := fmt.Sprintf("%s = %s", name, val)
-			fmt.Println(a)
+			a := cfg.DescribeAssignment(name, val)
+			fmt.Println(a, "--", sources[name])
 			mustAssignments = append(mustAssignments, a)
+			inputs = append(inputs, Input{Assignment: a, Source: sources[name].String()})
 		}
 		fmt.Println()
 
 		m.Close()
+		s.Close()
 	}
 
 	resp := struct {
-		Paths       []PathResp `json:"paths"`
-		Assignments []string   `json:"assignments"`
+		Paths       []PathResp    `json:"paths"`
+		Assignments []string      `json:"assignments"`
+		Inputs      []Input       `json:"inputs"`
+		Requests    []HTTPRequest `json:"requests"`
+		Truncated   bool          `json:"truncated"` //the limits left paths out
 	}{
 		respPath,
 		mustAssignments,
+		inputs,
+		requests,
+		pathList.Truncated,
 	}
 
 	respondJSON(w, http.StatusOK, resp)
 }
+
+//Input is an assignment of the inputs of a path and where the input
+//comes from (parameter, arguments, environment, flag, request, json, file)
+type Input struct {
+	Assignment string `json:"assignment"`
+	Source     string `json:"source"`
+}
+
+//HTTPRequest is a request to the handler the panic is in, reproducing
+//a path
+type HTTPRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body"` //JSON, empty without a body
+	Curl   string `json:"curl"`
+}
+
+//Declaration of the function of a frame in the files of the package, nil
+//when it isn't in the project
+func findFuncDecl(files []*ast.File, pkg, name string) *ast.FuncDecl {
+	for _, file := range files {
+		if !strings.Contains(file.Name.Name, pkg) {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && strings.EqualFold(decl.Name.Name, name) {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+//LogMatch is a log statement of a path and the message observed for it,
+//empty when the log wasn't observed
+type LogMatch struct {
+	Position string `json:"position"`
+	Message  string `json:"message"`
+}
+
+//Pairs the logs of the path with the messages they were aligned with
+func alignLogs(path cfg.Path, messages []string) []LogMatch {
+	matches := make([]LogMatch, 0, len(path.Logs))
+	for i, event := range path.Logs {
+		match := LogMatch{Position: event.Position.String()}
+		if path.Alignment != nil && path.Alignment.Observed[i] != -1 {
+			match.Message = messages[path.Alignment.Observed[i]]
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
@@ -1,41 +1,121 @@
 package handler
 
 import (
+	"encoding/json"
 	"sourcecrawler/app/model"
-
-	"github.com/jinzhu/gorm"
+	"strings"
 
 	"fmt"
 	"regexp"
 )
 
-func matchLog(logMessage string, db *gorm.DB) (*model.LogSourceResponse, error) {
-	// TODO: find log in database
-
-	logTypes := []model.LogType{}
-	db.Find(&logTypes)
+//compiledLogType holds a log type with the regex used to match messages,
+//every unknown part of the message is a capture group
+type compiledLogType struct {
+	logType     model.LogType
+	regex       *regexp.Regexp
+	specificity int
+}
 
-	// Initialize default response
-	var response *model.LogSourceResponse
-	err := fmt.Errorf("Could not match any log type to \"%s\"", logMessage)
+//logMatcher compiles the log types once so many messages can be matched
+type logMatcher struct {
+	logTypes []compiledLogType
+}
 
-	// Find the first logType where the logMessage matches the regex
+func newLogMatcher(logTypes []model.LogType) *logMatcher {
+	m := &logMatcher{logTypes: make([]compiledLogType, 0, len(logTypes))}
 	for _, logType := range logTypes {
-		fullRegex := "^" + logType.Regex + "$"
-		if regex, err := regexp.Compile(fullRegex); err == nil {
-
-			if regex.Match([]byte(logMessage)) {
-				// Found a log type, set values
-				response = &model.LogSourceResponse{
-					LineNumber: logType.LineNumber,
-					FilePath:   logType.FilePath,
-					Regex:      logType.Regex,
+		pattern, specificity := captureRegex(logType.Regex)
+		if regex, err := regexp.Compile("^" + pattern + "$"); err == nil {
+			m.logTypes = append(m.logTypes, compiledLogType{logType, regex, specificity})
+		}
+	}
+	return m
+}
+
+func matchLog(logMessage string, logTypes []model.LogType) (*model.LogSourceResponse, error) {
+	return newLogMatcher(logTypes).match(logMessage)
+}
+
+//Finds the log type for the message, when several match the one
+//with the most known text wins since it is the least ambiguous
+func (m *logMatcher) match(logMessage string) (*model.LogSourceResponse, error) {
+	best, bestArgs := m.find(logMessage)
+	if best == nil {
+		return nil, fmt.Errorf("Could not match any log type to \"%s\"", logMessage)
+	}
+
+	return &model.LogSourceResponse{
+		LogMessage:   logMessage,
+		FilePath:     best.logType.FilePath,
+		LineNumber:   best.logType.LineNumber,
+		FunctionName: best.logType.FunctionName,
+		Regex:        best.logType.Regex,
+		Arguments:    bestArgs,
+	}, nil
+}
+
+//Log type of the message and the arguments it was written with, nil
+//when no log type matches
+func (m *logMatcher) find(logMessage string) (*compiledLogType, []string) {
+	message := extractLogMessage(logMessage)
+
+	var best *compiledLogType
+	var bestArgs []string
+	for i, logType := range m.logTypes {
+		groups := logType.regex.FindStringSubmatch(message)
+		if groups == nil {
+			continue
+		}
+		if best == nil || logType.specificity > best.specificity {
+			best = &m.logTypes[i]
+			bestArgs = groups[1:]
+		}
+	}
+	return best, bestArgs
+}
+
+//Turns the unknown parts of a log type regex (.*, .+, \d) into capture
+//groups, numbers match all of their digits. The specificity is the amount
+//of literal text in the regex.
+func captureRegex(regex string) (string, int) {
+	var sb strings.Builder
+	specificity := 0
+	for i := 0; i < len(regex); i++ {
+		switch {
+		case strings.HasPrefix(regex[i:], ".*"), strings.HasPrefix(regex[i:], ".+"):
+			sb.WriteString("(" + regex[i:i+2] + "?)")
+			i++
+		case strings.HasPrefix(regex[i:], "\\d"):
+			sb.WriteString("(-?\\d+)")
+			i++
+		case regex[i] == '\\' && i+1 < len(regex):
+			sb.WriteString(regex[i : i+2])
+			specificity++
+			i++
+		default:
+			sb.WriteByte(regex[i])
+			specificity++
+		}
+	}
+	return sb.String(), specificity
+}
+
+//Gets the message out of a raw log line: the message field of JSON
+//logs (zerolog), or the text after the standard log timestamp
+func extractLogMessage(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &fields); err == nil {
+			for _, key := range []string{"message", "msg"} {
+				if msg, ok := fields[key].(string); ok {
+					return msg
 				}
-				err = nil
-				break
 			}
 		}
 	}
-
-	return response, err
+	return stdLogPrefix.ReplaceAllString(line, "")
 }
+
+var stdLogPrefix = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sourcecrawler/app/helper"
+	"sourcecrawler/app/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+//IndexProject extracts the log types of the project's current revision
+//and stores them, revisions that were already indexed are reused
+func IndexProject(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	request := model.ParseProjectRequest{}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if request.ProjectRoot == "" {
+		respondError(w, http.StatusBadRequest, "projectRoot is required")
+		return
+	}
+
+	revision, err := indexProject(db, request.ProjectRoot)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	count := 0
+	db.Model(&model.LogType{}).Where("project_revision_id = ?", revision.ID).Count(&count)
+	respondJSON(w, http.StatusOK, model.ParseProjectResponse{
+		ProjectRoot: request.ProjectRoot,
+		Commit:      revision.Commit,
+		Dirty:       revision.Dirty,
+		LogTypes:    count,
+	})
+}
+
+//LogSource finds where a log message was emitted from using the stored log types
+func LogSource(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	request := model.LogSourceRequest{}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	logTypes, err := indexedLogTypes(db, request.ProjectRoot)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response, err := matchLog(request.LogMessage, logTypes)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+//LogSourceBatch finds the source of every log line, lines that
+//don't match a log type get an error instead of a location
+func LogSourceBatch(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	request := model.LogSourceBatchRequest{}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	logTypes, err := indexedLogTypes(db, request.ProjectRoot)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	matcher := newLogMatcher(logTypes)
+	responses := make([]model.LogSourceResponse, 0, len(request.LogMessages))
+	for _, msg := range request.LogMessages {
+		response, err := matcher.match(msg)
+		if err != nil {
+			response = &model.LogSourceResponse{LogMessage: msg, Arguments: []string{}, Error: err.Error()}
+		}
+		responses = append(responses, *response)
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+//Log types of the project's current revision, indexing it if needed. Without a
+//project root, the latest revision of every indexed project is used. Without
+//a database the project is parsed directly.
+func indexedLogTypes(db *gorm.DB, projectRoot string) ([]model.LogType, error) {
+	if db == nil {
+		return helper.ParseProject(projectRoot), nil
+	}
+
+	revisionIDs := make([]uint, 0)
+	if projectRoot != "" {
+		revision, err := indexProject(db, projectRoot)
+		if err != nil {
+			return nil, err
+		}
+		revisionIDs = append(revisionIDs, revision.ID)
+	} else {
+		projects := []model.Project{}
+		if err := db.Find(&projects).Error; err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			revision := model.ProjectRevision{}
+			if err := db.Where("project_id = ?", project.ID).Order("updated_at desc").First(&revision).Error; err == nil {
+				revisionIDs = append(revisionIDs, revision.ID)
+			}
+		}
+	}
+
+	logTypes := []model.LogType{}
+	err := db.Where("project_revision_id in (?)", revisionIDs).Order("id").Find(&logTypes).Error
+	return logTypes, err
+}
+
+//Gets or creates the revision for the commit checked out in the project.
+//Revisions of a dirty working tree, or of a project outside of git, are
+//re-indexed since their log types may have changed, and so are the ones
+//indexed with other log recognizer settings.
+func indexProject(db *gorm.DB, projectRoot string) (*model.ProjectRevision, error) {
+	root, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	commit, dirty := helper.GitRevision(root)
+	recognizer := helper.Recognizer.Hash()
+
+	project := model.Project{}
+	if err := db.Where(model.Project{RootPath: root}).FirstOrCreate(&project).Error; err != nil {
+		return nil, err
+	}
+
+	revision := model.ProjectRevision{}
+	found := !db.Where("project_id = ? AND git_commit = ?", project.ID, commit).First(&revision).RecordNotFound()
+	if found && commit != "" && !dirty && !revision.Dirty && revision.Recognizer == recognizer {
+		return &revision, nil
+	}
+
+	logTypes := helper.ParseProject(root)
+
+	tx := db.Begin()
+	if found {
+		if err := tx.Unscoped().Where("project_revision_id = ?", revision.ID).Delete(&model.LogType{}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	} else {
+		revision = model.ProjectRevision{ProjectID: project.ID, Commit: commit}
+	}
+	revision.Dirty = dirty
+	revision.Recognizer = recognizer
+	if err := tx.Save(&revision).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for i := range logTypes {
+		logTypes[i].ProjectRevisionID = revision.ID
+		if err := tx.Create(&logTypes[i]).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
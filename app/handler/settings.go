@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"sourcecrawler/app/cfg"
+	"sourcecrawler/app/helper"
+	"sourcecrawler/config"
+
+	"github.com/jinzhu/gorm"
+)
+
+//settings is the configuration the handlers run with
+var settings = config.Default()
+
+//Configure applies the configuration to the handlers and the
+//helpers they use (log recognition, stack trace paths and the
+//summaries of library functions)
+func Configure(c *config.Config) error {
+	library := cfg.DefaultLibrary()
+	if c.Slicer.Summaries != "" {
+		loaded, err := cfg.LoadLibrary(c.Slicer.Summaries)
+		if err != nil {
+			return err
+		}
+		for name, summary := range loaded {
+			library[name] = summary
+		}
+	}
+	cfg.Summaries = library
+
+	settings = c
+
+	helper.Recognizer = helper.LogRecognizer{
+		Loggers: c.Logs.Loggers,
+		Methods: c.Logs.Methods,
+	}
+
+	mappings := make([]helper.PathMapping, 0, len(c.PathMappings))
+	for _, mapping := range c.PathMappings {
+		mappings = append(mappings, helper.PathMapping{From: mapping.From, To: mapping.To})
+	}
+	helper.PathMappings = mappings
+	return nil
+}
+
+//ShowConfig responds with the configuration in use, without its secrets
+func ShowConfig(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, settings.Redacted())
+}
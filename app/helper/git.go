@@ -0,0 +1,22 @@
+package helper
+
+import (
+	"os/exec"
+	"strings"
+)
+
+//GitRevision returns the commit checked out in the project and whether
+//the working tree has changes on top of it, the commit is empty when the
+//project isn't a git repository
+func GitRevision(projectRoot string) (string, bool) {
+	out, err := exec.Command("git", "-C", projectRoot, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	commit := strings.TrimSpace(string(out))
+
+	//only go files matter for the log types
+	status, err := exec.Command("git", "-C", projectRoot, "status", "--porcelain", "--", "*.go").Output()
+	dirty := err != nil || len(strings.TrimSpace(string(status))) > 0
+	return commit, dirty
+}
@@ -2,16 +2,79 @@ package helper
 
 import (
 	"fmt"
+	"hash/fnv"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"regexp"
 	"strings"
 )
 
+//LogRecognizer decides which calls are log statements
+type LogRecognizer struct {
+	Loggers []string
+	Methods []string
+}
+
+//Recognizer is used when looking for log statements, it is set from the configuration
+var Recognizer = LogRecognizer{
+	Loggers: []string{"log"},
+	Methods: []string{"Msg", "Msgf", "Err", "Errorf"},
+}
+
+//Identifiers of a printed expression
+var identifiers = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+//IsLogger checks if the expression mentions one of the loggers, an
+//identifier starting with its name (logger for log, but not catalog)
+func (r LogRecognizer) IsLogger(expr string) bool {
+	for _, id := range identifiers.FindAllString(expr, -1) {
+		for _, logger := range r.Loggers {
+			if strings.HasPrefix(id, logger) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//IsLogCall checks if the call is a log statement, a method of a call chain
+//starting at a logger that emits the message or is called on the logger
+func (r LogRecognizer) IsLogCall(call *ast.CallExpr) bool {
+	fn, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	return (r.IsMessageMethod(fn.Sel.Name) || r.IsLogger(fmt.Sprint(fn))) && IsFromLog(fn)
+}
+
+//IsMessageMethod checks if the method is one that emits the message (Msg, Msgf, Err...)
+func (r LogRecognizer) IsMessageMethod(name string) bool {
+	for _, method := range r.Methods {
+		if name == method {
+			return true
+		}
+	}
+	return false
+}
+
+//Hash of the settings, the log types found with other settings differ
+func (r LogRecognizer) Hash() string {
+	h := fnv.New64a()
+	for _, logger := range r.Loggers {
+		fmt.Fprintf(h, "%s\x00", logger)
+	}
+	h.Write([]byte{1})
+	for _, method := range r.Methods {
+		fmt.Fprintf(h, "%s\x00", method)
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
 //Checks if from log (two.name is Info/Err/Error)
 func IsFromLog(fn *ast.SelectorExpr) bool {
 
-	if strings.Contains(fmt.Sprint(fn.X), "log") {
+	if Recognizer.IsLogger(fmt.Sprint(fn.X)) {
 		return true
 	}
 	one, ok := fn.X.(*ast.CallExpr)
@@ -58,34 +121,28 @@ func GetLogRegexFromInfo(filename string, lineNumber int) string {
 }
 
 func CreateRegex(value string) string {
-	//Regex value currently
-	reg := value
+	//Remove the double quotes
+	return createRegexFromText(value[1 : len(value)-1])
+}
 
-	//Converting current regex strings to regex format (parenthesis, %d,%s,%v,',%+v)
-	if strings.Contains(reg, "(") {
-		reg = strings.ReplaceAll(reg, "(", "\\(")
-	}
-	if strings.Contains(reg, ")") {
-		reg = strings.ReplaceAll(reg, ")", "\\)")
-	}
+//Format verbs of log text and the regexes of the values they print
+var formatVerbs = regexp.MustCompile(`%\+v|%[dsv]`)
 
-	//Converting %d, %s, %v to regex num, removing single quotes
-	if strings.Contains(reg, "%d") {
-		reg = strings.ReplaceAll(reg, "%d", "\\d")
-	}
-	if strings.Contains(reg, "%s") {
-		reg = strings.ReplaceAll(reg, "%s", ".*")
-	}
-	if strings.Contains(reg, "%v") {
-		reg = strings.ReplaceAll(reg, "%v", ".*")
-	}
-	if strings.Contains(reg, "'") {
-		reg = strings.ReplaceAll(reg, "'", "")
-	}
-	if strings.Contains(reg, "%+v") {
-		reg = strings.ReplaceAll(reg, "%+v", ".+")
-	}
+var verbRegexes = map[string]string{"%d": "\\d", "%s": ".*", "%v": ".*", "%+v": ".+"}
 
-	//Remove the double quotes
-	return reg[1 : len(reg)-1]
+//Converts unquoted log text to the regex format, the text between the
+//verbs is matched literally
+func createRegexFromText(value string) string {
+	//single quotes are removed
+	value = strings.ReplaceAll(value, "'", "")
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range formatVerbs.FindAllStringIndex(value, -1) {
+		sb.WriteString(regexp.QuoteMeta(value[last:loc[0]]))
+		sb.WriteString(verbRegexes[value[loc[0]:loc[1]]])
+		last = loc[1]
+	}
+	sb.WriteString(regexp.QuoteMeta(value[last:]))
+	return sb.String()
 }
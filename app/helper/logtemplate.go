@@ -0,0 +1,491 @@
+package helper
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//---- Log message templates ----------
+type partKind int
+
+const (
+	textPart partKind = iota
+	wildPart
+	paramPart
+	altPart
+)
+
+//templatePart is a piece of a log message derived from the source: literal
+//text, a value that can't be known statically, a parameter of the function
+//the log is in, or a set of alternatives when several assignments reach it
+type templatePart struct {
+	Kind  partKind
+	Text  string
+	Param *types.Var
+	Alts  []logTemplate
+}
+
+type logTemplate []templatePart
+
+func textTemplate(text string) logTemplate {
+	return logTemplate{{Kind: textPart, Text: text}}
+}
+
+func wildTemplate() logTemplate {
+	return logTemplate{{Kind: wildPart}}
+}
+
+func concatTemplates(templates ...logTemplate) logTemplate {
+	ret := logTemplate{}
+	for _, t := range templates {
+		ret = append(ret, t...)
+	}
+	return ret
+}
+
+func joinTemplates(templates []logTemplate, sep string) logTemplate {
+	ret := logTemplate{}
+	for i, t := range templates {
+		if i > 0 && sep != "" {
+			ret = append(ret, textTemplate(sep)...)
+		}
+		ret = append(ret, t...)
+	}
+	return ret
+}
+
+//HasText is true if some part of the message is known, a template
+//made only of wildcards would match every log message
+func (t logTemplate) HasText() bool {
+	for _, part := range t {
+		switch part.Kind {
+		case textPart:
+			if part.Text != "" {
+				return true
+			}
+		case altPart:
+			for _, alt := range part.Alts {
+				if alt.HasText() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+//UsesParams is true if the message depends on the enclosing function's parameters
+func (t logTemplate) UsesParams() bool {
+	for _, part := range t {
+		switch part.Kind {
+		case paramPart:
+			return true
+		case altPart:
+			for _, alt := range part.Alts {
+				if alt.UsesParams() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+//Regex converts the template into the regex format used by the log types
+func (t logTemplate) Regex() string {
+	var sb strings.Builder
+	wild := false
+	for _, part := range t {
+		switch part.Kind {
+		case textPart:
+			if part.Text == "" {
+				continue
+			}
+			sb.WriteString(createRegexFromText(part.Text))
+			wild = false
+		case wildPart, paramPart:
+			//avoid .*.* for neighbouring unknown values
+			if !wild {
+				sb.WriteString(".*")
+			}
+			wild = true
+		case altPart:
+			alts := make([]string, 0, len(part.Alts))
+			seen := make(map[string]struct{})
+			for _, alt := range part.Alts {
+				reg := alt.Regex()
+				if _, ok := seen[reg]; !ok {
+					seen[reg] = struct{}{}
+					alts = append(alts, reg)
+				}
+			}
+			if len(alts) == 1 {
+				sb.WriteString(alts[0])
+			} else {
+				sb.WriteString("(?:" + strings.Join(alts, "|") + ")")
+			}
+			wild = false
+		}
+	}
+	return sb.String()
+}
+
+//---- Definitions of variables ----------
+
+//definition is an assignment to a variable found in the package,
+//appends are the += form on strings
+type definition struct {
+	Pos    token.Pos
+	Rhs    ast.Expr
+	Append bool
+	Block  ast.Node
+}
+
+//Collects every assignment in the package by the object being assigned
+func (pkg *TypedPackage) definitions() map[types.Object][]definition {
+	if pkg.defs != nil {
+		return pkg.defs
+	}
+	defs := make(map[types.Object][]definition)
+	objectOf := func(e ast.Expr) types.Object {
+		id, ok := e.(*ast.Ident)
+		if !ok || pkg.Info == nil {
+			return nil
+		}
+		if obj := pkg.Info.Defs[id]; obj != nil {
+			return obj
+		}
+		return pkg.Info.Uses[id]
+	}
+
+	for _, file := range pkg.Files {
+		stack := make([]ast.Node, 0)
+		enclosing := func() ast.Node {
+			for i := len(stack) - 2; i >= 0; i-- {
+				switch stack[i].(type) {
+				case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+					return stack[i]
+				}
+			}
+			return nil
+		}
+		add := func(lhs ast.Expr, def definition) {
+			if obj := objectOf(lhs); obj != nil {
+				def.Block = enclosing()
+				defs[obj] = append(defs[obj], def)
+			}
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+			stack = append(stack, n)
+
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for i, l := range n.Lhs {
+					def := definition{Pos: n.Pos()}
+					switch n.Tok {
+					case token.ASSIGN, token.DEFINE:
+						if len(n.Lhs) == len(n.Rhs) {
+							def.Rhs = n.Rhs[i]
+						}
+					case token.ADD_ASSIGN:
+						def.Rhs = n.Rhs[i]
+						def.Append = true
+					}
+					add(l, def)
+				}
+			case *ast.IncDecStmt:
+				add(n.X, definition{Pos: n.Pos()})
+			case *ast.RangeStmt:
+				if n.Key != nil {
+					add(n.Key, definition{Pos: n.Pos()})
+				}
+				if n.Value != nil {
+					add(n.Value, definition{Pos: n.Pos()})
+				}
+			case *ast.ValueSpec:
+				for i, name := range n.Names {
+					def := definition{Pos: n.Pos()}
+					if len(n.Values) == len(n.Names) {
+						def.Rhs = n.Values[i]
+					} else if len(n.Values) == 0 {
+						//zero value
+						def.Rhs = &ast.BasicLit{ValuePos: name.Pos(), Kind: token.STRING, Value: `""`}
+					}
+					add(name, def)
+				}
+			case *ast.UnaryExpr:
+				//taking the address lets the variable change anywhere
+				if n.Op == token.AND {
+					add(n.X, definition{Pos: n.Pos()})
+				}
+			}
+			return true
+		})
+	}
+
+	for _, list := range defs {
+		sort.Slice(list, func(i, j int) bool { return list[i].Pos < list[j].Pos })
+	}
+	pkg.defs = defs
+	return defs
+}
+
+//---- Deriving templates from expressions ----------
+
+const maxTemplateDepth = 16
+
+var errorType = types.Universe.Lookup("error").Type()
+
+//templateDeriver derives the log message template for expressions inside
+//a single function, using constant folding from the type checker and the
+//assignments that reach the log call for variables
+type templateDeriver struct {
+	proj   *TypedProject
+	pkg    *TypedPackage
+	fn     *ast.FuncDecl
+	params map[types.Object]*types.Var
+	depth  int
+}
+
+func newTemplateDeriver(proj *TypedProject, pkg *TypedPackage, fn *ast.FuncDecl) *templateDeriver {
+	d := &templateDeriver{
+		proj:   proj,
+		pkg:    pkg,
+		fn:     fn,
+		params: make(map[types.Object]*types.Var),
+	}
+	if fn != nil && fn.Type.Params != nil && pkg.Info != nil {
+		for _, field := range fn.Type.Params.List {
+			for _, name := range field.Names {
+				if v, ok := pkg.Info.Defs[name].(*types.Var); ok {
+					d.params[v] = v
+				}
+			}
+		}
+	}
+	return d
+}
+
+//Template for the message of a log call, format functions (Msgf, Printf, ...)
+//use their first argument, Println style functions space out their arguments
+func (d *templateDeriver) logCall(call *ast.CallExpr, name string) logTemplate {
+	if len(call.Args) == 0 {
+		return logTemplate{}
+	}
+	if strings.HasSuffix(name, "f") {
+		return d.expr(call.Args[0], call.Pos())
+	}
+	args := make([]logTemplate, 0, len(call.Args))
+	for _, arg := range call.Args {
+		args = append(args, d.expr(arg, call.Pos()))
+	}
+	if strings.HasSuffix(name, "ln") || len(args) > 1 {
+		return joinTemplates(args, " ")
+	}
+	return args[0]
+}
+
+//Template for the value of the expression at the given position
+func (d *templateDeriver) expr(e ast.Expr, at token.Pos) logTemplate {
+	if e == nil || d.depth > maxTemplateDepth {
+		return wildTemplate()
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	//constant folding handles literals, constants from any
+	//package and concatenations of them
+	if d.pkg.Info != nil {
+		if tv, ok := d.pkg.Info.Types[e]; ok && tv.Value != nil {
+			if tv.Value.Kind() == constant.String {
+				return textTemplate(constant.StringVal(tv.Value))
+			}
+			return textTemplate(tv.Value.ExactString())
+		}
+	}
+
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			if s, err := strconv.Unquote(e.Value); err == nil {
+				return textTemplate(s)
+			}
+		}
+		return textTemplate(e.Value)
+	case *ast.ParenExpr:
+		return d.expr(e.X, at)
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD && d.isString(e) {
+			return concatTemplates(d.expr(e.X, at), d.expr(e.Y, at))
+		}
+	case *ast.CallExpr:
+		return d.call(e, at)
+	case *ast.Ident:
+		return d.variable(e, at)
+	case *ast.SelectorExpr:
+		//variables of other packages, fields are unknown
+		if _, ok := d.pkg.Info.Selections[e]; !ok {
+			return d.variable(e.Sel, at)
+		}
+	}
+	return wildTemplate()
+}
+
+//Calls that build messages: fmt.Sprintf, fmt.Errorf, errors.New, err.Error()
+func (d *templateDeriver) call(call *ast.CallExpr, at token.Pos) logTemplate {
+	switch d.calleeName(call) {
+	case "fmt.Sprintf", "fmt.Errorf":
+		if len(call.Args) > 0 {
+			return d.expr(call.Args[0], at)
+		}
+	case "fmt.Sprint", "errors.New":
+		args := make([]logTemplate, 0, len(call.Args))
+		for _, arg := range call.Args {
+			args = append(args, d.expr(arg, at))
+		}
+		return joinTemplates(args, "")
+	case "fmt.Sprintln":
+		args := make([]logTemplate, 0, len(call.Args))
+		for _, arg := range call.Args {
+			args = append(args, d.expr(arg, at))
+		}
+		return joinTemplates(args, " ")
+	}
+
+	//err.Error() has the message of whatever created err
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Error" && len(call.Args) == 0 {
+		return d.expr(sel.X, at)
+	}
+	return wildTemplate()
+}
+
+//Package qualified name of the called function (ex: fmt.Sprintf)
+func (d *templateDeriver) calleeName(call *ast.CallExpr) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || d.pkg.Info == nil {
+		return ""
+	}
+	if fn, ok := d.pkg.Info.Uses[sel.Sel].(*types.Func); ok && fn.Pkg() != nil {
+		if _, isMethod := d.pkg.Info.Selections[sel]; !isMethod {
+			return fn.Pkg().Path() + "." + fn.Name()
+		}
+		return ""
+	}
+	if id, ok := sel.X.(*ast.Ident); ok {
+		if pkgName, ok := d.pkg.Info.Uses[id].(*types.PkgName); ok {
+			return pkgName.Imported().Path() + "." + sel.Sel.Name
+		}
+	}
+	return ""
+}
+
+func (d *templateDeriver) isString(e ast.Expr) bool {
+	if d.pkg.Info == nil {
+		return false
+	}
+	t := d.pkg.Info.TypeOf(e)
+	if t == nil {
+		return false
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+//Template for a variable from the assignments that reach the position
+func (d *templateDeriver) variable(id *ast.Ident, at token.Pos) logTemplate {
+	if d.pkg.Info == nil {
+		return wildTemplate()
+	}
+	obj, ok := d.pkg.Info.Uses[id].(*types.Var)
+	if !ok || !(d.isString(id) || types.Identical(obj.Type(), errorType)) {
+		return wildTemplate()
+	}
+
+	//package level variables may live in another package of the project
+	pkg := d.pkg
+	if obj.Pkg() != nil && obj.Pkg() != d.pkg.Types {
+		if pkg = d.proj.PackageOf(obj); pkg == nil {
+			return wildTemplate()
+		}
+	}
+	inner := &templateDeriver{proj: d.proj, pkg: pkg, fn: d.fn, params: d.params, depth: d.depth}
+	if pkg != d.pkg {
+		inner.fn = nil
+		inner.params = map[types.Object]*types.Var{}
+	}
+	return inner.valueAt(obj, at)
+}
+
+//Combines the definitions of the object reaching the position
+func (d *templateDeriver) valueAt(obj *types.Var, at token.Pos) logTemplate {
+	reaching, complete := d.reachingDefs(obj, at)
+
+	alts := make([]logTemplate, 0, len(reaching)+1)
+	for _, def := range reaching {
+		alts = append(alts, d.definitionValue(obj, def))
+	}
+	if param, ok := d.params[obj]; ok && !complete {
+		alts = append(alts, logTemplate{{Kind: paramPart, Param: param}})
+	}
+
+	switch len(alts) {
+	case 0:
+		return wildTemplate()
+	case 1:
+		return alts[0]
+	}
+	return logTemplate{{Kind: altPart, Alts: alts}}
+}
+
+func (d *templateDeriver) definitionValue(obj *types.Var, def definition) logTemplate {
+	if def.Rhs == nil {
+		return wildTemplate()
+	}
+	if def.Append {
+		return concatTemplates(d.valueAt(obj, def.Pos), d.expr(def.Rhs, def.Pos))
+	}
+	return d.expr(def.Rhs, def.Pos)
+}
+
+//Definitions that can reach the position: walking back from the closest one,
+//stop once a definition is in a block that also contains the position since
+//it hides every earlier one. Package level variables fall back to their
+//declaration when the function doesn't assign them. The bool is false when
+//the value on entry to the function may still reach the position.
+func (d *templateDeriver) reachingDefs(obj *types.Var, at token.Pos) ([]definition, bool) {
+	all := d.pkg.definitions()[obj]
+	local := make([]definition, 0)
+	var global *definition
+	for i, def := range all {
+		if def.Block == nil {
+			global = &all[i]
+			continue
+		}
+		if d.fn != nil && def.Pos >= d.fn.Pos() && def.Pos < d.fn.End() && def.Pos < at {
+			local = append(local, def)
+		}
+	}
+
+	reaching := make([]definition, 0)
+	for i := len(local) - 1; i >= 0; i-- {
+		def := local[i]
+		reaching = append(reaching, def)
+		if def.Block.Pos() <= at && at < def.Block.End() {
+			return reaching, true
+		}
+	}
+	if global != nil {
+		return append(reaching, *global), true
+	}
+	return reaching, false
+}
@@ -0,0 +1,133 @@
+package helper
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+//maxWrapperDepth bounds how many wrappers of wrappers are followed
+const maxWrapperDepth = 5
+
+//callSite is a call to a function declared in the project
+type callSite struct {
+	pkg    *TypedPackage
+	call   *ast.CallExpr
+	caller *ast.FuncDecl
+}
+
+//wrapperInstance is the message of a logging wrapper at one of its call sites
+type wrapperInstance struct {
+	template logTemplate
+	pos      token.Pos
+	caller   *ast.FuncDecl
+}
+
+//Calls to the function anywhere in the project, the index
+//is built the first time it is needed
+func (proj *TypedProject) callSitesOf(fn *types.Func) []callSite {
+	if proj.calls == nil {
+		proj.calls = make(map[*types.Func][]callSite)
+		for _, pkg := range proj.Pkgs {
+			if pkg.Info == nil {
+				continue
+			}
+			for _, file := range pkg.Files {
+				var caller *ast.FuncDecl
+				ast.Inspect(file, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case *ast.FuncDecl:
+						caller = n
+					case *ast.CallExpr:
+						var id *ast.Ident
+						switch fun := n.Fun.(type) {
+						case *ast.Ident:
+							id = fun
+						case *ast.SelectorExpr:
+							id = fun.Sel
+						}
+						if callee, ok := pkg.Info.Uses[id].(*types.Func); ok && id != nil {
+							proj.calls[callee] = append(proj.calls[callee], callSite{pkg, n, caller})
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+	return proj.calls[fn]
+}
+
+//Replaces the parameters in a wrapper's log template with the arguments
+//of every call to it. When the arguments are parameters of the caller as
+//well, the callers of the caller are followed, so the log types point at
+//the call that decides the message.
+func (proj *TypedProject) expandWrapperLog(template logTemplate, fn *types.Func, depth int, stack map[*types.Func]bool) []wrapperInstance {
+	instances := make([]wrapperInstance, 0)
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok {
+		return instances
+	}
+
+	for _, site := range proj.callSitesOf(fn) {
+		d := newTemplateDeriver(proj, site.pkg, site.caller)
+		concrete := substituteParams(template, sig, site.call, d)
+
+		if concrete.UsesParams() && site.caller != nil && depth < maxWrapperDepth {
+			if caller, ok := site.pkg.Info.Defs[site.caller.Name].(*types.Func); ok && !stack[caller] {
+				stack[caller] = true
+				outer := proj.expandWrapperLog(concrete, caller, depth+1, stack)
+				delete(stack, caller)
+				if len(outer) > 0 {
+					instances = append(instances, outer...)
+					continue
+				}
+			}
+		}
+		instances = append(instances, wrapperInstance{concrete, site.call.Pos(), site.caller})
+	}
+	return instances
+}
+
+//Replaces each parameter of the signature in the template by the
+//template of the matching argument of the call
+func substituteParams(template logTemplate, sig *types.Signature, call *ast.CallExpr, d *templateDeriver) logTemplate {
+	ret := logTemplate{}
+	for _, part := range template {
+		switch part.Kind {
+		case paramPart:
+			ret = append(ret, argumentFor(part.Param, sig, call, d)...)
+		case altPart:
+			alts := make([]logTemplate, 0, len(part.Alts))
+			for _, alt := range part.Alts {
+				alts = append(alts, substituteParams(alt, sig, call, d))
+			}
+			ret = append(ret, templatePart{Kind: altPart, Alts: alts})
+		default:
+			ret = append(ret, part)
+		}
+	}
+	return ret
+}
+
+func argumentFor(param *types.Var, sig *types.Signature, call *ast.CallExpr, d *templateDeriver) logTemplate {
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		if params.At(i) != param {
+			continue
+		}
+		if i >= len(call.Args) {
+			return wildTemplate()
+		}
+		//the variadic parameter holds every remaining argument
+		if sig.Variadic() && i == params.Len()-1 && !call.Ellipsis.IsValid() {
+			args := make([]logTemplate, 0)
+			for _, arg := range call.Args[i:] {
+				args = append(args, d.expr(arg, call.Pos()))
+			}
+			return joinTemplates(args, " ")
+		}
+		return d.expr(call.Args[i], call.Pos())
+	}
+	return wildTemplate()
+}
@@ -28,6 +28,16 @@ type StackTraceStruct struct {
 	FileName    []string
 	LineNum     []string
 	FuncName    []string
+	Recovered   bool             //a deferred function recovered the panic and panicked again
+	CreatedBy   *GoroutineOrigin //set when the panic is in a goroutine started in the project
+}
+
+//GoroutineOrigin is the go statement a goroutine was started by
+type GoroutineOrigin struct {
+	FuncName    string
+	PackageName string
+	FileName    string
+	LineNum     string
 }
 
 //Helper function to grab OS separator
@@ -88,6 +98,7 @@ func ParsePanic(projectRoot string, stackMessage string) StackTraceStruct {
 	id := 1
 	tempFuncName := ""
 	functionFound := false
+	var createdBy *GoroutineOrigin
 
 	//Scan through each line of log file and do analysis
 	for index := range stackStrs {
@@ -121,6 +132,49 @@ func ParsePanic(projectRoot string, stackMessage string) StackTraceStruct {
 			}
 		}
 
+		//A recovered panic that was raised again starts with
+		//panic: first [recovered]
+		//	panic: second
+		//or, since go 1.23, panic: first [recovered, repanicked]
+		//and the top of the stack is the recovering handler
+		trimmed := strings.TrimSpace(logStr)
+		if strings.HasPrefix(logStr, "panic: ") && (strings.HasSuffix(trimmed, "[recovered]") || strings.HasSuffix(trimmed, "[recovered, repanicked]")) {
+			tempStackTrace.Recovered = true
+		}
+
+		//The frame after "created by main.Start" is the go statement
+		//the goroutine was started by
+		if strings.HasPrefix(logStr, "created by ") {
+			name := strings.TrimPrefix(logStr, "created by ")
+			if end := strings.Index(name, " in goroutine"); end != -1 {
+				name = name[:end]
+			}
+			if enclosing, ok := enclosingFunction(name + "()"); ok {
+				name = enclosing
+			} else {
+				name = name[strings.LastIndex(name, ".")+1:]
+			}
+			createdBy = &GoroutineOrigin{FuncName: name}
+			functionFound = false
+			continue
+		}
+		if createdBy != nil && strings.Contains(logStr, ".go") {
+			if _, found := functionsMap[createdBy.FuncName]; found {
+				path := MapPath(logStr[strings.Index(logStr, "/"):strings.LastIndex(logStr, ":")])
+				fileName := path[strings.LastIndex(path, "/")+1:]
+				if _, ok := localFilesMap[fileName]; ok {
+					if file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly); err == nil {
+						createdBy.PackageName = file.Name.Name
+						createdBy.FileName = fileName
+						createdBy.LineNum = strings.Fields(logStr[strings.LastIndex(logStr, ":")+1:])[0]
+						tempStackTrace.CreatedBy = createdBy
+					}
+				}
+			}
+			createdBy = nil
+			continue
+		}
+
 		//Read the function line first
 		//!-- NOTE: assuming there is no function named go() --!
 		//Process function name lines (doesn't contain .go)
@@ -166,6 +220,13 @@ func ParsePanic(projectRoot string, stackMessage string) StackTraceStruct {
 				}
 			}
 
+			//Function literals (like a deferred handler) are part of the function they are in
+			if _, found := functionsMap[tempFuncName]; !found {
+				if name, ok := enclosingFunction(logStr); ok {
+					tempFuncName = name
+				}
+			}
+
 			//If found, add to list of function names
 			// bug with app.go function -- inside handleRequest issue with returning a function
 			if _, found := functionsMap[tempFuncName]; found {
@@ -183,7 +244,7 @@ func ParsePanic(projectRoot string, stackMessage string) StackTraceStruct {
 			fileName := logStr[strings.LastIndex(logStr, "/")+1 : strings.LastIndex(logStr, ":")]
 
 			//store package name instead of file name
-			file, err := parser.ParseFile(token.NewFileSet(), logStr[strings.Index(logStr, "/"):strings.LastIndex(logStr, ":")], nil, parser.ParseComments)
+			file, err := parser.ParseFile(token.NewFileSet(), MapPath(logStr[strings.Index(logStr, "/"):strings.LastIndex(logStr, ":")]), nil, parser.ParseComments)
 			if err != nil {
 				panic(err)
 			}
@@ -226,6 +287,55 @@ func ParsePanic(projectRoot string, stackMessage string) StackTraceStruct {
 	return finalStkTrc
 }
 
+//PathMapping replaces the From prefix of stack trace file paths with To
+type PathMapping struct {
+	From string
+	To   string
+}
+
+//PathMappings are applied to the files of a stack trace, it is set from the configuration
+var PathMappings = []PathMapping{}
+
+//MapPath rewrites the path with the first mapping whose prefix matches
+func MapPath(path string) string {
+	for _, mapping := range PathMappings {
+		if strings.HasPrefix(path, mapping.From) {
+			return mapping.To + strings.TrimPrefix(path, mapping.From)
+		}
+	}
+	return path
+}
+
+//Function literals are named after the function they are in, Fn.func1
+//(or Fn.func1.2 when nested)
+func enclosingFunction(frame string) (string, bool) {
+	if end := strings.LastIndex(frame, "("); end != -1 {
+		frame = frame[:end]
+	}
+	frame = frame[strings.LastIndex(frame, "/")+1:]
+
+	parts := strings.Split(frame, ".")
+	literal := false
+	for len(parts) > 1 && isLiteralName(parts[len(parts)-1]) {
+		parts = parts[:len(parts)-1]
+		literal = true
+	}
+	return parts[len(parts)-1], literal && len(parts) > 1
+}
+
+func isLiteralName(name string) bool {
+	name = strings.TrimPrefix(name, "func")
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func splitStackTraceString(sts string) []string {
 	return strings.Split(sts, "\n")
 }
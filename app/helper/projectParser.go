@@ -5,96 +5,26 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"path/filepath"
 	"sourcecrawler/app/model"
 	"strconv"
-	"strings"
 
 	"github.com/rs/zerolog/log"
 )
 
-type varDecls struct {
-	asns  []*ast.AssignStmt
-	decls []*ast.ValueSpec
-}
-
-//find index of a logtype for value changing
-func indexOf(elt model.LogType, arr []model.LogType) (int, bool) {
-	for k, v := range arr {
-		if elt == v {
-			return k, true
-		}
-	}
-	return -1, false
-}
-
 //Parse project to create log types
 func ParseProject(projectRoot string) []model.LogType {
 
 	//Holds a slice of log types
 	logTypes := []model.LogType{}
-	variableDeclarations := varDecls{}
-	variablesUsedInLogs := map[string]struct{}{}
-	filesToParse := GatherGoFiles(projectRoot)
-
-	//parse each file to collect logs and the variables used in them
-	//as well as collecting variables declared in the file for later use
-	for _, file := range filesToParse {
-		newLogTypes, newVariablesUsedInLogs := findLogsInFile(file, projectRoot)
-		logTypes = append(logTypes, newLogTypes...)
-		for key := range newVariablesUsedInLogs {
-			variablesUsedInLogs[key] = struct{}{}
-		}
-
-		newVarDecls := findVariablesInFile(file)
-		variableDeclarations.asns = append(variableDeclarations.asns, newVarDecls.asns...)
-		variableDeclarations.decls = append(variableDeclarations.decls, newVarDecls.decls...)
-	}
 
-	//match direct references to variables and update regex values
-	//note: may require checking scope if duplicate names are used
-	for _, asn := range variableDeclarations.asns {
-		fnName := fmt.Sprint(asn.Lhs[0])
-		_, ok := variablesUsedInLogs[fnName]
-		if ok {
-			//find variable in logTypes, update value
-			for _, logType := range logTypes {
-				if logType.Regex == fnName {
-					ndx, ok := indexOf(logType, logTypes)
-					if ok {
-						//This could be enhanced to detect non-literal values
-						//such as function returns, but currently just accept
-						//literal values
-						newVal, ok := asn.Rhs[0].(*ast.BasicLit)
-						if ok && len(newVal.Value) > 1 {
-							logTypes[ndx].Regex = newVal.Value[1 : len(newVal.Value)-1]
-						}
-					}
-				}
-			}
-		}
-	}
-	for _, decl := range variableDeclarations.decls {
-		fnName := fmt.Sprint(decl.Names[0])
-		_, ok := variablesUsedInLogs[fnName]
-		if ok {
-			//find variable in logTypes, update value
-			for _, logType := range logTypes {
-				if logType.Regex == fnName {
-					ndx, ok := indexOf(logType, logTypes)
-					if ok {
-						if len(decl.Values) > 0 {
-							//This could be enhanced to detect non-literal values
-							//such as function returns, but currently just accept
-							//literal values
-							newVal, ok := decl.Values[0].(*ast.BasicLit)
-							if ok && len(newVal.Value) > 1 {
-								logTypes[ndx].Regex = newVal.Value[1 : len(newVal.Value)-1]
-							}
-						}
-					}
-				}
-			}
+	//type check the whole project so the values used in log
+	//messages can be resolved across files and packages
+	proj := LoadTypedProject(projectRoot)
+	for _, pkg := range proj.Pkgs {
+		for _, file := range pkg.Files {
+			logTypes = append(logTypes, findLogsInFile(proj, pkg, file)...)
 		}
 	}
 
@@ -153,136 +83,36 @@ func findFunctionNodes(filesToParse []string) []FdeclStruct {
 //Can be changed/removed if desired,
 //currently just a placeholder
 type fnStruct struct {
-	n              ast.Node
-	fn             *ast.CallExpr
-	parentFn       *ast.FuncDecl
-	usedParentArgs []*ast.Ident
+	n           ast.Node
+	fn          *ast.CallExpr
+	parentFn    *ast.FuncDecl
+	enclosingFn *ast.FuncDecl
 }
 
 /*
-Current idea:
+Logging wrappers:
 	for each function declaration
 		if it contains a logging statement
-			if the logging statement's args using 1+ of the functions args
+			if the logging statement's message uses 1+ of the functions args
 				store it with an associated parent function
 			else
 				store it without an associated parent function
 
 	for each logging statement using parent function argument(s)
 		for each callexpr of that function
-			store with its message information
+			store with its message information (see expandWrapperLog)
 */
 
-func usesParentArgs(parent *ast.FuncDecl, call *ast.CallExpr) []*ast.Ident {
-	//fmt.Println("checking if", call, "depends on", parent.Name)
-	args := make([]*ast.Ident, 0)
-	if call == nil || parent == nil || parent.Type == nil || parent.Type.Params == nil {
-		return args
-	}
-	if parent.Type.Params.List == nil || len(parent.Type.Params.List) == 0 {
-		return args
-	}
-
-	// Gather all parent parameter names
-	params := make([]string, 0)
-	for _, field := range parent.Type.Params.List {
-		if field == nil {
-			continue
-		}
-		for _, param := range field.Names {
-			params = append(params, param.Name)
-		}
-	}
-
-	// Check if any call arguments are from the parent function
-	for _, arg := range call.Args {
-		switch arg := arg.(type) {
-		case *ast.Ident:
-			if arg == nil || arg.Obj == nil {
-				continue
-			}
-			for _, param := range params {
-				if arg.Name == param {
-					if arg.Obj.Kind == ast.Var || arg.Obj.Kind == ast.Con {
-						// Found an argument used by the parent in the logging call expression
-						// or a constant we can find the value of
-						//fmt.Println("\tcall expression uses", param)
-						args = append(args, arg)
-					}
-				}
-			}
-		case *ast.CallExpr:
-			// Check for nested function calls that use the parent's argument
-			// Still need to keep track of all the nested functions somehow
-			if arg != nil {
-				args = append(args, usesParentArgs(parent, arg)...)
-			}
-		}
-	}
-
-	return args
-}
-
-func findVariablesInFile(path string) varDecls {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
-	if err != nil {
-		log.Error().Err(err).Msg("unable to parse file")
-	}
-	vars := varDecls{}
-
-	//Check for nil node
-	if node == nil {
-		return varDecls{
-			asns:  nil,
-			decls: nil,
-		}
-	}
-
-	ast.Inspect(node, func(n ast.Node) bool {
-		//The following two blocks are related to finding variables
-		//and their values
-
-		//filter nodes that represent variable asns and decls
-
-		//These follow pattern "name :=/= value"
-		asn, ok := n.(*ast.AssignStmt)
-		if ok {
-			vars.asns = append(vars.asns, asn)
-		}
-
-		//These nodes follow pattern "var/const name = value"
-		expr, ok := n.(*ast.GenDecl)
-		if ok {
-			spec, ok := expr.Specs[0].(*ast.ValueSpec)
-			if ok {
-				vars.decls = append(vars.decls, spec)
-			}
-		}
-		return true
-	})
-
-	return vars
-}
-
-// Returns logTypes with map struct
-func findLogsInFile(path string, base string) ([]model.LogType, map[string]struct{}) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
-	if err != nil {
-		log.Error().Err(err).Msg("unable to parse file")
-	}
+// Returns the logTypes found in a file of the project
+func findLogsInFile(proj *TypedProject, pkg *TypedPackage, node *ast.File) []model.LogType {
+	fset := proj.Fset
 
-	varsInLogs := map[string]struct{}{}
 	logInfo := []model.LogType{}
 	logCalls := []fnStruct{}
 
-	//Helper structure to hold logTypes with function types (msg, msgf, err)
-	//lnTypes := make(map[model.LogType]string)
-
 	//Check for nil node
 	if node == nil {
-		return nil, nil
+		return nil
 	}
 
 	//Filter out nodes that do not contain a call to Msg or Msgf
@@ -293,11 +123,6 @@ func findLogsInFile(path string, base string) ([]model.LogType, map[string]struc
 	ast.Inspect(node, func(n ast.Node) bool {
 		// Keep track of the current parent function the log statement is contained in
 		if funcDecl, ok := n.(*ast.FuncDecl); ok {
-			// fmt.Println("checking funcDecl", funcDecl.Name)
-			// cfg := FnCfgCreator{}
-			// root := cfg.CreateCfg(funcDecl, base, fset, map[int]string{})
-			// printCfg(root, "")
-			// fmt.Println()
 			parentFn = funcDecl
 		}
 
@@ -309,35 +134,16 @@ func findLogsInFile(path string, base string) ([]model.LogType, map[string]struc
 			//continue processing if CallExpr casts
 			//as a SelectorExpr
 
-			//Additional processing for "log" functions in std go library (Ex: log.Print, log.Println)
-			basicLog := strings.Contains(fmt.Sprint(ret.Fun), "log")
-
-			if fn, ok := ret.Fun.(*ast.SelectorExpr); ok {
-				// fmt.Printf("%T, %v\n", fn, fn)
-				//convert Selector into String for comparison
-				val := fmt.Sprint(fn.Sel)
-
-				//fmt.Println("Val: " + val)
-
-				//Should recursively call a function to check if
-				//the preceding SelectorExpressions contain a call
-				//to log, which means this is most
-				//definitely a log statement
-				if (strings.Contains(val, "Msg") || val == "Err" || val == "Errorf" || basicLog) && IsFromLog(fn) {				
-					parentArgs := usesParentArgs(parentFn, ret)
-					value := fnStruct{
-						n:              n,
-						fn:             ret,
-						parentFn:       nil,
-						usedParentArgs: parentArgs,
-					}
-					// Check if the log call depends on a parent function argument
-					// and if it does, specify the parent function
-					if len(parentArgs) > 0 {
-						value.parentFn = parentFn
-					}
-					logCalls = append(logCalls, value)
-				}
+			//the message methods (Msg, Msgf...) and the functions of
+			//the std go library (Ex: log.Print, log.Println) of a call
+			//chain starting at a logger
+			if Recognizer.IsLogCall(ret) {
+				logCalls = append(logCalls, fnStruct{
+					n:           n,
+					fn:          ret,
+					parentFn:    nil,
+					enclosingFn: parentFn,
+				})
 			}
 		}
 		return true
@@ -345,69 +151,74 @@ func findLogsInFile(path string, base string) ([]model.LogType, map[string]struc
 
 	//AT THIS POINT
 	//all log messages should be collected, the next section
-	//demonstrates extracting the string arguments from each
-	//log isntance
-
-	//This block is for accessing the argument
-	//values of a selector function so we can see
-	//what was added by the inspection above
+	//derives the message template of each log instance from
+	//its arguments (literals, constants, concatenations,
+	//fmt.Sprintf, errors.New and the variables holding them)
 	for _, l := range logCalls {
 		currentLog := model.LogType{}
-		// fn, _ := l.fn.Fun.(*ast.SelectorExpr)
-		// fmt.Printf("Args for %v at line %d\n", fn.Sel, fset.Position(l.n.Pos()).Line)
-
-		//relPath, _ := filepath.Rel(base, fset.File(l.n.Pos()).Name())
-		//currentLog.FilePath = filepath.ToSlash(relPath) //TODO: bug with empty path, using absolute path right now
 
 		currentLog.FilePath = fset.File(l.n.Pos()).Name()
 		currentLog.LineNumber = fset.Position(l.n.Pos()).Line
-		for _, a := range l.fn.Args {
-			// good := false
-			//later will be used to call functions
-			//to extract data more eficiently for multiple
-			//types of arguments
-			switch v := a.(type) {
-
-			//this case catches string literals,
-			//our proof-of-concept case
-			case *ast.BasicLit:
-				// good = true
-				// fmt.Println("Basic", v.Value)
-
-				currentLog.Regex = CreateRegex(v.Value)
-
-				logInfo = append(logInfo, currentLog)
-
-			//this case catches composite literals
-			case *ast.CompositeLit:
-				// fmt.Println("Composite", v.Elts)
-
-			//This case represents variables used as log arguments
-			case *ast.Ident:
-				//store var name, will be   updated later
-				//currentLog.Regex = v.Name
-
-				// fmt.Printf("%v, ", v.Name)
-
-				//add an entry in the map for the variable name
-				//so we can check if declarations refer to a
-				//variable used in a log statement
-				varsInLogs[v.Name] = struct{}{}
-				// good = true
-
-			default:
-				//fmt.Println("type arg", reflect.TypeOf(a), a)
+		currentLog.FunctionName = FuncDeclName(l.enclosingFn)
+
+		name := l.fn.Fun.(*ast.SelectorExpr).Sel.Name
+		template := newTemplateDeriver(proj, pkg, l.enclosingFn).logCall(l.fn, name)
+
+		// Check if the log call depends on a parent function argument
+		// and if it does, specify the parent function
+		if template.UsesParams() {
+			l.parentFn = l.enclosingFn
+		}
+
+		//the message of a logging wrapper comes from its callers,
+		//so there's a log type at every call site instead
+		if l.parentFn != nil {
+			if parent, ok := pkg.Info.Defs[l.parentFn.Name].(*types.Func); ok {
+				instances := proj.expandWrapperLog(template, parent, 1, map[*types.Func]bool{parent: true})
+				for _, instance := range instances {
+					if !instance.template.HasText() {
+						continue
+					}
+					position := fset.Position(instance.pos)
+					logInfo = append(logInfo, model.LogType{
+						FilePath:     position.Filename,
+						LineNumber:   position.Line,
+						FunctionName: FuncDeclName(instance.caller),
+						Regex:        instance.template.Regex(),
+					})
+				}
+				if len(instances) > 0 {
+					continue
+				}
 			}
-			//if the type is known and handled,
-			//add it to the result array
-			// if good {
-			// 	logInfo = append(logInfo, currentLog)
-			// }
 		}
-		//fmt.Println()
+
+		//a message with no known text would match every log
+		if !template.HasText() {
+			continue
+		}
+		currentLog.Regex = template.Regex()
+		logInfo = append(logInfo, currentLog)
 	}
 
-	return logInfo, varsInLogs
+	return logInfo
+}
+
+//FuncDeclName gives the name of a function as it appears in
+//stack traces, methods include their receiver (ex: (*App).Run)
+func FuncDeclName(fn *ast.FuncDecl) string {
+	if fn == nil {
+		return ""
+	}
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	switch recv := fn.Recv.List[0].Type.(type) {
+	case *ast.StarExpr:
+		return fmt.Sprintf("(*%v).%s", recv.X, fn.Name.Name)
+	default:
+		return fmt.Sprintf("%v.%s", recv, fn.Name.Name)
+	}
 }
 
 //Helper function to create map of log to regex
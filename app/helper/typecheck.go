@@ -0,0 +1,272 @@
+package helper
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+//TypedPackage is one package (directory + package name) of a project
+//along with the type information gathered when checking it
+type TypedPackage struct {
+	Dir   string
+	Path  string
+	Name  string
+	Files []*ast.File
+	Types *types.Package
+	Info  *types.Info
+
+	checking bool
+	defs     map[types.Object][]definition
+}
+
+//TypedProject holds all of the packages under a project root. Packages are
+//type checked against each other, so constants, variables and functions
+//resolve across files and packages, standard library packages come from
+//export data (or source) and any other import is replaced by an empty package
+type TypedProject struct {
+	Root   string
+	Module string
+	Fset   *token.FileSet
+	Pkgs   []*TypedPackage
+
+	byPath map[string]*TypedPackage
+	std    types.Importer
+	stdSrc types.Importer
+	calls  map[*types.Func][]callSite
+}
+
+//LoadTypedProject parses and type checks every go file under the root
+func LoadTypedProject(projectRoot string) *TypedProject {
+	proj := &TypedProject{
+		Root:   projectRoot,
+		Fset:   token.NewFileSet(),
+		Pkgs:   make([]*TypedPackage, 0),
+		byPath: make(map[string]*TypedPackage),
+	}
+	proj.std = importer.ForCompiler(proj.Fset, "gc", nil)
+	proj.stdSrc = importer.ForCompiler(proj.Fset, "source", nil)
+
+	modDir, module := findModule(projectRoot)
+	proj.Module = module
+
+	//group the files by directory and package name, so external
+	//test packages (package foo_test) are kept apart
+	byDir := make(map[string]*TypedPackage)
+	for _, file := range GatherGoFiles(projectRoot) {
+		node, err := parser.ParseFile(proj.Fset, file, nil, parser.ParseComments)
+		if err != nil || node == nil {
+			log.Error().Err(err).Msg("unable to parse file " + file)
+			continue
+		}
+		dir := filepath.Dir(file)
+		key := dir + ":" + node.Name.Name
+		pkg, ok := byDir[key]
+		if !ok {
+			pkg = &TypedPackage{
+				Dir:  dir,
+				Name: node.Name.Name,
+				Path: importPathFor(modDir, module, projectRoot, dir),
+			}
+			byDir[key] = pkg
+			proj.Pkgs = append(proj.Pkgs, pkg)
+		}
+		pkg.Files = append(pkg.Files, node)
+	}
+
+	//keep a stable order, and let the non-test package own the import path
+	sort.Slice(proj.Pkgs, func(i, j int) bool {
+		if proj.Pkgs[i].Dir != proj.Pkgs[j].Dir {
+			return proj.Pkgs[i].Dir < proj.Pkgs[j].Dir
+		}
+		return proj.Pkgs[i].Name < proj.Pkgs[j].Name
+	})
+	for _, pkg := range proj.Pkgs {
+		if strings.HasSuffix(pkg.Name, "_test") {
+			continue
+		}
+		if _, ok := proj.byPath[pkg.Path]; !ok {
+			proj.byPath[pkg.Path] = pkg
+		}
+	}
+
+	for _, pkg := range proj.Pkgs {
+		proj.check(pkg)
+	}
+
+	return proj
+}
+
+//PackageOf returns the project package that declares the object, if any
+func (proj *TypedProject) PackageOf(obj types.Object) *TypedPackage {
+	if obj == nil || obj.Pkg() == nil {
+		return nil
+	}
+	for _, pkg := range proj.Pkgs {
+		if pkg.Types == obj.Pkg() {
+			return pkg
+		}
+	}
+	return nil
+}
+
+//FileOf returns the file of the package containing the position
+func (pkg *TypedPackage) FileOf(pos token.Pos) *ast.File {
+	for _, file := range pkg.Files {
+		if file.Pos() <= pos && pos <= file.End() {
+			return file
+		}
+	}
+	return nil
+}
+
+//Import satisfies types.Importer so project packages can import each other
+func (proj *TypedProject) Import(importPath string) (*types.Package, error) {
+	if pkg := proj.lookupImport(importPath); pkg != nil {
+		proj.check(pkg)
+		if pkg.Types == nil {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		return pkg.Types, nil
+	}
+
+	if isStdlib(importPath) {
+		//export data is much faster, the sources are the fallback
+		//when the toolchain can't provide it
+		if pkg, err := proj.std.Import(importPath); err == nil {
+			return pkg, nil
+		}
+		return proj.stdSrc.Import(importPath)
+	}
+
+	//third party code isn't loaded, an empty package lets
+	//the checker keep going with everything else
+	fake := types.NewPackage(importPath, guessPackageName(importPath))
+	fake.MarkComplete()
+	return fake, nil
+}
+
+func (proj *TypedProject) check(pkg *TypedPackage) {
+	if pkg.Info != nil || pkg.checking {
+		return
+	}
+	pkg.checking = true
+	defer func() { pkg.checking = false }()
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Implicits:  make(map[ast.Node]types.Object),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{
+		Importer:    proj,
+		FakeImportC: true,
+		//errors are expected since third party packages are empty,
+		//the information gathered so far is still usable
+		Error: func(err error) {},
+	}
+	pkg.Types, _ = conf.Check(pkg.Path, proj.Fset, pkg.Files, info)
+	pkg.Info = info
+}
+
+func (proj *TypedProject) lookupImport(importPath string) *TypedPackage {
+	if pkg, ok := proj.byPath[importPath]; ok {
+		return pkg
+	}
+
+	//without a module the import path is unknown, so match on
+	//the longest directory suffix instead
+	var best *TypedPackage
+	bestLen := 0
+	for _, pkg := range proj.Pkgs {
+		if strings.HasSuffix(pkg.Name, "_test") {
+			continue
+		}
+		rel, err := filepath.Rel(proj.Root, pkg.Dir)
+		if err != nil || rel == "." {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if (importPath == rel || strings.HasSuffix(importPath, "/"+rel)) && len(rel) > bestLen {
+			best = pkg
+			bestLen = len(rel)
+		}
+	}
+	return best
+}
+
+//Walks up from the root looking for a go.mod to get the module path
+func findModule(projectRoot string) (string, string) {
+	dir, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return "", ""
+	}
+	for {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if strings.HasPrefix(line, "module") {
+					return dir, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), "\"")
+				}
+			}
+			return dir, ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+func importPathFor(modDir, module, projectRoot, dir string) string {
+	if module != "" {
+		if rel, err := filepath.Rel(modDir, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			if rel == "." {
+				return module
+			}
+			return module + "/" + filepath.ToSlash(rel)
+		}
+	}
+	rel, err := filepath.Rel(projectRoot, dir)
+	if err != nil {
+		return dir
+	}
+	return filepath.ToSlash(rel)
+}
+
+//Standard library paths don't have a domain in their first element
+func isStdlib(importPath string) bool {
+	first := importPath
+	if i := strings.Index(importPath, "/"); i != -1 {
+		first = importPath[:i]
+	}
+	return !strings.Contains(first, ".")
+}
+
+//Best effort package name for an import path (go-z3 -> z3, yaml.v2 -> yaml)
+func guessPackageName(importPath string) string {
+	name := path.Base(importPath)
+	if strings.HasPrefix(name, "v") && len(name) > 1 && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	if i := strings.Index(name, ".v"); i != -1 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.Replace(name, "-", "_", -1)
+}
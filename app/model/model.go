@@ -3,31 +3,77 @@ package model
 import (
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 )
 
+//Project is a code base that has been indexed, identified by its root path
+type Project struct {
+	gorm.Model
+	RootPath  string            `gorm:"unique_index" json:"rootPath"`
+	Revisions []ProjectRevision `json:"revisions,omitempty"`
+}
+
+//ProjectRevision is the state of a project at a git commit, the log types
+//are extracted once per revision. Dirty marks a working tree with changes
+//that aren't committed, those revisions are re-indexed on every request.
+//Recognizer is the hash of the log recognizer settings the log types were
+//extracted with, the revision is re-indexed once they change.
+type ProjectRevision struct {
+	gorm.Model
+	ProjectID  uint      `gorm:"unique_index:idx_project_commit" json:"projectId"`
+	Commit     string    `gorm:"column:git_commit;unique_index:idx_project_commit" json:"commit"`
+	Dirty      bool      `json:"dirty"`
+	Recognizer string    `json:"recognizer"`
+	LogTypes   []LogType `json:"logTypes,omitempty"`
+}
+
 type LogType struct {
 	gorm.Model
-	FilePath   string `json:"filePath"`
-	LineNumber int    `json:"lineNumber"`
-	Regex      string `json:"regex"`
+	ProjectRevisionID uint   `gorm:"index" json:"projectRevisionId"`
+	FilePath          string `json:"filePath"`
+	LineNumber        int    `json:"lineNumber"`
+	FunctionName      string `json:"functionName"`
+	Regex             string `json:"regex"`
 }
 
 type ParseProjectRequest struct {
 	ProjectRoot string `json:"projectRoot"`
 }
 
+//ParseProjectResponse summarizes the revision that was indexed
+type ParseProjectResponse struct {
+	ProjectRoot string `json:"projectRoot"`
+	Commit      string `json:"commit"`
+	Dirty       bool   `json:"dirty"`
+	LogTypes    int    `json:"logTypes"`
+}
+
 type LogSourceRequest struct {
-	LogMessage string `json:"logMessage"`
+	LogMessage  string `json:"logMessage"`
+	ProjectRoot string `json:"projectRoot"` //optional, every project is searched without it
 }
 
+//LogSourceBatchRequest looks up the source of many log lines at once
+type LogSourceBatchRequest struct {
+	LogMessages []string `json:"logMessages"`
+	ProjectRoot string   `json:"projectRoot"`
+}
+
+//LogSourceResponse is where a log message comes from, the arguments are
+//the values that filled the unknown parts of the message, in order
 type LogSourceResponse struct {
-	FilePath   string `json:"filePath"`
-	LineNumber int    `json:"lineNumber"`
-	Regex      string `json:"regex"`
+	LogMessage   string   `json:"logMessage"`
+	FilePath     string   `json:"filePath"`
+	LineNumber   int      `json:"lineNumber"`
+	FunctionName string   `json:"functionName"`
+	Regex        string   `json:"regex"`
+	Arguments    []string `json:"arguments"`
+	Error        string   `json:"error,omitempty"`
 }
 
 // DBMigrate will create and migrate the tables, and then make the some relationships if necessary
 func DBMigrate(db *gorm.DB) *gorm.DB {
-	db.AutoMigrate(&LogType{})
+	db.AutoMigrate(&Project{}, &ProjectRevision{}, &LogType{})
 	return db
-}
\ No newline at end of file
+}
@@ -0,0 +1,30 @@
+package arithmetic
+
+func Divide(a, b int) int {
+	q := a / b
+	return q
+}
+
+func Truncate(a int) {
+	if a/4 == -1 && a%4 == -3 {
+		panic("truncated")
+	}
+}
+
+func Mask(flags uint8) {
+	if flags&0x0f == 0x0a && flags|0x01 == 0xfb {
+		panic("mask")
+	}
+}
+
+func Shift(n int) {
+	if n<<2 == 20 && n>>1 == 2 {
+		panic("shift")
+	}
+}
+
+func Sign(n int8) {
+	if n^-1 == 4 {
+		panic("sign")
+	}
+}
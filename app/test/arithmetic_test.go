@@ -0,0 +1,40 @@
+package test
+
+import (
+	"testing"
+)
+
+func TestArithmetic(t *testing.T) {
+	cases := []struct {
+		fn       string
+		line     int
+		variable string
+		value    int
+		panics   string //condition of the implicit panic
+	}{
+		//the trace ends at the division, it divided by zero
+		{"Divide", 4, "Divide.b", 0, "Divide.b == 0"},
+		//the quotient is truncated towards zero
+		{"Truncate", 10, "Truncate.a", -7, ""},
+		{"Mask", 16, "Mask.flags", 0xfa, ""},
+		{"Shift", 22, "Shift.n", 5, ""},
+		//in two's complement
+		{"Sign", 28, "Sign.n", -5, ""},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			solved, done := solveFixture(t, "arithmetic/arithmetic.go", test.fn, test.line)
+			defer done()
+			if test.panics != "" && (len(solved.conditions) == 0 || solved.conditions[0] != test.panics) {
+				t.Errorf("expected the path to start with %s, found %v", test.panics, solved.conditions)
+			}
+			if solved.model == nil {
+				t.Fatalf("the path %v is unsolvable", solved.conditions)
+			}
+			if v := solved.model.Assignments()[test.variable]; v == nil || v.Int() != test.value {
+				t.Errorf("expected %s = %d, found %v", test.variable, test.value, v)
+			}
+		})
+	}
+}
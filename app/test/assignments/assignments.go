@@ -0,0 +1,37 @@
+package assignments
+
+type Counter struct {
+	total int
+}
+
+func Lower(a, b int, c *Counter, xs []int) {
+	a /= b
+	a %= b
+	a <<= 2
+	a >>= 1
+	a &= b
+	a |= b
+	a ^= b
+	a &^= b
+	c.total += a
+	xs[0] -= a
+	a++
+	a = compute(a) + 1
+	a, b = split(a)
+}
+
+func compute(x int) int {
+	return x * 2
+}
+
+func split(x int) (int, int) {
+	return x / 2, x % 2
+}
+
+func Accumulate(x int) {
+	total := 1
+	total += compute(x)
+	if total > 10 {
+		panic("large")
+	}
+}
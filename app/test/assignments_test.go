@@ -0,0 +1,122 @@
+package test
+
+import (
+	"go/ast"
+	"go/printer"
+	"sourcecrawler/app/cfg"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-z3"
+)
+
+func TestReassignmentConversion(t *testing.T) {
+	file, fset := parseFixture(t, "assignments/assignments.go")
+	var lower *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "Lower" {
+			lower = fn
+		}
+	}
+
+	expected := []string{
+		"a = a / b",
+		"a = a % b",
+		"a = a << 2",
+		"a = a >> 1",
+		"a = a & b",
+		"a = a | b",
+		"a = a ^ b",
+		"a = a &^ b",
+		"c.total = c.total + a",
+		"xs[0] = xs[0] - a",
+		"a = a + 1",
+		//calls are replaced by their results
+		"a = compute() + 1",
+		"a, b = split().0, split().1",
+	}
+	if len(lower.Body.List) != len(expected) {
+		t.Fatalf("expected %d statements, found %d", len(expected), len(lower.Body.List))
+	}
+	for i, stmt := range lower.Body.List {
+		t.Run(expected[i], func(t *testing.T) {
+			assign, _ := cfg.RessignmentConversion(stmt, fset)
+			if assign == nil {
+				t.Fatalf("expected an assignment")
+			}
+			var bf strings.Builder
+			printer.Fprint(&bf, fset, assign)
+			if bf.String() != expected[i] {
+				t.Errorf("expected %s, found %s", expected[i], bf.String())
+			}
+		})
+	}
+
+	//x /= y isn't taken for the equation x == y
+	t.Run("compound", func(t *testing.T) {
+		config := z3.NewConfig()
+		ctx := z3.NewContext(config)
+		config.Close()
+		defer ctx.Close()
+		if condition := cfg.ConvertExprToZ3(ctx, lower.Body.List[0], fset); condition != nil {
+			t.Errorf("expected no condition, found %s", condition)
+		}
+	})
+
+	//the path has the lowered assignments, also without the ssa form
+	t.Run("lowered", func(t *testing.T) {
+		w := wrapFixture(t, file, fset, "Accumulate")
+		paths := cfg.CreateNewPath()
+		paths.TraverseCFG(blockAt(t, w, fset, 35), w)
+		if len(paths.Paths) != 1 {
+			t.Fatalf("expected one path to the panic, found %d", len(paths.Paths))
+		}
+		found := false
+		for _, expr := range paths.Paths[0].Expressions {
+			var bf strings.Builder
+			printer.Fprint(&bf, fset, expr)
+			found = found || bf.String() == "total = total + compute()"
+		}
+		if !found {
+			t.Errorf("expected the lowered sum in %v", paths.Paths[0].Expressions)
+		}
+	})
+
+	//the sum with the result of the call is part of the path
+	t.Run("path", func(t *testing.T) {
+		w := wrapFixture(t, file, fset, "Accumulate")
+		cfg.ConvertCFGtoSSAForm(w)
+		paths := cfg.CreateNewPath()
+		paths.TraverseCFG(blockAt(t, w, fset, 35), w)
+		if len(paths.Paths) != 1 {
+			t.Fatalf("expected one path to the panic, found %d", len(paths.Paths))
+		}
+
+		config := z3.NewConfig()
+		ctx := z3.NewContext(config)
+		config.Close()
+		defer ctx.Close()
+		s := ctx.NewSolver()
+		defer s.Close()
+		found := false
+		for _, expr := range paths.Paths[0].Expressions {
+			var bf strings.Builder
+			printer.Fprint(&bf, fset, expr)
+			found = found || bf.String() == "2Accumulate.total = 1Accumulate.total + 1Accumulate.compute()"
+			if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+				s.Assert(condition)
+			}
+		}
+		if !found {
+			t.Errorf("expected the sum in %v", paths.Paths[0].Expressions)
+		}
+		if s.Check() != z3.True {
+			t.Fatalf("the path %v is unsolvable", paths.Paths[0].Expressions)
+		}
+		m := s.Model()
+		defer m.Close()
+		if v := m.Assignments()["1Accumulate.compute()"]; v == nil || v.Int() < 10 {
+			t.Errorf("expected a result of at least 10, found %v", v)
+		}
+	})
+}
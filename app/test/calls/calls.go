@@ -0,0 +1,19 @@
+package calls
+
+func foo(n int) int {
+	return n + 1
+}
+
+func bar() int {
+	return 2
+}
+
+func Nested() int {
+	a := foo(bar())
+	return a
+}
+
+func Pair() int {
+	a, b := foo(1), bar()
+	return a + b
+}
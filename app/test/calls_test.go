@@ -0,0 +1,48 @@
+package test
+
+import (
+	"go/ast"
+	"sourcecrawler/app/cfg"
+	"strings"
+	"testing"
+)
+
+func TestCallOrder(t *testing.T) {
+	cases := []struct {
+		fn    string
+		calls []string
+	}{
+		{"Nested", []string{"bar", "foo"}},
+		{"Pair", []string{"foo", "bar"}},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			w, _ := fixture(t, "calls/calls.go", test.fn)
+
+			//the calls follow each other, every one with its own wrapper
+			calls := make([]string, 0)
+			seen := make(map[cfg.Wrapper]bool)
+			for _, fn := range fnWrappers(w, map[cfg.Wrapper]bool{})[1:] {
+				if seen[fn] {
+					t.Errorf("%s is wrapped twice", fn.Name())
+				}
+				seen[fn] = true
+				calls = append(calls, fn.Name())
+			}
+			if strings.Join(calls, " ") != strings.Join(test.calls, " ") {
+				t.Errorf("expected the calls %v, found %v", test.calls, calls)
+			}
+
+			//and the last one returns to the rest of the block
+			for _, leaf := range cfg.GetLeafNodes(w) {
+				if b, ok := leaf.(*cfg.BlockWrapper); ok && len(b.Block.Nodes) > 0 {
+					if _, ok := b.Block.Nodes[len(b.Block.Nodes)-1].(*ast.ReturnStmt); ok {
+						continue
+					}
+				}
+				t.Errorf("unexpected exit %v", leaf)
+			}
+		})
+	}
+}
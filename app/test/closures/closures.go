@@ -0,0 +1,29 @@
+package closures
+
+func Twice(n int) int {
+	double := func(x int) int {
+		return x * 2
+	}
+	limit := func() int {
+		bound := n + 1
+		return bound
+	}
+	m := double(n)
+	if m > limit() {
+		panic("too big")
+	}
+	return m
+}
+
+func Start(j int) {
+	go audit()
+	go worker(j)
+}
+
+func audit() {}
+
+func worker(j int) {
+	if j < 0 {
+		panic("negative job")
+	}
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"sourcecrawler/app/cfg"
+	"sourcecrawler/app/helper"
+	"strings"
+	"testing"
+)
+
+//Function wrappers in the expanded graph
+func fnWrappers(w cfg.Wrapper, seen map[cfg.Wrapper]bool) []*cfg.FnWrapper {
+	if seen[w] {
+		return nil
+	}
+	seen[w] = true
+	fns := make([]*cfg.FnWrapper, 0)
+	if fn, ok := w.(*cfg.FnWrapper); ok {
+		fns = append(fns, fn)
+	}
+	for _, child := range w.GetChildren() {
+		fns = append(fns, fnWrappers(child, seen)...)
+	}
+	return fns
+}
+
+func TestClosureNames(t *testing.T) {
+	w, _ := fixture(t, "closures/closures.go", "Twice")
+	cfg.ConvertCFGtoSSAForm(w)
+
+	names := make([]string, 0)
+	for _, fn := range fnWrappers(w, map[cfg.Wrapper]bool{}) {
+		names = append(names, fn.Name())
+	}
+	sort.Strings(names)
+	expected := []string{"Twice", "Twice.lit4:12", "Twice.lit7:11"}
+	if strings.Join(names, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected functions %v, found %v", expected, names)
+	}
+
+	//the closures' own variables are theirs, captured ones stay the function's
+	cases := []struct {
+		fn       string
+		variable string
+	}{
+		{"Twice.lit7:11", "1Twice.lit7:11.bound"},
+		{"Twice.lit7:11", "Twice.n"},
+	}
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			for _, fn := range fnWrappers(w, map[cfg.Wrapper]bool{}) {
+				if fn.Name() != test.fn {
+					continue
+				}
+				found := make([]string, 0)
+				for _, b := range loopBlocks(w, map[cfg.Wrapper]bool{}, map[cfg.Wrapper]bool{}, t) {
+					if b.Outer != fn || b.SSA == nil {
+						continue
+					}
+					for _, node := range b.SSA.Nodes() {
+						ast.Inspect(node, func(node ast.Node) bool {
+							if id, ok := node.(*ast.Ident); ok {
+								found = append(found, id.Name)
+							}
+							return true
+						})
+					}
+				}
+				//the source keeps its names
+				ast.Inspect(fn.Fn.(*ast.FuncLit).Body, func(node ast.Node) bool {
+					if id, ok := node.(*ast.Ident); ok && strings.Contains(id.Name, ".") {
+						t.Errorf("expected the source to be unchanged, found %s", id.Name)
+					}
+					return true
+				})
+				for _, name := range found {
+					if name == test.variable {
+						return
+					}
+				}
+				t.Errorf("expected %s in %v", test.variable, found)
+			}
+		})
+	}
+}
+
+func TestGoroutineOrigin(t *testing.T) {
+	path, err := filepath.Abs("closures/closures.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := strings.Join([]string{
+		"panic: negative job",
+		"",
+		"goroutine 6 [running]:",
+		"sourcecrawler/app/test/closures.worker(0xffffffffffffffff)",
+		"\t" + path + ":27 +0x39",
+		"created by sourcecrawler/app/test/closures.Start in goroutine 1",
+		"\t" + path + ":20 +0x2f",
+		"",
+	}, "\n")
+
+	stack := helper.ParsePanic("closures", trace)
+	if stack.CreatedBy == nil {
+		t.Fatal("the goroutine's origin isn't parsed")
+	}
+	origin := *stack.CreatedBy
+	if origin.FuncName != "Start" || origin.PackageName != "closures" || origin.FileName != "closures.go" || origin.LineNum != "20" {
+		t.Errorf("unexpected origin %+v", origin)
+	}
+	if len(stack.LineNum) != 1 || stack.LineNum[0] != "27" {
+		t.Errorf("expected only the worker's frame, found %v %v", stack.FuncName, stack.LineNum)
+	}
+
+	cases := []struct {
+		name   string
+		origin *helper.GoroutineOrigin
+		worker bool
+	}{
+		{"traced", &origin, true},
+		{"untraced", nil, false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			w, _ := fixture(t, "closures/closures.go", "Start", func(w *cfg.FnWrapper) { w.CreatedBy = test.origin })
+			if callsAfter(w, "audit", map[cfg.Wrapper]bool{}) {
+				t.Error("a goroutine the trace isn't in is expanded")
+			}
+			if callsAfter(w, "worker", map[cfg.Wrapper]bool{}) != test.worker {
+				t.Errorf("expected the worker to be expanded: %v", test.worker)
+			}
+			if test.worker && cfg.FindPanicWrapper(w, &stack) == nil {
+				t.Error("no block for the panic in the worker")
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package test
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sourcecrawler/app/handler"
+	"sourcecrawler/app/helper"
+	"sourcecrawler/config"
+	"strings"
+	"testing"
+)
+
+func TestConfigLayers(t *testing.T) {
+	os.Setenv("SOURCECRAWLER_SLICER_MAX_PATHS", "75")
+	os.Setenv("SOURCECRAWLER_DB_PASSWORD", "secret")
+	defer os.Unsetenv("SOURCECRAWLER_SLICER_MAX_PATHS")
+	defer os.Unsetenv("SOURCECRAWLER_DB_PASSWORD")
+
+	cases := []struct {
+		name         string
+		args         []string
+		addr         string
+		dialect      string
+		timeoutMs    int
+		maxPaths     int
+		maxCallDepth int
+		loggers      []string
+		mappings     int
+	}{
+		{"defaults and env", []string{}, ":3000", "sqlite3", 10000, 75, 32, []string{"log"}, 0},
+		{"yaml", []string{"-config", "config/sourcecrawler.yaml"}, ":4000", "mysql", 2500, 75, 32, []string{"log", "logger"}, 1},
+		{"toml", []string{"-config", "config/sourcecrawler.toml"}, ":5000", "sqlite3", 10000, 75, 4, []string{"log"}, 0},
+		{"flags win", []string{"-config", "config/sourcecrawler.yaml", "-addr", ":6000", "-max-paths", "5", "-path-mappings", "/a=/b,/c=/d"}, ":6000", "mysql", 2500, 5, 32, []string{"log", "logger"}, 2},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := config.Load(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Addr != test.addr || c.DB.Dialect != test.dialect || c.Solver.TimeoutMs != test.timeoutMs ||
+				c.Slicer.MaxPaths != test.maxPaths || c.Slicer.MaxCallDepth != test.maxCallDepth {
+				t.Errorf("unexpected config %+v %+v %+v %+v", c, c.DB, c.Solver, c.Slicer)
+			}
+			if strings.Join(c.Logs.Loggers, ",") != strings.Join(test.loggers, ",") {
+				t.Errorf("expected loggers %v, found %v", test.loggers, c.Logs.Loggers)
+			}
+			if len(c.PathMappings) != test.mappings {
+				t.Errorf("expected %d path mappings, found %v", test.mappings, c.PathMappings)
+			}
+			if c.DB.Password != "secret" {
+				t.Errorf("expected the password from the environment, found %q", c.DB.Password)
+			}
+		})
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		edit func(c *config.Config)
+		err  string
+	}{
+		{"valid", func(c *config.Config) {}, ""},
+		{"dialect", func(c *config.Config) { c.DB.Dialect = "oracle" }, "unsupported db.dialect"},
+		{"mysql without host", func(c *config.Config) { c.DB.Dialect = "mysql"; c.DB.Host = "" }, "db.host"},
+		{"negative limits", func(c *config.Config) { c.Slicer.MaxPaths = -1; c.Solver.TimeoutMs = -1 }, "slicer.maxPaths"},
+		{"path limits", func(c *config.Config) { c.Slicer.MaxDepth = -1; c.Slicer.TimeoutMs = -1 }, "slicer.maxDepth"},
+		{"loop bound", func(c *config.Config) { c.Slicer.LoopBound = 0 }, "slicer.loopBound"},
+		{"call context", func(c *config.Config) { c.Slicer.CallContext = 0 }, "slicer.callContext"},
+		{"backend", func(c *config.Config) { c.Slicer.Backend = "llvm" }, "slicer.backend"},
+		{"no loggers", func(c *config.Config) { c.Logs.Loggers = nil }, "logs.loggers"},
+		{"empty mapping", func(c *config.Config) { c.PathMappings = []config.PathMapping{{To: "/x"}} }, "pathMappings[0].from"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			c := config.Default()
+			test.edit(c)
+			err := c.Validate()
+			if test.err == "" && err != nil {
+				t.Errorf("expected no error, found %v", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("expected an error with %q, found %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	cases := []struct {
+		dsn      string
+		password string
+		expected string
+	}{
+		{"crawler:hunter2@tcp(db:3306)/crawler", "", "crawler:********@tcp(db:3306)/crawler"},
+		{"postgres://crawler:hunter2@db/crawler", "", "postgres://crawler:********@db/crawler"},
+		{"host=db user=crawler password=hunter2 dbname=crawler", "", "host=db user=crawler password=******** dbname=crawler"},
+		{"", "hunter2", ""},
+	}
+
+	for _, test := range cases {
+		c := config.Default()
+		c.DB.DSN = test.dsn
+		c.DB.Password = test.password
+		handler.Configure(c)
+
+		w := httptest.NewRecorder()
+		handler.ShowConfig(nil, w, httptest.NewRequest("GET", "/config", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("config failed: %d", w.Code)
+		}
+		if strings.Contains(w.Body.String(), "hunter2") {
+			t.Errorf("secret leaked: %s", w.Body.String())
+		}
+		shown := config.Config{}
+		json.Unmarshal(w.Body.Bytes(), &shown)
+		if shown.DB.DSN != test.expected {
+			t.Errorf("expected dsn %q, found %q", test.expected, shown.DB.DSN)
+		}
+		if c.DB.DSN != test.dsn || c.DB.Password != test.password {
+			t.Errorf("redacting changed the configuration")
+		}
+	}
+	handler.Configure(config.Default())
+}
+
+func TestMessageMethods(t *testing.T) {
+	recognizer := helper.LogRecognizer{Loggers: []string{"log"}, Methods: config.Default().Logs.Methods}
+	cases := []struct {
+		method  string
+		message bool
+	}{
+		{"Msg", true},
+		{"Msgf", true},
+		{"Err", true},
+		{"Errorf", true},
+		//names starting like a method aren't it
+		{"Error", false},
+		{"ErrorStack", false},
+		{"Msgs", false},
+	}
+
+	for _, test := range cases {
+		t.Run(test.method, func(t *testing.T) {
+			if message := recognizer.IsMessageMethod(test.method); message != test.message {
+				t.Errorf("expected %s to emit the message: %v, found %v", test.method, test.message, message)
+			}
+		})
+	}
+}
+
+func TestLogCalls(t *testing.T) {
+	recognizer := helper.LogRecognizer{Loggers: []string{"log"}, Methods: config.Default().Logs.Methods}
+	cases := []struct {
+		call string
+		log  bool
+	}{
+		{`log.Printf("big")`, true},
+		{`log.Info().Msg("big")`, true},
+		{`log.Logger.Info().Msgf("%d", n)`, true},
+		{`logger.Debugf("even")`, true},
+		//loggers are names identifiers start with
+		{`catalog.Add(n)`, false},
+		{`dialog.Show()`, false},
+		{`fmt.Println("big")`, false},
+		{`print("big")`, false},
+	}
+
+	for _, test := range cases {
+		t.Run(test.call, func(t *testing.T) {
+			expr, err := parser.ParseExpr(test.call)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if log := recognizer.IsLogCall(expr.(*ast.CallExpr)); log != test.log {
+				t.Errorf("expected %s to be a log: %v, found %v", test.call, test.log, log)
+			}
+		})
+	}
+}
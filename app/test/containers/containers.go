@@ -0,0 +1,75 @@
+package containers
+
+func Lookup(m map[string]int, key string) int {
+	v, ok := m[key]
+	if !ok {
+		panic("missing " + key)
+	}
+	return v
+}
+
+func Threshold(m map[string]int, key string) {
+	if v, ok := m[key]; ok && v > 10 {
+		panic("too big")
+	}
+}
+
+func Store(m map[string]int, key string, n int) {
+	m[key] = n
+	if v, ok := m[key]; !ok || v != n {
+		panic("lost")
+	}
+}
+
+func Remove(m map[string]int, key string) {
+	delete(m, key)
+	if _, ok := m[key]; ok {
+		panic("still there")
+	}
+}
+
+func Full(ch chan int) {
+	ch <- 1
+	if len(ch) == cap(ch) {
+		panic("full")
+	}
+}
+
+func Drain(n int) {
+	ch := make(chan int, 3)
+	ch <- n
+	ch <- n
+	<-ch
+	if len(ch) == 2 {
+		panic("unreachable")
+	}
+}
+
+func Received(ch chan int) {
+	v := <-ch
+	if len(ch) == cap(ch) {
+		panic(v)
+	}
+}
+
+func Alias(m map[int]int, a, b int) {
+	m[a] = 1
+	if _, ok := m[b]; !ok && a == b {
+		panic("lost")
+	}
+}
+
+func Frame(m map[int]int, a, b int) {
+	v := m[b]
+	m[a] = 1
+	if m[b] != v && a != b {
+		panic("changed")
+	}
+}
+
+func Other(m map[int]int, a, b int) {
+	m[a] = 1
+	if m[b] != 1 {
+		panic("other")
+	}
+}
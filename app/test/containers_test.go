@@ -0,0 +1,47 @@
+package test
+
+import "testing"
+
+func TestContainers(t *testing.T) {
+	cases := []struct {
+		fn       string
+		line     int
+		solvable bool
+		inputs   []string //expected in the model
+	}{
+		//the key must be missing from the input map
+		{"Lookup", 6, true, []string{"Lookup.key not in Lookup.m"}},
+		{"Threshold", 13, true, []string{"Threshold.key in Threshold.m", "Threshold.m[Threshold.key] = 11"}},
+		//the map has what was written to it, and not what was deleted
+		{"Store", 20, false, nil},
+		{"Remove", 27, false, nil},
+		//the buffer had room for the value sent
+		{"Full", 34, true, []string{"len(Full.ch) = 0", "cap(Full.ch) = 1"}},
+		{"Drain", 44, false, nil},
+		//a buffer is never full after a receive
+		{"Received", 51, false, nil},
+		//equal keys are the same element, a write keeps the other keys
+		{"Alias", 58, false, nil},
+		{"Frame", 66, false, nil},
+		{"Other", 73, true, nil},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			solved, done := solveFixture(t, "containers/containers.go", test.fn, test.line)
+			defer done()
+			if solvable := solved.model != nil; solvable != test.solvable {
+				t.Fatalf("expected the path %v to be solvable: %v, found %v", solved.conditions, test.solvable, solvable)
+			}
+			if !test.solvable {
+				return
+			}
+			inputs := solved.inputs(true)
+			for _, input := range test.inputs {
+				if !inputs[input] {
+					t.Errorf("expected %s in %v", input, inputs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package contexts
+
+import "log"
+
+func Fact(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * Fact(n-1)
+}
+
+func Run(n int) int {
+	return outer(n)
+}
+
+func outer(n int) int {
+	return inner(n) + side(n)
+}
+
+func inner(n int) int {
+	if n < 0 {
+		panic("negative")
+	}
+	return n
+}
+
+func side(n int) int {
+	log.Println("side", n)
+	return check(n)
+}
+
+func check(n int) int {
+	return n
+}
+
+func Later(n int) int {
+	m := side(n)
+	return inner(m)
+}
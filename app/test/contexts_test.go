@@ -0,0 +1,105 @@
+package test
+
+import (
+	"sourcecrawler/app/cfg"
+	"sourcecrawler/app/helper"
+	"testing"
+)
+
+//Wraps the function of the contexts fixture and expands it with the limits
+func contextFixture(t *testing.T, name string, depth int, context int, trace *helper.StackTraceStruct) *cfg.FnWrapper {
+	w, _ := fixture(t, "contexts/contexts.go", name, func(w *cfg.FnWrapper) {
+		w.MaxCallDepth = depth
+		w.CallContext = context
+		w.Trace = trace
+	})
+	return w
+}
+
+//Wrappers of the calls to the named function, expanded ones and summaries
+func callsOf(w cfg.Wrapper, name string) (expanded int, summarized int) {
+	for _, fn := range fnWrappers(w, map[cfg.Wrapper]bool{}) {
+		if fn.Name() != name {
+			continue
+		}
+		if fn.Summarized() {
+			summarized++
+		} else {
+			expanded++
+		}
+	}
+	return expanded, summarized
+}
+
+func TestCallContext(t *testing.T) {
+	cases := []struct {
+		name    string
+		context int
+		clones  int
+	}{
+		{"once", 1, 1},
+		{"twice", 2, 2},
+		{"three times", 3, 3},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			w := contextFixture(t, "Fact", 0, test.context, nil)
+			expanded, summarized := callsOf(w, "Fact")
+			if expanded != test.clones || summarized != 1 {
+				t.Errorf("expected %d clones and a summary, found %d and %d", test.clones, expanded, summarized)
+			}
+		})
+	}
+}
+
+func TestTraceCloning(t *testing.T) {
+	trace := &helper.StackTraceStruct{
+		FuncName: []string{"inner", "outer", "Run"},
+		LineNum:  []string{"22", "17", "13"},
+	}
+
+	cases := []struct {
+		name   string
+		trace  *helper.StackTraceStruct
+		fn     string
+		expand bool
+	}{
+		{"inner on the trace", trace, "inner", true},
+		{"side off the trace", trace, "side", false},
+		{"inner without a trace", nil, "inner", false},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			w := contextFixture(t, "Run", 2, 1, test.trace)
+			expanded, summarized := callsOf(w, test.fn)
+			if expanded+summarized != 1 || (expanded == 1) != test.expand {
+				t.Errorf("expected %s to be expanded: %v, found %d expanded and %d summarized", test.fn, test.expand, expanded, summarized)
+			}
+		})
+	}
+
+	//the caller goes on after a summary, the call on the trace is expanded
+	later := &helper.StackTraceStruct{
+		FuncName: []string{"inner", "Later"},
+		LineNum:  []string{"22", "38"},
+	}
+	if expanded, _ := callsOf(contextFixture(t, "Later", 1, 1, later), "inner"); expanded != 1 {
+		t.Errorf("expected inner to be expanded after the summary of side, found %d", expanded)
+	}
+
+	//the summary keeps the logs of the function and skips its calls
+	w := contextFixture(t, "Run", 2, 1, trace)
+	for _, fn := range fnWrappers(w, map[cfg.Wrapper]bool{}) {
+		if fn.Name() == "check" {
+			t.Error("a call in a summary is expanded")
+		}
+		if fn.Name() == "side" {
+			summary := fn.FirstBlock.(*cfg.BlockWrapper)
+			if len(summary.Block.Nodes) != 1 {
+				t.Errorf("expected the log in the summary, found %v", summary.Block.Nodes)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package defers
+
+import "log"
+
+func Close(n int) int {
+	defer log.Printf("closed")
+	defer cleanup(n)
+	if n > 3 {
+		panic("too many")
+	}
+	return n
+}
+
+func cleanup(n int) {
+	log.Printf("cleaning up %d", n)
+}
+
+func Handler(n int) {
+	defer func() {
+		if r := recover(); r != nil {
+			panic("handled")
+		}
+	}()
+	if n > 5 {
+		panic("too big")
+	}
+}
+
+func check(n int) {
+	if n > 7 {
+		panic("checked")
+	}
+}
+
+func Conditional(n int, strict bool) {
+	if strict {
+		defer check(n)
+	}
+	log.Printf("done")
+}
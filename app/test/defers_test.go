@@ -0,0 +1,168 @@
+package test
+
+import (
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"sourcecrawler/app/cfg"
+	"sourcecrawler/app/helper"
+	"strings"
+	"testing"
+)
+
+//Block with a node on the line
+func blockAt(t *testing.T, w cfg.Wrapper, fset *token.FileSet, line int) *cfg.BlockWrapper {
+	for _, b := range loopBlocks(w, map[cfg.Wrapper]bool{}, map[cfg.Wrapper]bool{}, t) {
+		for _, node := range b.Block.Nodes {
+			if fset.Position(node.Pos()).Line == line {
+				return b
+			}
+		}
+	}
+	t.Fatalf("no block for line %d", line)
+	return nil
+}
+
+//Whether a call to the named function comes after w
+func callsAfter(w cfg.Wrapper, name string, seen map[cfg.Wrapper]bool) bool {
+	if seen[w] {
+		return false
+	}
+	seen[w] = true
+	for _, child := range w.GetChildren() {
+		if fn, ok := child.(*cfg.FnWrapper); ok {
+			if decl, ok := fn.Fn.(*ast.FuncDecl); ok && decl.Name.Name == name {
+				return true
+			}
+		}
+		if callsAfter(child, name, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDeferSplicing(t *testing.T) {
+	w, fset := fixture(t, "defers/defers.go", "Close")
+
+	//the deferred call runs on the way out of both exits
+	cases := []struct {
+		name string
+		line int
+	}{
+		{"panic", 9},
+		{"return", 11},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			exit := blockAt(t, w, fset, test.line)
+			if !callsAfter(exit, "cleanup", map[cfg.Wrapper]bool{}) {
+				t.Errorf("cleanup isn't called after the %s on line %d", test.name, test.line)
+			}
+			for _, leaf := range cfg.GetLeafNodes(w) {
+				if leaf == exit {
+					t.Errorf("the %s on line %d is still an exit of the function", test.name, test.line)
+				}
+			}
+		})
+	}
+
+	//and not where it is deferred
+	for _, child := range blockAt(t, w, fset, 7).GetChildren() {
+		if _, ok := child.(*cfg.FnWrapper); ok {
+			t.Error("the deferred call is expanded where it is deferred")
+		}
+	}
+}
+
+func TestRecoveredPanic(t *testing.T) {
+	path, err := filepath.Abs("defers/defers.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []string{
+		"",
+		"goroutine 1 [running]:",
+		"sourcecrawler/app/test/defers.Handler.func1()",
+		"\t" + path + ":21 +0x65",
+		"panic({0x4a1e60, 0x4e8f20})",
+		"\t/usr/local/go/src/runtime/panic.go:884 +0x213",
+		"sourcecrawler/app/test/defers.Handler(0x6)",
+		"\t" + path + ":25 +0x45",
+		"",
+	}
+
+	cases := []struct {
+		name   string
+		header []string
+	}{
+		{"recovered", []string{"panic: too big [recovered]", "\tpanic: handled"}},
+		{"repanicked", []string{"panic: too big [recovered, repanicked]"}},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			stack := helper.ParsePanic("defers", strings.Join(append(test.header, frames...), "\n"))
+			if !stack.Recovered {
+				t.Error("the panic isn't recognised as recovered")
+			}
+			if len(stack.LineNum) != 2 || stack.LineNum[0] != "21" || stack.FuncName[0] != "Handler" {
+				t.Fatalf("expected the handler on line 21 on top of the stack, found %v %v", stack.FuncName, stack.LineNum)
+			}
+
+			//the slice goes from the handler back through the panic it recovered
+			w, fset := fixture(t, "defers/defers.go", "Handler")
+			exceptionBlock := cfg.FindPanicWrapper(w, &stack)
+			if exceptionBlock == nil {
+				t.Fatal("no block for the recovering handler")
+			}
+			paths := cfg.CreateNewPath()
+			paths.Recovered = cfg.FindRecoveredWrapper(w, &stack)
+			if paths.Recovered == nil {
+				t.Fatal("no block for the recovered panic")
+			}
+			cfg.ConvertCFGtoSSAForm(w)
+			paths.TraverseCFG(exceptionBlock, w)
+
+			if len(paths.Paths) == 0 {
+				t.Fatal("no path through the recovered panic")
+			}
+			for _, path := range paths.Paths {
+				found := false
+				for _, expr := range path.Expressions {
+					var bf strings.Builder
+					printer.Fprint(&bf, fset, expr)
+					found = found || bf.String() == "Handler.n > 5"
+				}
+				if !found {
+					t.Errorf("a path doesn't go through the recovered panic: %v", path.Expressions)
+				}
+			}
+		})
+	}
+}
+
+func TestConditionalDefer(t *testing.T) {
+	w, fset := fixture(t, "defers/defers.go", "Conditional")
+	exceptionBlock := blockAt(t, w, fset, 31)
+	cfg.ConvertCFGtoSSAForm(w)
+	paths := cfg.CreateNewPath()
+	paths.TraverseCFG(exceptionBlock, w)
+
+	//the call only runs on the way out when it was deferred
+	if len(paths.Paths) == 0 {
+		t.Fatal("no path to the panic of the deferred call")
+	}
+	for _, path := range paths.Paths {
+		conditions := make([]string, 0)
+		for _, expr := range path.Expressions {
+			var bf strings.Builder
+			printer.Fprint(&bf, fset, expr)
+			conditions = append(conditions, bf.String())
+		}
+		if !strings.Contains(strings.Join(conditions, " | "), "Conditional.strict") || strings.Contains(strings.Join(conditions, " | "), "!Conditional.strict") {
+			t.Errorf("expected the path to go through the defer statement, found %v", conditions)
+		}
+	}
+}
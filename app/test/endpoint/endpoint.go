@@ -0,0 +1,50 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type Order struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"qty"`
+	Express  bool   `json:"express"`
+}
+
+func Routes(r *mux.Router) {
+	r.HandleFunc("/stores/{store}/orders", CreateOrder).Methods("POST")
+	r.HandleFunc("/stores/{store}", GetStore)
+}
+
+func CreateOrder(w http.ResponseWriter, r *http.Request) {
+	var order Order
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	store, err := strconv.Atoi(mux.Vars(r)["store"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	reserve(store, order.Quantity, order.Express)
+}
+
+func reserve(store, qty int, express bool) {
+	if store > 0 && express && qty > 10*store {
+		panic("not enough stock")
+	}
+}
+
+func GetStore(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		return
+	}
+	if limit > 100 {
+		panic("too many")
+	}
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"go/ast"
+	"sourcecrawler/app/cfg"
+	"testing"
+
+	"github.com/mitchellh/go-z3"
+)
+
+func TestEndpoint(t *testing.T) {
+	file, fset := parseFixture(t, "endpoint/endpoint.go")
+
+	cases := []struct {
+		fn       string
+		line     int
+		endpoint cfg.Endpoint
+		curl     string //expected for one of the paths
+	}{
+		//the body is decoded into a struct, the store is a URL variable
+		{"CreateOrder", 38, cfg.Endpoint{Method: "POST", Path: "/stores/{store}/orders"},
+			`curl -X POST 'http://localhost:3000/stores/1/orders' -H 'Content-Type: application/json' -d '{"express":true,"qty":11}'`},
+		//a query value, the URL variable isn't used
+		{"GetStore", 48, cfg.Endpoint{Path: "/stores/{store}"},
+			`curl -X GET 'http://localhost:3000/stores/0?limit=101'`},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			w := wrapFixture(t, file, fset, test.fn)
+			if !cfg.IsHandler(w.Fn.(*ast.FuncDecl).Type) {
+				t.Fatalf("expected %s to be a handler", test.fn)
+			}
+			endpoint := cfg.FindEndpoint([]*ast.File{file}, test.fn)
+			if endpoint == nil || *endpoint != test.endpoint {
+				t.Fatalf("expected the endpoint %v, found %v", test.endpoint, endpoint)
+			}
+
+			cfg.ConvertCFGtoSSAForm(w)
+			block := blockAt(t, w, fset, test.line)
+			paths := cfg.CreateNewPath()
+			paths.TraverseCFG(block, w)
+
+			config := z3.NewConfig()
+			ctx := z3.NewContext(config)
+			config.Close()
+			defer ctx.Close()
+			curls := make([]string, 0)
+			for _, path := range paths.Paths {
+				s := ctx.NewSolver()
+				for _, expr := range path.Expressions {
+					if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+						s.Assert(condition)
+					}
+				}
+				if s.Check() == z3.True {
+					m := s.Model()
+					assignments := m.Assignments()
+					values := cfg.RequestValues(block, path.Expressions, assignments)
+					curls = append(curls, cfg.BuildRequest(endpoint, values, assignments).Curl("http://localhost:3000"))
+					m.Close()
+				}
+				s.Close()
+			}
+			for _, curl := range curls {
+				if curl == test.curl {
+					return
+				}
+			}
+			t.Errorf("expected %s in %v", test.curl, curls)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sourcecrawler/app/cfg"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//Parses a fixture file
+func parseFixture(t *testing.T, path string) (*ast.File, *token.FileSet) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, fset
+}
+
+//Wraps and expands a function of the parsed fixture, the options set the
+//wrapper up before it is expanded
+func wrapFixture(t *testing.T, file *ast.File, fset *token.FileSet, name string, options ...func(*cfg.FnWrapper)) *cfg.FnWrapper {
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == name {
+			w := cfg.NewFnWrapper(fn, make([]ast.Expr, 0))
+			w.Fset = fset
+			w.ASTs = []*ast.File{file}
+			for _, option := range options {
+				option(w)
+			}
+			cfg.ExpandCFG(w)
+			return w
+		}
+	}
+	t.Fatalf("no function %s in the fixture", name)
+	return nil
+}
+
+//Wraps and expands a function of the fixture file
+func fixture(t *testing.T, path string, name string, options ...func(*cfg.FnWrapper)) (*cfg.FnWrapper, *token.FileSet) {
+	file, fset := parseFixture(t, path)
+	return wrapFixture(t, file, fset, name, options...), fset
+}
+
+//Only path to a panic of a fixture, with its constraints solved
+type solvedPath struct {
+	w          *cfg.FnWrapper
+	block      *cfg.BlockWrapper //of the panic
+	path       cfg.Path
+	conditions []string  //the expressions of the path, printed
+	model      *z3.Model //nil when the path is unsolvable
+}
+
+//Wraps a function of the fixture in ssa form and solves the one path to
+//the block of the line, done releases the solver
+func solveFixture(t *testing.T, path string, name string, line int) (*solvedPath, func()) {
+	w, fset := fixture(t, path, name)
+	cfg.ConvertCFGtoSSAForm(w)
+	block := blockAt(t, w, fset, line)
+	paths := cfg.CreateNewPath()
+	paths.TraverseCFG(block, w)
+	if len(paths.Paths) != 1 {
+		t.Fatalf("expected one path to line %d, found %d", line, len(paths.Paths))
+	}
+
+	solved := &solvedPath{w: w, block: block, path: paths.Paths[0], conditions: make([]string, 0)}
+	config := z3.NewConfig()
+	ctx := z3.NewContext(config)
+	config.Close()
+	s := ctx.NewSolver()
+	for _, expr := range solved.path.Expressions {
+		var bf strings.Builder
+		printer.Fprint(&bf, fset, expr)
+		solved.conditions = append(solved.conditions, bf.String())
+		if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+			s.Assert(condition)
+		}
+	}
+	if s.Check() == z3.True {
+		solved.model = s.Model()
+	}
+	return solved, func() {
+		if solved.model != nil {
+			solved.model.Close()
+		}
+		s.Close()
+		ctx.Close()
+	}
+}
+
+//Inputs of the model the way they are shown, the computed values are
+//left out of them when filtered
+func (p *solvedPath) inputs(filtered bool) map[string]bool {
+	assignments := p.model.Assignments()
+	if filtered {
+		cfg.FilterToUserInput(p.w, p.path.Expressions, assignments)
+	}
+	inputs := make(map[string]bool)
+	for name, value := range assignments {
+		inputs[cfg.DescribeAssignment(name, value)] = true
+	}
+	return inputs
+}
@@ -1,7 +1,6 @@
 package test
 
 import (
-	"bufio"
 	"fmt"
 	_ "fmt"
 	"go/ast"
@@ -11,6 +10,8 @@ import (
 	"os"
 	"sourcecrawler/app/cfg"
 	"sourcecrawler/app/helper"
+	"sourcecrawler/app/model"
+	"strings"
 	"testing"
 )
 
@@ -18,7 +19,6 @@ import (
 func testLabel(t *testing.T, fileName string) {
 
 	projectRoot := "../../../../sourcecrawler"
-	traceFile := "../../../stackTrace.log"
 
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, fileName, nil, parser.ParseComments)
@@ -44,19 +44,6 @@ func testLabel(t *testing.T, fileName string) {
 		cfg.ExpandCFG(w, /*make([]*cfg.FnWrapper, 0)*/)
 	}
 
-	//Sample stack trace
-	stkTrcFile, err := os.Open(traceFile)
-	if err != nil {
-		fmt.Println("Bad stack trace file")
-	}
-	scanner := bufio.NewScanner(stkTrcFile)
-	messageString := ""
-	for scanner.Scan() {
-		messageString += scanner.Text() + "\n"
-	}
-	// fmt.Println("Message", messageString)
-	stackInfo := helper.ParsePanic(projectRoot, messageString)
-
 	//Sample logs
 	logTypes := helper.ParseProject(projectRoot)
 
@@ -65,8 +52,8 @@ func testLabel(t *testing.T, fileName string) {
 	leaves := cfg.GetLeafNodes(w)
 	for _, leaf := range leaves {
 		fmt.Println("Leaf is", leaf)
-		paths.LabelCFG(leaf, logTypes, w, stackInfo) //Label each block with executionLabel (TraverseCFG can be updated to map each stmt to a label)
-		paths.TraverseCFG(leaf, w)                   //Gather expressions for paths
+		paths.LabelCFG(leaf, logTypes) //Label each block with executionLabel (TraverseCFG can be updated to map each stmt to a label)
+		paths.TraverseCFG(leaf, w)     //Gather expressions for paths
 	}
 
 	//Test print labels
@@ -119,3 +106,96 @@ func PrintLabels(curr cfg.Wrapper) {
 		PrintLabels(child)
 	}
 }
+
+func TestDominanceLabels(t *testing.T) {
+	w, fset := fixture(t, "labels/labels.go", "Check")
+	exceptionBlock := blockAt(t, w, fset, 14)
+
+	logs := []model.LogType{{Regex: "big"}}
+	lines := []int{6, 7, 9, 11, 12, 14}
+
+	//blocks run if they dominate the exception or logged what was seen,
+	//missing logs only count when the logs are complete and their level on
+	cases := []struct {
+		name     string
+		complete bool
+		disabled []string
+		labels   []cfg.ExecutionLabel
+	}{
+		{"complete logs", true, nil,
+			[]cfg.ExecutionLabel{cfg.Must, cfg.Must, cfg.MustNot, cfg.Must, cfg.MustNot, cfg.Must}},
+		{"partial logs", false, nil,
+			[]cfg.ExecutionLabel{cfg.Must, cfg.Must, cfg.May, cfg.Must, cfg.May, cfg.Must}},
+		{"debug disabled", true, []string{"Debug"},
+			[]cfg.ExecutionLabel{cfg.Must, cfg.Must, cfg.MustNot, cfg.Must, cfg.May, cfg.Must}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			paths := cfg.CreateNewPath()
+			paths.CompleteLogs = test.complete
+			paths.DisabledLevels = test.disabled
+			paths.LabelCFG(exceptionBlock, logs)
+			for i, line := range lines {
+				if label := blockAt(t, w, fset, line).GetLabel(); label != test.labels[i] {
+					t.Errorf("expected line %d to be %v, found %v", line, test.labels[i], label)
+				}
+			}
+		})
+	}
+
+	//a log is seen by where it is even when its message doesn't match
+	paths := cfg.CreateNewPath()
+	paths.CompleteLogs = true
+	paths.LabelCFG(exceptionBlock, []model.LogType{{FilePath: "labels/labels.go", LineNumber: 9, Regex: "tiny"}})
+	if label := blockAt(t, w, fset, 9).GetLabel(); label != cfg.Must {
+		t.Errorf("expected the log seen on line 9 to be Must, found %v", label)
+	}
+	if label := blockAt(t, w, fset, 7).GetLabel(); label != cfg.MustNot {
+		t.Errorf("expected the log missing on line 7 to be MustNot, found %v", label)
+	}
+
+	//calls that merely mention a logger's name aren't logs missing
+	stock, stockFset := fixture(t, "labels/labels.go", "Stock")
+	paths = cfg.CreateNewPath()
+	paths.CompleteLogs = true
+	paths.LabelCFG(blockAt(t, stock, stockFset, 33), []model.LogType{})
+	if label := blockAt(t, stock, stockFset, 31).GetLabel(); label != cfg.May {
+		t.Errorf("expected the call on line 31 to be May, found %v", label)
+	}
+
+	//a log written through a wrapper is seen at the call of the wrapper
+	count, countFset := fixture(t, "labels/labels.go", "Count")
+	for _, complete := range []bool{false, true} {
+		paths = cfg.CreateNewPath()
+		paths.CompleteLogs = complete
+		paths.LabelCFG(blockAt(t, count, countFset, 44), []model.LogType{{FilePath: "labels/labels.go", LineNumber: 42, Regex: "too many"}})
+		for _, line := range []int{37, 42} {
+			if label := blockAt(t, count, countFset, line).GetLabel(); label != cfg.Must {
+				t.Errorf("expected the wrapped log on line %d to be Must with complete logs %v, found %v", line, complete, label)
+			}
+		}
+	}
+
+	paths = cfg.CreateNewPath()
+	paths.CompleteLogs = true
+	paths.LabelCFG(exceptionBlock, logs)
+
+	//conditions hold as the branch the path goes through ran
+	cfg.ConvertCFGtoSSAForm(w)
+	paths.TraverseCFG(exceptionBlock, w)
+	executed := make([]string, 0)
+	for _, path := range paths.Paths {
+		if path.DidExecute != cfg.Must {
+			continue
+		}
+		for _, expr := range path.Expressions {
+			var bf strings.Builder
+			printer.Fprint(&bf, fset, expr)
+			executed = append(executed, bf.String())
+		}
+	}
+	expected := []string{"!(Check.n%2 == 0)", "Check.n > 10"}
+	if strings.Join(executed, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected one path that ran with %v, found %v", expected, executed)
+	}
+}
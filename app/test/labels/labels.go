@@ -0,0 +1,45 @@
+package labels
+
+import "log"
+
+func Check(n int) {
+	if n > 10 {
+		log.Printf("big")
+	} else {
+		log.Printf("small")
+	}
+	if n%2 == 0 {
+		logger.Debugf("even")
+	}
+	panic("checked")
+}
+
+type leveled struct{}
+
+func (leveled) Debugf(format string, args ...interface{}) {}
+
+var logger leveled
+
+type shelf struct{}
+
+func (shelf) Add(n int) {}
+
+var catalog shelf
+
+func Stock(n int) {
+	if n > 3 {
+		catalog.Add(n)
+	}
+	panic("stocked")
+}
+
+func logErr(msg string) {
+	log.Print(msg)
+}
+
+func Count(n int) {
+	if n > 3 {
+		logErr("too many")
+	}
+	panic("counted")
+}
@@ -0,0 +1,46 @@
+package library
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+func Parse(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	if n == 0 {
+		panic("zero")
+	}
+	return n
+}
+
+func Pad(s string, width int) string {
+	return strings.Repeat(" ", width-len(s)) + s
+}
+
+func Count(n uint) int {
+	c := bits.OnesCount(n)
+	if c > 10 {
+		panic("too many")
+	}
+	return c
+}
+
+func Base(s string, base int) int64 {
+	n, err := strconv.ParseInt(s, base, 64)
+	if err == nil && base > 30 {
+		panic("parsed")
+	}
+	return n
+}
+
+func Find(s string, sub string) int {
+	i := strings.Index(s, sub)
+	if i >= 0 && len(sub) > 2 {
+		panic("found")
+	}
+	return i
+}
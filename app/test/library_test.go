@@ -0,0 +1,125 @@
+package test
+
+import (
+	"go/printer"
+	"sourcecrawler/app/cfg"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//Conditions of the paths to the line of a function of the library
+//fixture, and whether they are solvable
+func libraryPaths(t *testing.T, name string, line int) ([]string, *z3.Model, func()) {
+	w, fset := fixture(t, "library/library.go", name)
+	exceptionBlock := blockAt(t, w, fset, line)
+	cfg.ConvertCFGtoSSAForm(w)
+	paths := cfg.CreateNewPath()
+	paths.TraverseCFG(exceptionBlock, w)
+	if len(paths.Paths) != 1 {
+		t.Fatalf("expected one path to line %d, found %d", line, len(paths.Paths))
+	}
+
+	config := z3.NewConfig()
+	ctx := z3.NewContext(config)
+	config.Close()
+	s := ctx.NewSolver()
+	conditions := make([]string, 0)
+	for _, expr := range paths.Paths[0].Expressions {
+		var bf strings.Builder
+		printer.Fprint(&bf, fset, expr)
+		//the summaries' conditions have no positions of their own
+		conditions = append(conditions, strings.Join(strings.Fields(bf.String()), " "))
+		if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+			s.Assert(condition)
+		}
+	}
+	if s.Check() != z3.True {
+		t.Fatalf("the path %v is unsolvable", conditions)
+	}
+	m := s.Model()
+	return conditions, m, func() {
+		m.Close()
+		s.Close()
+		ctx.Close()
+	}
+}
+
+func TestLibrarySummaries(t *testing.T) {
+	t.Run("returns", func(t *testing.T) {
+		conditions, m, done := libraryPaths(t, "Parse", 15)
+		defer done()
+		//the results are the versions the call assigns
+		expected := "1Parse.err == nil || 1Parse.n == 0"
+		found := false
+		for _, condition := range conditions {
+			found = found || condition == expected
+		}
+		if !found {
+			t.Errorf("expected %s in %v", expected, conditions)
+		}
+		//a zero parsed without an error comes from a non empty string
+		if v := m.Assignments()["len(Parse.s)"]; v == nil || v.Int() == 0 {
+			t.Errorf("expected a non empty string, found %v", v)
+		}
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		conditions, m, done := libraryPaths(t, "Pad", 21)
+		defer done()
+		width, length := m.Assignments()["Pad.width"], m.Assignments()["len(Pad.s)"]
+		if width == nil || length == nil || width.Int() >= length.Int() {
+			t.Errorf("expected the width to be shorter than the string in %v, found %v and %v", conditions, width, length)
+		}
+	})
+
+	t.Run("invalid base", func(t *testing.T) {
+		conditions, m, done := libraryPaths(t, "Base", 35)
+		defer done()
+		//a base past 36 returns an error
+		if base := m.Assignments()["Base.base"]; base == nil || base.Int() > 36 {
+			t.Errorf("expected a valid base in %v, found %v", conditions, base)
+		}
+	})
+
+	t.Run("index", func(t *testing.T) {
+		conditions, m, done := libraryPaths(t, "Find", 43)
+		defer done()
+		//the substring found fits in the string after its index
+		i, s, sub := m.Assignments()["1Find.i"], m.Assignments()["len(Find.s)"], m.Assignments()["len(Find.sub)"]
+		if i == nil || s == nil || sub == nil || i.Int()+sub.Int() > s.Int() {
+			t.Errorf("expected the substring to fit in the string in %v, found %v, %v and %v", conditions, i, s, sub)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		conditions, _, done := libraryPaths(t, "Count", 27)
+		defer done()
+		for _, condition := range conditions {
+			if strings.Contains(condition, "<= Count.n") {
+				t.Errorf("unexpected constraint of an unknown function %s", condition)
+			}
+		}
+	})
+}
+
+func TestLoadLibrary(t *testing.T) {
+	if _, err := cfg.LoadLibrary("library/invalid.yaml"); err == nil {
+		t.Error("expected an error for a constraint that isn't an expression")
+	}
+
+	library, err := cfg.LoadLibrary("library/summaries.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := cfg.Summaries
+	cfg.Summaries = library
+	defer func() { cfg.Summaries = defaults }()
+
+	conditions, m, done := libraryPaths(t, "Count", 27)
+	defer done()
+	if n := m.Assignments()["Count.n"]; n == nil || n.Int() < 11 {
+		t.Errorf("expected the argument to be at least 11 in %v, found %v", conditions, n)
+	}
+}
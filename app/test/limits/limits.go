@@ -0,0 +1,16 @@
+package limits
+
+func Branches(a, b, c int) {
+	if a > 0 {
+		a++
+	}
+	if b > 0 {
+		b = a + b
+	}
+	if c > 0 {
+		c = b + c
+	}
+	if a+b+c > 100 {
+		panic("too big")
+	}
+}
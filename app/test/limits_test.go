@@ -0,0 +1,66 @@
+package test
+
+import (
+	"sourcecrawler/app/cfg"
+	"testing"
+	"time"
+)
+
+func TestPathLimits(t *testing.T) {
+	file, fset := parseFixture(t, "limits/limits.go")
+
+	cases := []struct {
+		name      string
+		maxPaths  int
+		maxDepth  int
+		timeout   time.Duration
+		paths     int
+		truncated bool
+	}{
+		{"no limits", 0, 0, 0, 8, false},
+		//the shortest paths are kept
+		{"max paths", 3, 0, 0, 3, true},
+		//every path goes through more blocks
+		{"max depth", 0, 3, 0, 0, true},
+		{"timeout", 0, 0, time.Nanosecond, 0, true},
+	}
+
+	//lengths of every path, from the shortest
+	var lengths []int
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			w := wrapFixture(t, file, fset, "Branches")
+			cfg.ConvertCFGtoSSAForm(w)
+			paths := cfg.CreateNewPath()
+			paths.MaxPaths, paths.MaxDepth, paths.Timeout = test.maxPaths, test.maxDepth, test.timeout
+			block := blockAt(t, w, fset, 14)
+			if test.timeout > 0 {
+				time.Sleep(test.timeout)
+			}
+			paths.TraverseCFG(block, w)
+			//the paths found again aren't added twice
+			paths.TraverseCFG(block, w)
+
+			if len(paths.Paths) != test.paths || paths.Truncated != test.truncated {
+				t.Fatalf("expected %d paths, truncated %v, found %d, %v", test.paths, test.truncated, len(paths.Paths), paths.Truncated)
+			}
+			for i, path := range paths.Paths {
+				if i > 0 && len(path.Expressions) < len(paths.Paths[i-1].Expressions) {
+					t.Errorf("expected the shortest paths first, found %d after %d", len(path.Expressions), len(paths.Paths[i-1].Expressions))
+				}
+				if lengths == nil {
+					continue
+				}
+				if len(path.Expressions) != lengths[i] {
+					t.Errorf("expected the path %d to have %d nodes, found %d", i, lengths[i], len(path.Expressions))
+				}
+			}
+			if lengths == nil {
+				lengths = make([]int, 0)
+				for _, path := range paths.Paths {
+					lengths = append(lengths, len(path.Expressions))
+				}
+			}
+		})
+	}
+}
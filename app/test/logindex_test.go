@@ -0,0 +1,170 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sourcecrawler/app/handler"
+	"sourcecrawler/app/helper"
+	"sourcecrawler/app/model"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.DBMigrate(db)
+}
+
+func postJSON(t *testing.T, db *gorm.DB, fn func(*gorm.DB, http.ResponseWriter, *http.Request), body interface{}) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	fn(db, w, httptest.NewRequest("POST", "/", bytes.NewReader(data)))
+	return w
+}
+
+func TestIndexProject(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	//indexing twice keeps a single project and revision
+	for i := 0; i < 2; i++ {
+		w := postJSON(t, db, handler.IndexProject, model.ParseProjectRequest{ProjectRoot: "logwrappers"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("index failed: %d %s", w.Code, w.Body.String())
+		}
+		resp := model.ParseProjectResponse{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.LogTypes != 5 {
+			t.Errorf("expected 5 log types, found %d", resp.LogTypes)
+		}
+	}
+
+	projects, revisions, logTypes := 0, 0, 0
+	db.Model(&model.Project{}).Count(&projects)
+	db.Model(&model.ProjectRevision{}).Count(&revisions)
+	db.Model(&model.LogType{}).Count(&logTypes)
+	if projects != 1 || revisions != 1 || logTypes != 5 {
+		t.Errorf("expected 1 project, 1 revision and 5 log types, found %d, %d, %d", projects, revisions, logTypes)
+	}
+
+	w := postJSON(t, db, handler.LogSource, model.LogSourceRequest{LogMessage: "user bob logged in"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("lookup failed: %d %s", w.Code, w.Body.String())
+	}
+	source := model.LogSourceResponse{}
+	json.Unmarshal(w.Body.Bytes(), &source)
+	if filepath.Base(source.FilePath) != "logwrappers.go" || source.LineNumber != 32 {
+		t.Errorf("expected logwrappers.go:32, found %s:%d", source.FilePath, source.LineNumber)
+	}
+	if source.FunctionName != "callers" || len(source.Arguments) != 1 || source.Arguments[0] != "bob" {
+		t.Errorf("expected callers with argument bob, found %s %v", source.FunctionName, source.Arguments)
+	}
+
+	w = postJSON(t, db, handler.LogSource, model.LogSourceRequest{LogMessage: "not a log of the project"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected not found, got %d", w.Code)
+	}
+}
+
+type logSourceCase struct {
+	Message   string
+	Function  string
+	Arguments []string
+	Found     bool
+}
+
+func TestLogSourceBatch(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	cases := []logSourceCase{
+		{"processed 42 items", "wrapped", []string{"42"}, true},
+		{`{"level":"info","message":"consumer: orders"}`, "concatenation", []string{"orders"}, true},
+		{"2020/01/02 10:11:12 count is high", "appended", []string{}, true},
+		{"busy", "branches", []string{}, true},
+		{"nothing like this is logged", "", []string{}, false},
+	}
+
+	request := model.LogSourceBatchRequest{ProjectRoot: "logtemplates"}
+	for _, c := range cases {
+		request.LogMessages = append(request.LogMessages, c.Message)
+	}
+	w := postJSON(t, db, handler.LogSourceBatch, request)
+	if w.Code != http.StatusOK {
+		t.Fatalf("lookup failed: %d %s", w.Code, w.Body.String())
+	}
+
+	responses := []model.LogSourceResponse{}
+	json.Unmarshal(w.Body.Bytes(), &responses)
+	if len(responses) != len(cases) {
+		t.Fatalf("expected %d responses, found %d", len(cases), len(responses))
+	}
+	for i, c := range cases {
+		resp := responses[i]
+		if resp.LogMessage != c.Message {
+			t.Errorf("responses out of order, expected %q found %q", c.Message, resp.LogMessage)
+		}
+		if (resp.Error == "") != c.Found {
+			t.Errorf("%q: expected found=%v, error %q", c.Message, c.Found, resp.Error)
+			continue
+		}
+		if resp.FunctionName != c.Function {
+			t.Errorf("%q: expected function %q, found %q", c.Message, c.Function, resp.FunctionName)
+		}
+		if len(resp.Arguments) != len(c.Arguments) {
+			t.Errorf("%q: expected arguments %v, found %v", c.Message, c.Arguments, resp.Arguments)
+			continue
+		}
+		for j := range c.Arguments {
+			if resp.Arguments[j] != c.Arguments[j] {
+				t.Errorf("%q: expected arguments %v, found %v", c.Message, c.Arguments, resp.Arguments)
+			}
+		}
+	}
+}
+
+func TestRecognizerChange(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	defer func(recognizer helper.LogRecognizer) { helper.Recognizer = recognizer }(helper.Recognizer)
+
+	cases := []struct {
+		name     string
+		methods  []string
+		logTypes int
+	}{
+		{"default methods", helper.Recognizer.Methods, 5},
+		{"without Msgf", []string{"Msg"}, 4},
+		{"default again", helper.Recognizer.Methods, 5},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			helper.Recognizer = helper.LogRecognizer{Loggers: []string{"log"}, Methods: test.methods}
+			w := postJSON(t, db, handler.IndexProject, model.ParseProjectRequest{ProjectRoot: "logwrappers"})
+			if w.Code != http.StatusOK {
+				t.Fatalf("index failed: %d %s", w.Code, w.Body.String())
+			}
+			resp := model.ParseProjectResponse{}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.LogTypes != test.logTypes {
+				t.Errorf("expected %d log types, found %d", test.logTypes, resp.LogTypes)
+			}
+		})
+	}
+
+	revisions := 0
+	db.Model(&model.ProjectRevision{}).Count(&revisions)
+	if revisions != 1 {
+		t.Errorf("expected 1 revision, found %d", revisions)
+	}
+}
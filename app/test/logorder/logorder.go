@@ -0,0 +1,52 @@
+package logorder
+
+import "log"
+
+func Connect(y int) {
+	if y > 100 {
+		log.Printf("y is large")
+	}
+	log.Printf("connected")
+	if y > 1000 {
+		log.Printf("y is huge")
+	}
+	panic("connection lost")
+}
+
+func Retry(n int) {
+	for i := 0; i < n; i++ {
+		log.Printf("retrying")
+	}
+	panic("gave up")
+}
+
+func Lookup(s []int, i int) {
+	log.Printf("start")
+	_ = s[i]
+	log.Printf("done")
+}
+
+func warn(msg string) {
+	log.Print(msg)
+}
+
+func relay(text string) {
+	warn(text)
+}
+
+func Drain(n int) {
+	if n > 3 {
+		relay("draining")
+	}
+	log.Printf("drained")
+	panic("stuck")
+}
+
+func Poll(n int) {
+	if n > 5 {
+		log.Printf("retrying %d", n)
+	} else {
+		log.Printf("retrying")
+	}
+	panic("polled")
+}
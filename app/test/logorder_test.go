@@ -0,0 +1,137 @@
+package test
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/types"
+	"net/http"
+	"path/filepath"
+	"sourcecrawler/app/handler"
+	"sourcecrawler/app/cfg"
+	"sourcecrawler/app/model"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//Messages of the logs of every path that is consistent with the logs
+//observed, a path without logs is empty. A message observed at a line
+//(message@line) is the log type there. At most max paths are kept, 0 is
+//no limit
+func alignedPaths(t *testing.T, name string, line int, complete bool, observed []string, max int) []string {
+	w, fset := fixture(t, "logorder/logorder.go", name)
+	exceptionBlock := blockAt(t, w, fset, line)
+	cfg.ConvertCFGtoSSAForm(w)
+
+	logs := make([]model.LogType, 0, len(observed))
+	for _, message := range observed {
+		logType := model.LogType{Regex: message}
+		if at := strings.LastIndex(message, "@"); at != -1 {
+			logType.Regex = message[:at]
+			logType.FilePath = "logorder/logorder.go"
+			logType.LineNumber, _ = strconv.Atoi(message[at+1:])
+		}
+		logs = append(logs, logType)
+	}
+	paths := cfg.CreateNewPath()
+	paths.CompleteLogs = complete
+	paths.ExceptionLine = line
+	paths.MaxPaths = max
+	paths.Observed = logs
+	paths.TraverseCFG(exceptionBlock, w)
+
+	consistent := make([]string, 0)
+	for _, path := range paths.Paths {
+		if !path.Alignment.Consistent {
+			if path.DidExecute != cfg.MustNot {
+				t.Errorf("expected a path with inconsistent logs to be MustNot, found %v", path.DidExecute)
+			}
+			continue
+		}
+		messages := make([]string, 0, len(path.Logs))
+		for _, event := range path.Logs {
+			call := event.Stmt.(*ast.ExprStmt).X.(*ast.CallExpr)
+			messages = append(messages, strings.Trim(types.ExprString(call.Args[0]), `"`))
+		}
+		consistent = append(consistent, strings.Join(messages, ", "))
+	}
+	sort.Strings(consistent)
+	return consistent
+}
+
+func TestLogOrder(t *testing.T) {
+	cases := []struct {
+		name     string
+		fn       string
+		line     int
+		complete bool
+		observed []string
+		max      int
+		expected []string
+	}{
+		{"in order", "Connect", 13, false, []string{"y is large", "connected"}, 0,
+			[]string{"connected", "connected, y is huge", "y is large, connected", "y is large, connected, y is huge"}},
+		{"out of order", "Connect", 13, false, []string{"connected", "y is large"}, 0,
+			[]string{"connected", "connected, y is huge"}},
+		{"complete logs", "Connect", 13, true, []string{"y is large", "connected"}, 0,
+			[]string{"y is large, connected"}},
+		{"limited paths", "Connect", 13, true, []string{"y is large", "connected"}, 1,
+			[]string{"y is large, connected"}},
+		{"partial repeats", "Retry", 20, false, []string{"retrying", "retrying"}, 0,
+			[]string{"", "retrying", "retrying, retrying"}},
+		{"complete repeats", "Retry", 20, true, []string{"retrying", "retrying"}, 0,
+			[]string{"retrying, retrying"}},
+		{"after the exception", "Lookup", 25, true, []string{"start"}, 0,
+			[]string{"start"}},
+		//the log type of a wrapper is at the call the message comes from
+		{"through a wrapper", "Drain", 42, true, []string{"draining@39", "drained"}, 0,
+			[]string{"msg, drained"}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			consistent := alignedPaths(t, test.fn, test.line, test.complete, test.observed, test.max)
+			if strings.Join(consistent, " | ") != strings.Join(test.expected, " | ") {
+				t.Errorf("expected the paths %q, found %q", test.expected, consistent)
+			}
+		})
+	}
+}
+
+//The messages are the logs of the log types they match the way their
+//source is found, the most specific one
+func TestObservedLogs(t *testing.T) {
+	path, err := filepath.Abs("logorder/logorder.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := strings.Join([]string{
+		"panic: polled",
+		"",
+		"goroutine 1 [running]:",
+		"sourcecrawler/app/test/logorder.Poll(0x7)",
+		"\t" + path + ":51 +0x45",
+		"",
+	}, "\n")
+	request := map[string]interface{}{
+		"stackTrace":   trace,
+		"logMessages":  []string{"2020/01/02 10:11:12 retrying 7"},
+		"projectRoot":  "logorder",
+		"completeLogs": true,
+	}
+	w := postJSON(t, nil, handler.SliceProgram, request)
+	if w.Code != http.StatusOK {
+		t.Fatalf("slicing failed: %d %s", w.Code, w.Body.String())
+	}
+	resp := struct {
+		Paths []struct {
+			Path []string `json:"path"`
+		} `json:"paths"`
+	}{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	//the message is the log with the number, the path writing it ran
+	if len(resp.Paths) != 1 || strings.Join(resp.Paths[0].Path, " ") != "Poll.n > 5" {
+		t.Errorf("expected the one path through the log with the number, found %v", resp.Paths)
+	}
+}
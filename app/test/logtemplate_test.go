@@ -0,0 +1,73 @@
+package test
+
+import (
+	"regexp"
+	"sourcecrawler/app/helper"
+	"testing"
+)
+
+func TestLogTemplates(t *testing.T) {
+	logTypes := helper.ParseProject("logtemplates")
+
+	regexes := make(map[string]int)
+	for _, logType := range logTypes {
+		regexes[logType.Regex] = logType.LineNumber
+	}
+
+	expected := []string{
+		"retrying request",
+		"consumer started",
+		"consumer stopped",
+		"prefix: connected to",
+		"consumer: .*",
+		"processed \\d items",
+		"queue is empty",
+		"request failed",
+		"bad id \\d",
+		"(?:busy|idle)",
+		"shadowed",
+		"final",
+		"count is high",
+	}
+	for _, regex := range expected {
+		if _, ok := regexes[regex]; !ok {
+			t.Errorf("expected a log type with regex %q", regex)
+		}
+	}
+
+	unexpected := []string{".*", "overwritten", "shadowed|idle"}
+	for _, regex := range unexpected {
+		if _, ok := regexes[regex]; ok {
+			t.Errorf("did not expect a log type with regex %q", regex)
+		}
+	}
+
+	if len(logTypes) != len(expected) {
+		t.Errorf("expected %d log types, found %d", len(expected), len(logTypes))
+	}
+}
+
+func TestCreateRegex(t *testing.T) {
+	cases := []struct {
+		literal string
+		regex   string
+		message string //matched by the regex
+	}{
+		{`"(log msg 2)"`, `\(log msg 2\)`, "(log msg 2)"},
+		{`"retry [%d/%d] in %s"`, `retry \[\d/\d\] in .*`, "retry [1/3] in 2s"},
+		{`"cost: $%d.%d + tax"`, `cost: \$\d\.\d \+ tax`, "cost: $4.5 + tax"},
+		{`"user '%v' missing?"`, `user .* missing\?`, "user bob missing?"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.literal, func(t *testing.T) {
+			regex := helper.CreateRegex(test.literal)
+			if regex != test.regex {
+				t.Fatalf("expected %q, found %q", test.regex, regex)
+			}
+			if matched, err := regexp.MatchString("^"+regex+"$", test.message); err != nil || !matched {
+				t.Errorf("expected %q to match %q, found %v %v", regex, test.message, matched, err)
+			}
+		})
+	}
+}
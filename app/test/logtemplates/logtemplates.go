@@ -0,0 +1,55 @@
+package logtemplates
+
+import (
+	"errors"
+	"fmt"
+
+	"sourcecrawler/app/test/logtemplates/messages"
+
+	"github.com/rs/zerolog/log"
+)
+
+const retries = "retrying request"
+
+func constants() {
+	log.Info().Msg(retries)
+	log.Info().Msg(messages.Started)
+	log.Info().Msg(messages.Stopped)
+}
+
+func concatenation(name string) {
+	msg := "connected to"
+	log.Info().Msg("prefix: " + msg)
+	log.Info().Msg(messages.Prefix + ": " + name)
+}
+
+func wrapped(id int) {
+	log.Info().Msg(fmt.Sprintf("processed %d items", id))
+	err := errors.New("queue is empty")
+	log.Error().Msg(err.Error())
+	log.Error().Err(fmt.Errorf("bad id %d", id)).Msg("request failed")
+}
+
+func branches(x int) {
+	status := "idle"
+	if x > 5 {
+		status = "busy"
+	}
+	log.Info().Msg(status)
+
+	//the same name in another scope must not be confused with status
+	{
+		status := "shadowed"
+		log.Info().Msg(status)
+	}
+
+	detail := "overwritten"
+	detail = "final"
+	log.Info().Msg(detail)
+}
+
+func appended(x int) {
+	text := "count"
+	text += " is high"
+	log.Info().Msg(text)
+}
@@ -0,0 +1,10 @@
+package messages
+
+//Prefix is shared by log messages of other packages
+const Prefix = "consumer"
+
+//Started is built from another constant
+const Started = Prefix + " started"
+
+//Stopped is a package variable that is never reassigned
+var Stopped = "consumer stopped"
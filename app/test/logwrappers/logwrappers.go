@@ -0,0 +1,34 @@
+package logwrappers
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+const prefix = "consumer"
+
+func logErr(msg string) {
+	log.Error().Msg(msg)
+}
+
+func logWithPrefix(msg string) {
+	log.Warn().Msg("warning: " + msg)
+}
+
+func logf(format string, args ...interface{}) {
+	log.Info().Msgf(format, args...)
+}
+
+func relay(text string) {
+	logErr(text)
+}
+
+func neverCalled(msg string) {
+	log.Info().Msg("never called: " + msg)
+}
+
+func callers(user string) {
+	logErr("disk is full")
+	logWithPrefix(prefix)
+	logf("user %s logged in", user)
+	relay("relayed message")
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"path/filepath"
+	"sourcecrawler/app/helper"
+	"testing"
+)
+
+type wrapperLogCase struct {
+	Regex string
+	Line  int
+}
+
+func TestLogWrappers(t *testing.T) {
+	logTypes := helper.ParseProject("logwrappers")
+
+	found := make(map[string]int)
+	for _, logType := range logTypes {
+		if filepath.Base(logType.FilePath) != "logwrappers.go" {
+			t.Errorf("unexpected file %s", logType.FilePath)
+		}
+		found[logType.Regex] = logType.LineNumber
+	}
+
+	//one log type per call of a wrapper, pointing at the call
+	cases := []wrapperLogCase{
+		{"disk is full", 30},
+		{"warning: consumer", 31},
+		{"user .* logged in", 32},
+		{"relayed message", 33},
+		{"never called: .*", 26},
+	}
+	for _, c := range cases {
+		line, ok := found[c.Regex]
+		if !ok {
+			t.Errorf("expected a log type with regex %q", c.Regex)
+		} else if line != c.Line {
+			t.Errorf("expected %q at line %d, found line %d", c.Regex, c.Line, line)
+		}
+	}
+
+	if len(logTypes) != len(cases) {
+		t.Errorf("expected %d log types, found %d", len(cases), len(logTypes))
+	}
+}
@@ -0,0 +1,40 @@
+package loops
+
+func Counter(n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += i
+		if total > 2 {
+			panic("too big")
+		}
+	}
+	return total
+}
+
+func Sum(xs []int) int {
+	sum := 0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum
+}
+
+func Nested(n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			count++
+		}
+	}
+	return count
+}
+
+func Retry(n int) int {
+	tries := 0
+again:
+	tries++
+	if tries < n {
+		goto again
+	}
+	return tries
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"sourcecrawler/app/cfg"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//Blocks of the wrapped function, every block is visited once
+//so this also fails (by overflowing) when the graph has a cycle
+func loopBlocks(w cfg.Wrapper, stack map[cfg.Wrapper]bool, seen map[cfg.Wrapper]bool, t *testing.T) []*cfg.BlockWrapper {
+	if stack[w] {
+		t.Fatal("the wrapped graph has a cycle")
+	}
+	if seen[w] {
+		return nil
+	}
+	seen[w] = true
+	stack[w] = true
+	defer delete(stack, w)
+
+	blocks := make([]*cfg.BlockWrapper, 0)
+	if b, ok := w.(*cfg.BlockWrapper); ok {
+		blocks = append(blocks, b)
+	}
+	for _, child := range w.GetChildren() {
+		blocks = append(blocks, loopBlocks(child, stack, seen, t)...)
+	}
+	return blocks
+}
+
+func TestLoopDetection(t *testing.T) {
+	cases := []struct {
+		fn      string
+		loops   int
+		headers []string
+	}{
+		{"Counter", 1, []string{"for.loop"}},
+		{"Sum", 1, []string{"range.loop"}},
+		{"Nested", 2, []string{"for.loop", "for.loop"}},
+		{"Retry", 1, []string{"again"}},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			w, _ := fixture(t, "loops/loops.go", test.fn)
+			headers := make([]string, 0)
+			for _, b := range loopBlocks(w, map[cfg.Wrapper]bool{}, map[cfg.Wrapper]bool{}, t) {
+				if b.HeadOf != nil {
+					headers = append(headers, b.Block.String())
+					if b.HeadOf.Header != b || len(b.HeadOf.Latches) == 0 {
+						t.Errorf("loop at %s has header %v and latches %v", b.Block, b.HeadOf.Header, b.HeadOf.Latches)
+					}
+				}
+			}
+			if len(headers) != test.loops {
+				t.Fatalf("expected %d loops, found headers %v", test.loops, headers)
+			}
+			for i := range headers {
+				if !strings.Contains(headers[i], test.headers[i]) {
+					t.Errorf("expected headers %v, found %v", test.headers, headers)
+				}
+			}
+
+			//paths to the return go around the loops a bounded number of times
+			cfg.ConvertCFGtoSSAForm(w)
+			paths := cfg.CreateNewPath()
+			paths.LoopBound = 2
+			for _, leaf := range cfg.GetLeafNodes(w) {
+				paths.TraverseCFG(leaf, w)
+			}
+			if len(paths.Paths) == 0 {
+				t.Error("no paths to the return")
+			}
+		})
+	}
+}
+
+func TestLoopUnrolling(t *testing.T) {
+	//the panic needs three iterations: total goes 0, 1, 3
+	cases := []struct {
+		bound    int
+		solvable bool
+	}{
+		{1, false},
+		{2, false},
+		{3, true},
+		{5, true},
+	}
+
+	for _, test := range cases {
+		w, fset := fixture(t, "loops/loops.go", "Counter")
+		cfg.ConvertCFGtoSSAForm(w)
+
+		var panicBlock cfg.Wrapper
+		for _, b := range loopBlocks(w, map[cfg.Wrapper]bool{}, map[cfg.Wrapper]bool{}, t) {
+			for _, node := range b.Block.Nodes {
+				if fset.Position(node.Pos()).Line == 8 {
+					panicBlock = b
+				}
+			}
+		}
+		if panicBlock == nil {
+			t.Fatal("no block for the panic")
+		}
+
+		paths := cfg.CreateNewPath()
+		paths.LoopBound = test.bound
+		paths.TraverseCFG(panicBlock, w)
+
+		config := z3.NewConfig()
+		ctx := z3.NewContext(config)
+		config.Close()
+
+		solvable := false
+		for _, path := range paths.Paths {
+			s := ctx.NewSolver()
+			for _, expr := range path.Expressions {
+				if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+					s.Assert(condition)
+				}
+			}
+			if s.Check() == z3.True {
+				solvable = true
+				m := s.Model()
+				if n := m.Assignments()["Counter.n"]; n == nil || n.Int() < 3 {
+					t.Errorf("bound %d: expected n >= 3, found %v", test.bound, n)
+				}
+				m.Close()
+			}
+			s.Close()
+		}
+		ctx.Close()
+
+		if solvable != test.solvable {
+			t.Errorf("bound %d: expected solvable %v, found %v in %d paths", test.bound, test.solvable, solvable, len(paths.Paths))
+		}
+	}
+}
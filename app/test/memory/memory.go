@@ -0,0 +1,61 @@
+package memory
+
+type Account struct {
+	Balance int
+	Owner   *Account
+}
+
+func Withdraw(a *Account, amount int) {
+	debit(a, amount)
+	if a.Balance < 0 {
+		panic("overdrawn")
+	}
+}
+
+func debit(acc *Account, amount int) {
+	acc.Balance -= amount
+}
+
+func Owner(a *Account) int {
+	if a != nil {
+		return a.Owner.Balance
+	}
+	return 0
+}
+
+func Fresh(n int) {
+	a := &Account{Balance: n}
+	if a == nil {
+		panic("unreachable")
+	}
+}
+
+func Copy(n int) {
+	var a Account
+	a.Balance = n
+	credit(&a)
+	if a.Balance > 10 {
+		panic("rich")
+	}
+}
+
+func credit(acc *Account) {
+	acc.Balance++
+}
+
+func Alias(p *Account) {
+	q := p
+	q.Balance = 5
+	if p.Balance != 5 {
+		panic("aliased")
+	}
+}
+
+func Address(n int) {
+	var a Account
+	q := &a
+	q.Balance = n
+	if a.Balance != n {
+		panic("addressed")
+	}
+}
@@ -0,0 +1,51 @@
+package test
+
+import "testing"
+
+func TestMemory(t *testing.T) {
+	cases := []struct {
+		fn        string
+		line      int
+		condition string //expected on the path
+		solvable  bool
+		inputs    []string //expected in the model
+	}{
+		//the callee writes the field of the caller's account
+		{"Withdraw", 11, "1Withdraw.a.Balance = Withdraw.a.Balance - debit.amount", true, nil},
+		//dereferencing a nil pointer panics
+		{"Owner", 21, "Owner.a == nil || Owner.a.Owner == nil", true, []string{"Owner.a != nil", "Owner.a.Owner == nil"}},
+		//addresses aren't nil
+		{"Fresh", 29, "1Fresh.a == Fresh.nil", false, nil},
+		//the field of the local variable is incremented through its address
+		{"Copy", 38, "2Copy.a.Balance = 1Copy.a.Balance + 1", true, []string{"Copy.n = 10"}},
+		//a copy of a pointer writes what the pointer points to
+		{"Alias", 50, "1Alias.p.Balance = 5", false, nil},
+		{"Address", 59, "1Address.a.Balance = Address.n", false, nil},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			solved, done := solveFixture(t, "memory/memory.go", test.fn, test.line)
+			defer done()
+			found := false
+			for _, condition := range solved.conditions {
+				found = found || condition == test.condition
+			}
+			if !found {
+				t.Errorf("expected %s in %v", test.condition, solved.conditions)
+			}
+			if solvable := solved.model != nil; solvable != test.solvable {
+				t.Fatalf("expected the path to be solvable: %v, found %v", test.solvable, solvable)
+			}
+			if !test.solvable {
+				return
+			}
+			inputs := solved.inputs(false)
+			for _, input := range test.inputs {
+				if !inputs[input] {
+					t.Errorf("expected %s in %v", input, inputs)
+				}
+			}
+		})
+	}
+}
@@ -1,15 +1,15 @@
 package test
 
 import (
-	"bufio"
 	"fmt"
 	_ "fmt"
 	"go/ast"
 	"go/parser"
+	"go/printer"
 	"go/token"
-	"os"
 	"sourcecrawler/app/cfg"
 	"sourcecrawler/app/helper"
+	"strings"
 	"testing"
 
 	"github.com/mitchellh/go-z3"
@@ -38,22 +38,10 @@ func testUtil(t *testing.T, fileName string) {
 		w.Fset = fset
 		w.ASTs = []*ast.File{f}
 		cfg.ExpandCFGRecur(w, make([]*cfg.FnWrapper, 0))
+		cfg.ConvertCFGtoSSAForm(w)
 	}
 
 	logs := helper.ParseProject("../../../../sourcecrawler")
-	//Sample stack trace
-	stkTrcFile, err := os.Open("../../../stackTrace.log")
-	if err != nil {
-		fmt.Println("Bad stack trace file")
-	}
-	scanner := bufio.NewScanner(stkTrcFile)
-	messageString := ""
-	for scanner.Scan() {
-		messageString += scanner.Text() + "\n"
-	}
-	// fmt.Println("Message", messageString)
-	stkInfo := helper.ParsePanic("../../../../sourcecrawler", messageString)
-
 	// condStmts := make(map[ast.Node]cfg.ExecutionLabel)
 	// vars := make([]ast.Node, 0)
 	// var exprs []ast.Node
@@ -61,7 +49,7 @@ func testUtil(t *testing.T, fileName string) {
 	paths := cfg.CreateNewPath()
 	leaves := cfg.GetLeafNodes(w)
 	for _, leaf := range leaves {
-		paths.LabelCFG(leaf, logs, w, stkInfo) //output isnt printed, but labeling still occurs
+		paths.LabelCFG(leaf, logs) //output isnt printed, but labeling still occurs
 		paths.TraverseCFG(leaf, w)
 	}
 
@@ -134,3 +122,138 @@ func TestReassignment(t *testing.T) {
 func TestZ3AndSSA(t *testing.T) {
 	testUtil(t, "example_z3.go")
 }
+
+//Expanded graph of the function of the ssa form fixture and the printed source
+func ssaFixture(t *testing.T, file *ast.File, fset *token.FileSet) (*cfg.FnWrapper, string) {
+	var source strings.Builder
+	printer.Fprint(&source, fset, file.Decls[0])
+	return wrapFixture(t, file, fset, "Merge"), source.String()
+}
+
+func TestSSAForm(t *testing.T) {
+	file, fset := parseFixture(t, "ssaform/ssaform.go")
+	w, source := ssaFixture(t, file, fset)
+	cfg.ConvertCFGtoSSAForm(w)
+	join := blockAt(t, w, fset, 10)
+
+	t.Run("phi", func(t *testing.T) {
+		if join.SSA == nil || len(join.SSA.Phis) != 1 {
+			t.Fatalf("expected a phi at the join")
+		}
+		phi := join.SSA.Phis[0]
+		edges := make([]string, 0)
+		for _, parent := range join.Parents {
+			edges = append(edges, phi.Edges[parent].Name())
+		}
+		if phi.Value.Name() != "4Merge.x" || strings.Join(edges, " ") != "2Merge.x 3Merge.x" {
+			t.Errorf("expected 4Merge.x from 2Merge.x 3Merge.x, found %s from %v", phi.Value, edges)
+		}
+		var condition strings.Builder
+		printer.Fprint(&condition, fset, join.SSA.Condition)
+		if condition.String() != "4Merge.x > 10" {
+			t.Errorf("expected the condition over the phi, found %s", condition.String())
+		}
+		if line := fset.Position(join.SSA.Instrs[0].Pos()).Line; line != 10 {
+			t.Errorf("expected the condition at line 10, found %d", line)
+		}
+	})
+
+	//every path picks the version of the branch it went through
+	t.Run("paths", func(t *testing.T) {
+		paths := cfg.CreateNewPath()
+		paths.TraverseCFG(join.Succs[0], w)
+		if len(paths.Paths) != 2 {
+			t.Fatalf("expected two paths to the panic, found %d", len(paths.Paths))
+		}
+		config := z3.NewConfig()
+		ctx := z3.NewContext(config)
+		config.Close()
+		defer ctx.Close()
+		for _, path := range paths.Paths {
+			s := ctx.NewSolver()
+			positive := false
+			for _, expr := range path.Expressions {
+				var bf strings.Builder
+				printer.Fprint(&bf, fset, expr)
+				positive = positive || bf.String() == "Merge.a > 0"
+				if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+					s.Assert(condition)
+				}
+			}
+			if s.Check() != z3.True {
+				t.Fatalf("the path %v is unsolvable", path.Expressions)
+			}
+			m := s.Model()
+			a := m.Assignments()["Merge.a"].Int()
+			if (positive && a <= 10) || (!positive && a >= -10) {
+				t.Errorf("expected |Merge.a| > 10 on the branch taken, found %d", a)
+			}
+			m.Close()
+			s.Close()
+		}
+	})
+
+	//the source stays as it was, slicing it again gives the same form
+	t.Run("source", func(t *testing.T) {
+		again, printed := ssaFixture(t, file, fset)
+		if printed != source {
+			t.Errorf("expected the source to be unchanged, found\n%s", printed)
+		}
+		cfg.ConvertCFGtoSSAForm(again)
+		var first, second strings.Builder
+		printer.Fprint(&first, fset, join.SSA.Condition)
+		printer.Fprint(&second, fset, blockAt(t, again, fset, 10).SSA.Condition)
+		if first.String() != second.String() {
+			t.Errorf("expected %s again, found %s", first.String(), second.String())
+		}
+	})
+}
+
+//The sum read after the loop is the one the loop carried on each path
+func TestSSALoopCarried(t *testing.T) {
+	w, fset := fixture(t, "ssaform/ssaform.go", "Accumulate")
+	cfg.ConvertCFGtoSSAForm(w)
+
+	names := make([]string, 0)
+	for _, b := range loopBlocks(w, map[cfg.Wrapper]bool{}, map[cfg.Wrapper]bool{}, t) {
+		if b.HeadOf != nil {
+			for _, phi := range b.SSA.Phis {
+				names = append(names, phi.Value.Name())
+			}
+		}
+	}
+	if strings.Join(names, " ") != "2Accumulate.i 2Accumulate.total" {
+		t.Errorf("expected phis for the carried variables at the header, found %v", names)
+	}
+
+	paths := cfg.CreateNewPath()
+	paths.TraverseCFG(blockAt(t, w, fset, 22), w)
+	if len(paths.Paths) == 0 {
+		t.Fatal("expected paths to the panic")
+	}
+	config := z3.NewConfig()
+	ctx := z3.NewContext(config)
+	config.Close()
+	defer ctx.Close()
+	solvable := 0
+	for _, path := range paths.Paths {
+		s := ctx.NewSolver()
+		for _, expr := range path.Expressions {
+			if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+				s.Assert(condition)
+			}
+		}
+		if s.Check() == z3.True {
+			solvable++
+			m := s.Model()
+			if n := m.Assignments()["Accumulate.n"]; n == nil || n.Int() < 2 {
+				t.Errorf("expected Accumulate.n >= 2, found %v", n)
+			}
+			m.Close()
+		}
+		s.Close()
+	}
+	if solvable == 0 {
+		t.Errorf("expected a solvable path to the panic")
+	}
+}
@@ -0,0 +1,46 @@
+package ssabackend
+
+type Limits struct {
+	Max int
+}
+
+func Check(l *Limits, a, b int) int {
+	total := a
+	if b > 0 {
+		total = a + b
+	}
+	if total > l.Max {
+		panic("over the limit")
+	}
+	return total
+}
+
+func Sum(n int) int {
+	s := 0
+	for i := 0; i < n; i++ {
+		s += scale(i)
+	}
+	if s > 1 {
+		panic("too much")
+	}
+	return s
+}
+
+func scale(i int) int {
+	return i * 2
+}
+
+func Bump(l *Limits, n int) {
+	l.Max = n
+	if l.Max > 10 {
+		panic("raised")
+	}
+}
+
+func Grow(l *Limits) {
+	before := l.Max
+	l.Max = before + 1
+	if l.Max != before {
+		panic("grown")
+	}
+}
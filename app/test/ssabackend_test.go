@@ -0,0 +1,89 @@
+package test
+
+import (
+	"go/printer"
+	"sourcecrawler/app/cfg"
+	"sourcecrawler/app/helper"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-z3"
+)
+
+func TestSSABackend(t *testing.T) {
+	prog, err := cfg.NewSSAProgram(helper.LoadTypedProject("ssabackend"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		fn        string
+		line      int
+		condition string
+		paths     int //every one of them is solvable, 0 when only some are
+		variable  string
+		min       int
+	}{
+		//the total merged from both branches is compared to a field
+		{"Check", 13, "Check.t2 > Check.t4", 2, "", 0},
+		//the sum is carried around the loop and through the call
+		{"Sum", 24, "Sum.t4 > 1", 0, "Sum.n", 2},
+		//the load reads the value stored before it
+		{"Bump", 36, "Bump.t2 > 10", 1, "Bump.n", 11},
+		//a load after a store doesn't share the value of the one before
+		{"Grow", 44, "Grow.t5 != Grow.t1", 1, "", 0},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			w := cfg.NewSSAFnWrapper(prog.Function("ssabackend", test.fn))
+			w.SetOuterWrapper(prog.Root())
+			cfg.ExpandSSA(w)
+			panicBlock := blockAt(t, w, prog.Fset, test.line)
+
+			paths := cfg.CreateNewPath()
+			paths.TraverseCFG(panicBlock, w)
+			if test.paths != 0 && len(paths.Paths) != test.paths {
+				t.Fatalf("expected %d paths to the panic, found %d", test.paths, len(paths.Paths))
+			}
+
+			config := z3.NewConfig()
+			ctx := z3.NewContext(config)
+			config.Close()
+			defer ctx.Close()
+
+			solvable := 0
+			for _, path := range paths.Paths {
+				s := ctx.NewSolver()
+				found := false
+				for _, expr := range path.Expressions {
+					var bf strings.Builder
+					printer.Fprint(&bf, prog.Fset, expr)
+					found = found || bf.String() == test.condition
+					if condition := cfg.ConvertExprToZ3(ctx, expr, prog.Fset); condition != nil {
+						s.Assert(condition)
+					}
+				}
+				if !found {
+					t.Errorf("expected %s in %v", test.condition, path.Expressions)
+				}
+				if s.Check() == z3.True {
+					solvable++
+					if test.variable != "" {
+						m := s.Model()
+						if v := m.Assignments()[test.variable]; v == nil || v.Int() < test.min {
+							t.Errorf("expected %s >= %d, found %v", test.variable, test.min, v)
+						}
+						m.Close()
+					}
+				} else if test.paths != 0 {
+					t.Errorf("the path %v is unsolvable", path.Expressions)
+				}
+				s.Close()
+			}
+			if solvable == 0 {
+				t.Errorf("expected a solvable path to the panic")
+			}
+		})
+	}
+}
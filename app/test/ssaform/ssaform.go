@@ -0,0 +1,25 @@
+package ssaform
+
+func Merge(a int) int {
+	x := 0
+	if a > 0 {
+		x = a
+	} else {
+		x = -a
+	}
+	if x > 10 {
+		panic("large")
+	}
+	return x
+}
+
+func Accumulate(n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += i
+	}
+	if total > 0 {
+		panic("large")
+	}
+	return total
+}
@@ -0,0 +1,37 @@
+package switches
+
+import "log"
+
+func Grade(n int) {
+	switch n {
+	case 1:
+		log.Printf("one")
+	case 2, 3:
+		log.Printf("two or three")
+	case 4:
+		panic("four")
+	case 5:
+	default:
+		log.Printf("other")
+	}
+}
+
+func Classify(v interface{}) {
+	switch v.(type) {
+	case int:
+		log.Printf("int")
+	case string:
+		panic("string")
+	default:
+		log.Printf("other")
+	}
+}
+
+func Receive(a chan int, b chan int) {
+	select {
+	case <-a:
+		log.Printf("a")
+	case <-b:
+		panic("b")
+	}
+}
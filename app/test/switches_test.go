@@ -0,0 +1,174 @@
+package test
+
+import (
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"sourcecrawler/app/cfg"
+	"sourcecrawler/app/model"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-z3"
+)
+
+//Wraps and expands a function of the switches fixture, along with the
+//block of the panic on the given line
+func switchFixture(t *testing.T, name string, line int) (*cfg.FnWrapper, *token.FileSet, *cfg.BlockWrapper) {
+	w, fset := fixture(t, "switches/switches.go", name)
+	for _, b := range loopBlocks(w, map[cfg.Wrapper]bool{}, map[cfg.Wrapper]bool{}, t) {
+		for _, node := range b.Block.Nodes {
+			if fset.Position(node.Pos()).Line == line {
+				return w, fset, b
+			}
+		}
+	}
+	t.Fatalf("no block for line %d", line)
+	return nil, nil, nil
+}
+
+//Case value, type or channel operation a test is for
+func caseName(c *cfg.SwitchCase) string {
+	if c.Value != nil {
+		return types.ExprString(c.Value)
+	}
+	switch comm := c.Clause.(*ast.CommClause).Comm.(type) {
+	case *ast.ExprStmt:
+		return types.ExprString(comm.X)
+	case *ast.SendStmt:
+		return types.ExprString(comm.Chan) + " <-"
+	case *ast.AssignStmt:
+		return types.ExprString(comm.Rhs[0])
+	}
+	return ""
+}
+
+func TestSwitchConditions(t *testing.T) {
+	cases := []struct {
+		fn        string
+		line      int
+		variable  string
+		value     int
+		condition string
+	}{
+		{"Grade", 12, "Grade.n", 4, "Grade.n == 4"},
+		{"Classify", 24, "", 0, `Classify.v.(type) == "string"`},
+		{"Receive", 35, "", 0, ""},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			w, fset, panicBlock := switchFixture(t, test.fn, test.line)
+			cfg.ConvertCFGtoSSAForm(w)
+			paths := cfg.CreateNewPath()
+			paths.TraverseCFG(panicBlock, w)
+			if len(paths.Paths) != 1 {
+				t.Fatalf("expected one path to the panic, found %d", len(paths.Paths))
+			}
+
+			conditions := make([]string, 0)
+			for _, expr := range paths.Paths[0].Expressions {
+				var bf strings.Builder
+				printer.Fprint(&bf, fset, expr)
+				//the tag and case value are on different lines
+				conditions = append(conditions, strings.Join(strings.Fields(bf.String()), " "))
+			}
+			if test.condition != "" {
+				found := false
+				for _, condition := range conditions {
+					found = found || condition == test.condition
+				}
+				if !found {
+					t.Errorf("expected %s in %v", test.condition, conditions)
+				}
+			}
+
+			config := z3.NewConfig()
+			ctx := z3.NewContext(config)
+			config.Close()
+			defer ctx.Close()
+
+			s := ctx.NewSolver()
+			defer s.Close()
+			for _, expr := range paths.Paths[0].Expressions {
+				if condition := cfg.ConvertExprToZ3(ctx, expr, fset); condition != nil {
+					s.Assert(condition)
+				}
+			}
+			if s.Check() != z3.True {
+				t.Fatalf("the path %v is unsolvable", conditions)
+			}
+			if test.variable != "" {
+				m := s.Model()
+				defer m.Close()
+				if v := m.Assignments()[test.variable]; v == nil || v.Int() != test.value {
+					t.Errorf("expected %s = %d, found %v", test.variable, test.value, v)
+				}
+			}
+		})
+	}
+}
+
+func TestSwitchLabels(t *testing.T) {
+	cases := []struct {
+		fn     string
+		line   int
+		logs   []string
+		tests  map[string]cfg.ExecutionLabel
+		bodies map[string]cfg.ExecutionLabel
+	}{
+		{
+			"Grade", 12, []string{"one"},
+			map[string]cfg.ExecutionLabel{"1": cfg.Must, "2": cfg.Must, "3": cfg.Must, "4": cfg.Must, "5": cfg.May},
+			map[string]cfg.ExecutionLabel{"1": cfg.Must, "2": cfg.MustNot, "4": cfg.Must, "5": cfg.May},
+		},
+		{
+			"Classify", 24, nil,
+			map[string]cfg.ExecutionLabel{"int": cfg.Must, "string": cfg.Must},
+			map[string]cfg.ExecutionLabel{"int": cfg.MustNot, "string": cfg.Must},
+		},
+		{
+			"Receive", 35, []string{"a"},
+			map[string]cfg.ExecutionLabel{"<-a": cfg.Must, "<-b": cfg.Must},
+			map[string]cfg.ExecutionLabel{"<-a": cfg.Must, "<-b": cfg.Must},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			w, _, panicBlock := switchFixture(t, test.fn, test.line)
+			logs := make([]model.LogType, 0)
+			for _, regex := range test.logs {
+				logs = append(logs, model.LogType{Regex: regex})
+			}
+
+			paths := cfg.CreateNewPath()
+			paths.CompleteLogs = true
+			paths.LabelCFG(panicBlock, logs)
+
+			tests := make(map[string]cfg.ExecutionLabel)
+			bodies := make(map[string]cfg.ExecutionLabel)
+			for _, b := range loopBlocks(w, map[cfg.Wrapper]bool{}, map[cfg.Wrapper]bool{}, t) {
+				if b.Case != nil {
+					name := caseName(b.Case)
+					tests[name] = b.GetLabel()
+					if _, ok := test.bodies[name]; ok {
+						bodies[name] = b.Succs[0].GetLabel()
+					}
+				}
+			}
+
+			for name, label := range test.tests {
+				if tests[name] != label {
+					t.Errorf("expected the test for %s to be %v, found %v", name, label, tests[name])
+				}
+			}
+			for name, label := range test.bodies {
+				if bodies[name] != label {
+					t.Errorf("expected the body of %s to be %v, found %v", name, label, bodies[name])
+				}
+			}
+		})
+	}
+}
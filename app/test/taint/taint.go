@@ -0,0 +1,85 @@
+package taint
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+type Order struct {
+	Qty int
+}
+
+func Param(n int) {
+	m := n * 2
+	if m > 10 {
+		panic("param")
+	}
+}
+
+func Args() {
+	n, err := strconv.Atoi(os.Args[1])
+	if err == nil && n > 3 {
+		panic("args")
+	}
+}
+
+func Env() {
+	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	if port < 1024 {
+		panic("env")
+	}
+}
+
+func Flag() {
+	var n int
+	flag.IntVar(&n, "n", 0, "number of items")
+	flag.Parse()
+	if n > 100 {
+		panic("flag")
+	}
+}
+
+func Handle(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(r.FormValue("n"))
+	if n > 5 {
+		panic("request")
+	}
+}
+
+func Decode(data []byte) {
+	var order Order
+	json.Unmarshal(data, &order)
+	if order.Qty > 10 {
+		panic("json")
+	}
+}
+
+func File() {
+	data, _ := ioutil.ReadFile("input.txt")
+	if len(data) > 100 {
+		panic("file")
+	}
+}
+
+func Internal(n int) {
+	limit := compute()
+	if n > limit {
+		panic("internal")
+	}
+}
+
+func compute() int {
+	return 7
+}
+
+func Assigned() {
+	var port int
+	port, _ = strconv.Atoi(os.Getenv("PORT"))
+	if port < 1024 {
+		panic("assigned")
+	}
+}
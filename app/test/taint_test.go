@@ -0,0 +1,49 @@
+package test
+
+import (
+	"sourcecrawler/app/cfg"
+	"testing"
+)
+
+func TestInputSources(t *testing.T) {
+	cases := []struct {
+		fn       string
+		line     int
+		input    string
+		source   cfg.SourceKind
+		computed string //in the model but not an input
+	}{
+		{"Param", 19, "Param.n", cfg.Parameter, "1Param.m"},
+		{"Args", 26, "1Args.strconv.Atoi().0", cfg.Arguments, "1Args.n"},
+		{"Env", 33, "1Env.strconv.Atoi().0", cfg.Environment, "1Env.port"},
+		{"Flag", 42, "Flag.n", cfg.Flag, ""},
+		{"Handle", 49, "1Handle.strconv.Atoi().0", cfg.Request, "1Handle.n"},
+		{"Decode", 57, "Decode.order.Qty", cfg.JSON, ""},
+		{"File", 64, "len(1File.data)", cfg.File, "1File.data"},
+		//the result of a function of the program isn't an input
+		{"Internal", 71, "Internal.n", cfg.Parameter, "1Internal.compute()"},
+		//the value is assigned after the declaration
+		{"Assigned", 83, "1Assigned.strconv.Atoi().0", cfg.Environment, "1Assigned.port"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.fn, func(t *testing.T) {
+			solved, done := solveFixture(t, "taint/taint.go", test.fn, test.line)
+			defer done()
+			if solved.model == nil {
+				t.Fatalf("the path %v is unsolvable", solved.conditions)
+			}
+			assignments := solved.model.Assignments()
+			sources := cfg.InputSources(solved.block, solved.path.Expressions, assignments)
+			if source := sources[test.input]; source != test.source {
+				t.Errorf("expected %s to come from the %v, found %v in %v", test.input, test.source, source, sources)
+			}
+			if _, ok := assignments[test.computed]; test.computed != "" && !ok {
+				t.Errorf("expected %s in the model %v", test.computed, assignments)
+			}
+			if _, ok := sources[test.computed]; ok {
+				t.Errorf("expected %s not to be an input", test.computed)
+			}
+		})
+	}
+}
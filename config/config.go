@@ -1,29 +1,417 @@
 package config
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+//EnvPrefix starts the name of every environment variable read by Load
+const EnvPrefix = "SOURCECRAWLER_"
+
+//Config is the server configuration, it is layered from the defaults,
+//a YAML or TOML file, environment variables and command line flags
 type Config struct {
-	DB *DBConfig
+	Addr         string        `yaml:"addr" toml:"addr" json:"addr"`
+	DB           *DBConfig     `yaml:"db" toml:"db" json:"db"`
+	Solver       *SolverConfig `yaml:"solver" toml:"solver" json:"solver"`
+	Slicer       *SlicerConfig `yaml:"slicer" toml:"slicer" json:"slicer"`
+	Logs         *LogConfig    `yaml:"logs" toml:"logs" json:"logs"`
+	PathMappings []PathMapping `yaml:"pathMappings" toml:"pathMappings" json:"pathMappings"`
 }
 
+//DBConfig is the database connection, a DSN takes precedence over the other fields
 type DBConfig struct {
-	Dialect  string
-	Host     string
-	Port     int
-	Username string
-	Password string
-	Name     string
-	Charset  string
+	Dialect  string `yaml:"dialect" toml:"dialect" json:"dialect"`
+	DSN      string `yaml:"dsn" toml:"dsn" json:"dsn,omitempty"`
+	Host     string `yaml:"host" toml:"host" json:"host"`
+	Port     int    `yaml:"port" toml:"port" json:"port"`
+	Username string `yaml:"username" toml:"username" json:"username"`
+	Password string `yaml:"password" toml:"password" json:"password,omitempty"`
+	Name     string `yaml:"name" toml:"name" json:"name"`
+	Charset  string `yaml:"charset" toml:"charset" json:"charset"`
+}
+
+//SolverConfig holds the z3 settings, a timeout of 0 means no timeout
+type SolverConfig struct {
+	TimeoutMs int `yaml:"timeoutMs" toml:"timeoutMs" json:"timeoutMs"`
+}
+
+//SlicerConfig bounds the work done for a slicing request, 0 means no limit
+type SlicerConfig struct {
+	MaxPaths     int    `yaml:"maxPaths" toml:"maxPaths" json:"maxPaths"` //the likeliest paths are kept
+	MaxCallDepth int    `yaml:"maxCallDepth" toml:"maxCallDepth" json:"maxCallDepth"`
+	MaxDepth     int    `yaml:"maxDepth" toml:"maxDepth" json:"maxDepth"`          //most blocks in a path
+	TimeoutMs    int    `yaml:"timeoutMs" toml:"timeoutMs" json:"timeoutMs"`       //gathering the paths stops after it
+	LoopBound    int    `yaml:"loopBound" toml:"loopBound" json:"loopBound"`       //most iterations of a loop in a path
+	CallContext  int    `yaml:"callContext" toml:"callContext" json:"callContext"` //clones of a recursive function in one call chain
+	Summaries    string `yaml:"summaries" toml:"summaries" json:"summaries"`       //YAML file of library function summaries, added to the built-in ones
+	Backend      string `yaml:"backend" toml:"backend" json:"backend"`             //ast builds the graphs from go/cfg, ssa from golang.org/x/tools/go/ssa
+}
+
+//LogConfig decides which calls are log statements: a call is a log when
+//its receiver mentions one of the loggers and (for selector chains like
+//zerolog's) the method is one of the methods
+type LogConfig struct {
+	Loggers []string `yaml:"loggers" toml:"loggers" json:"loggers"`
+	Methods []string `yaml:"methods" toml:"methods" json:"methods"`
+}
+
+//PathMapping rewrites the file paths of stack traces, for binaries built
+//somewhere else than where the project is checked out
+type PathMapping struct {
+	From string `yaml:"from" toml:"from" json:"from"`
+	To   string `yaml:"to" toml:"to" json:"to"`
 }
 
-func GetConfig() *Config {
+//Default is the configuration used when nothing else is given
+func Default() *Config {
 	return &Config{
+		Addr: ":3000",
 		DB: &DBConfig{
-			Dialect:  "mysql",
+			Dialect:  "sqlite3",
 			Host:     "127.0.0.1",
 			Port:     3306,
 			Username: "sourcecrawler",
-			Password: "password",
-			Name:     "sourcecrawler",
+			Name:     "sourcecrawler.db",
 			Charset:  "utf8",
 		},
+		Solver: &SolverConfig{
+			TimeoutMs: 10000,
+		},
+		Slicer: &SlicerConfig{
+			MaxPaths:     1000,
+			MaxCallDepth: 32,
+			MaxDepth:     2000,
+			TimeoutMs:    30000,
+			LoopBound:    3,
+			CallContext:  1,
+			Backend:      "ast",
+		},
+		Logs: &LogConfig{
+			Loggers: []string{"log"},
+			Methods: []string{"Msg", "Msgf", "Err", "Errorf"},
+		},
+		PathMappings: []PathMapping{},
+	}
+}
+
+//Load builds the configuration from the defaults, then the config file
+//(-config flag or SOURCECRAWLER_CONFIG), then SOURCECRAWLER_* environment
+//variables and last the command line flags, the result is validated
+func Load(args []string) (*Config, error) {
+	config := Default()
+
+	flags := flag.NewFlagSet("sourcecrawler", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv(EnvPrefix+"CONFIG"), "YAML or TOML configuration file")
+	addr := flags.String("addr", "", "listen address")
+	dialect := flags.String("db-dialect", "", "database dialect (sqlite3, mysql, postgres)")
+	dsn := flags.String("db-dsn", "", "database connection string")
+	timeout := flags.Int("solver-timeout", 0, "solver timeout in milliseconds")
+	maxPaths := flags.Int("max-paths", 0, "maximum number of paths per request")
+	maxCallDepth := flags.Int("max-call-depth", 0, "maximum depth of expanded calls")
+	maxDepth := flags.Int("max-depth", 0, "maximum number of blocks in a path")
+	slicerTimeout := flags.Int("slicer-timeout", 0, "time gathering the paths in milliseconds")
+	loopBound := flags.Int("loop-bound", 0, "maximum iterations of a loop in a path")
+	callContext := flags.Int("call-context", 0, "clones of a recursive function in one call chain")
+	summaries := flags.String("summaries", "", "YAML file of library function summaries")
+	backend := flags.String("backend", "", "graphs built from the syntax (ast) or from go/ssa (ssa)")
+	loggers := flags.String("loggers", "", "comma separated logger names")
+	methods := flags.String("log-methods", "", "comma separated log method names")
+	mappings := flags.String("path-mappings", "", "comma separated from=to path prefixes")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := config.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.loadEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			config.Addr = *addr
+		case "db-dialect":
+			config.DB.Dialect = *dialect
+		case "db-dsn":
+			config.DB.DSN = *dsn
+		case "solver-timeout":
+			config.Solver.TimeoutMs = *timeout
+		case "max-paths":
+			config.Slicer.MaxPaths = *maxPaths
+		case "max-call-depth":
+			config.Slicer.MaxCallDepth = *maxCallDepth
+		case "max-depth":
+			config.Slicer.MaxDepth = *maxDepth
+		case "slicer-timeout":
+			config.Slicer.TimeoutMs = *slicerTimeout
+		case "loop-bound":
+			config.Slicer.LoopBound = *loopBound
+		case "call-context":
+			config.Slicer.CallContext = *callContext
+		case "summaries":
+			config.Slicer.Summaries = *summaries
+		case "backend":
+			config.Slicer.Backend = *backend
+		case "loggers":
+			config.Logs.Loggers = splitList(*loggers)
+		case "log-methods":
+			config.Logs.Methods = splitList(*methods)
+		case "path-mappings":
+			if config.PathMappings, err = parseMappings(*mappings); err != nil {
+				err = fmt.Errorf("-path-mappings: %v", err)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+//Reads the file over the current values, the format is picked by extension
+func (c *Config) loadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	case ".toml":
+		_, err = toml.Decode(string(data), c)
+	default:
+		return fmt.Errorf("unknown config file format %s, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", path, err)
+	}
+
+	//sections left out of the file keep their defaults
+	defaults := Default()
+	if c.DB == nil {
+		c.DB = defaults.DB
+	}
+	if c.Solver == nil {
+		c.Solver = defaults.Solver
+	}
+	if c.Slicer == nil {
+		c.Slicer = defaults.Slicer
+	}
+	if c.Logs == nil {
+		c.Logs = defaults.Logs
+	}
+	return nil
+}
+
+//Reads the SOURCECRAWLER_* variables over the current values
+func (c *Config) loadEnv(getenv func(string) string) error {
+	strs := map[string]*string{
+		"ADDR":             &c.Addr,
+		"DB_DIALECT":       &c.DB.Dialect,
+		"DB_DSN":           &c.DB.DSN,
+		"DB_HOST":          &c.DB.Host,
+		"DB_USERNAME":      &c.DB.Username,
+		"DB_PASSWORD":      &c.DB.Password,
+		"DB_NAME":          &c.DB.Name,
+		"DB_CHARSET":       &c.DB.Charset,
+		"SLICER_SUMMARIES": &c.Slicer.Summaries,
+		"SLICER_BACKEND":   &c.Slicer.Backend,
+	}
+	for name, field := range strs {
+		if value := getenv(EnvPrefix + name); value != "" {
+			*field = value
+		}
+	}
+
+	ints := map[string]*int{
+		"DB_PORT":               &c.DB.Port,
+		"SOLVER_TIMEOUT_MS":     &c.Solver.TimeoutMs,
+		"SLICER_MAX_PATHS":      &c.Slicer.MaxPaths,
+		"SLICER_MAX_CALL_DEPTH": &c.Slicer.MaxCallDepth,
+		"SLICER_MAX_DEPTH":      &c.Slicer.MaxDepth,
+		"SLICER_TIMEOUT_MS":     &c.Slicer.TimeoutMs,
+		"SLICER_LOOP_BOUND":     &c.Slicer.LoopBound,
+		"SLICER_CALL_CONTEXT":   &c.Slicer.CallContext,
+	}
+	for name, field := range ints {
+		if value := getenv(EnvPrefix + name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", EnvPrefix, name, err)
+			}
+			*field = n
+		}
+	}
+
+	if value := getenv(EnvPrefix + "LOG_LOGGERS"); value != "" {
+		c.Logs.Loggers = splitList(value)
+	}
+	if value := getenv(EnvPrefix + "LOG_METHODS"); value != "" {
+		c.Logs.Methods = splitList(value)
+	}
+	if value := getenv(EnvPrefix + "PATH_MAPPINGS"); value != "" {
+		mappings, err := parseMappings(value)
+		if err != nil {
+			return fmt.Errorf("%sPATH_MAPPINGS: %v", EnvPrefix, err)
+		}
+		c.PathMappings = mappings
+	}
+	return nil
+}
+
+//Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	problems := make([]string, 0)
+	if c.Addr == "" {
+		problems = append(problems, "addr is empty")
+	}
+	if c.DB == nil || c.Solver == nil || c.Slicer == nil || c.Logs == nil {
+		return errors.New("invalid configuration: missing section")
+	}
+
+	switch c.DB.Dialect {
+	case "sqlite3":
+		if c.DB.DSN == "" && c.DB.Name == "" {
+			problems = append(problems, "db.name (the sqlite file) or db.dsn is required")
+		}
+	case "mysql", "postgres":
+		if c.DB.DSN == "" && (c.DB.Host == "" || c.DB.Name == "") {
+			problems = append(problems, "db.host and db.name or db.dsn are required for "+c.DB.Dialect)
+		}
+		if c.DB.Port < 0 || c.DB.Port > 65535 {
+			problems = append(problems, fmt.Sprintf("db.port %d is out of range", c.DB.Port))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported db.dialect %q", c.DB.Dialect))
+	}
+
+	if c.Solver.TimeoutMs < 0 {
+		problems = append(problems, "solver.timeoutMs can't be negative")
+	}
+	if c.Slicer.MaxPaths < 0 {
+		problems = append(problems, "slicer.maxPaths can't be negative")
+	}
+	if c.Slicer.MaxCallDepth < 0 {
+		problems = append(problems, "slicer.maxCallDepth can't be negative")
+	}
+	if c.Slicer.MaxDepth < 0 {
+		problems = append(problems, "slicer.maxDepth can't be negative")
+	}
+	if c.Slicer.TimeoutMs < 0 {
+		problems = append(problems, "slicer.timeoutMs can't be negative")
+	}
+	if c.Slicer.LoopBound < 1 {
+		problems = append(problems, "slicer.loopBound must be at least 1")
+	}
+	if c.Slicer.CallContext < 1 {
+		problems = append(problems, "slicer.callContext must be at least 1")
+	}
+	if c.Slicer.Backend != "ast" && c.Slicer.Backend != "ssa" {
+		problems = append(problems, fmt.Sprintf("unsupported slicer.backend %q, expected ast or ssa", c.Slicer.Backend))
+	}
+	if len(c.Logs.Loggers) == 0 {
+		problems = append(problems, "logs.loggers needs at least one logger")
+	}
+	for i, mapping := range c.PathMappings {
+		if mapping.From == "" {
+			problems = append(problems, fmt.Sprintf("pathMappings[%d].from is empty", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+//URI builds the connection string for the configured dialect
+func (c *DBConfig) URI() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	switch c.Dialect {
+	case "mysql":
+		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True",
+			c.Username,
+			c.Password,
+			c.Host,
+			c.Port,
+			c.Name,
+			c.Charset)
+	case "postgres":
+		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s",
+			c.Host,
+			c.Port,
+			c.Username,
+			c.Password,
+			c.Name)
+	default:
+		//sqlite3 only needs the database file
+		return c.Name
+	}
+}
+
+const redacted = "********"
+
+var (
+	dsnUserPassword = regexp.MustCompile(`^([^:/@]*(?:://)?[^:/@]*):([^@]*)@`)
+	dsnPasswordKey  = regexp.MustCompile(`(password=)(\S*)`)
+)
+
+//Redacted is a copy of the configuration that is safe to show,
+//passwords (including ones inside the DSN) are masked
+func (c *Config) Redacted() *Config {
+	copied := *c
+	db := *c.DB
+	if db.Password != "" {
+		db.Password = redacted
+	}
+	db.DSN = dsnUserPassword.ReplaceAllString(db.DSN, "$1:"+redacted+"@")
+	db.DSN = dsnPasswordKey.ReplaceAllString(db.DSN, "${1}"+redacted)
+	copied.DB = &db
+	return &copied
+}
+
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+//Parses from=to pairs separated by commas
+func parseMappings(value string) ([]PathMapping, error) {
+	mappings := make([]PathMapping, 0)
+	for _, pair := range splitList(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q should be from=to", pair)
+		}
+		mappings = append(mappings, PathMapping{From: parts[0], To: parts[1]})
 	}
+	return mappings, nil
 }
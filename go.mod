@@ -3,9 +3,11 @@ module sourcecrawler
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/golang-collections/collections v0.0.0-20130729185459-604e922904d3
 	github.com/gorilla/mux v1.7.4
 	github.com/jinzhu/gorm v1.9.12
+	github.com/lib/pq v1.1.1 // indirect
 	github.com/mingrammer/go-todo-rest-api-example v0.0.0-20190527014715-ae46b4d42804
 	github.com/mitchellh/go-z3 v0.0.0-20191228203228-4cbedeba863f
 	github.com/neo4j-drivers/gobolt v1.7.4 // indirect
@@ -13,4 +15,5 @@ require (
 	github.com/rs/zerolog v1.19.0
 	github.com/zenazn/goji v0.9.0 // indirect
 	golang.org/x/tools v0.0.0-20190828213141-aed303cbaa74
+	gopkg.in/yaml.v2 v2.4.0
 )
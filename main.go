@@ -1,16 +1,21 @@
 package main
 
 import (
+	"log"
+	"os"
 	"sourcecrawler/app"
 	"sourcecrawler/config"
 )
 
 func main() {
 
-	config := config.GetConfig()
+	config, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	app := &app.App{}
 	app.Initialize(config)
-	app.Run(":3000")
+	app.Run(config.Addr)
 
 }